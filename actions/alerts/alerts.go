@@ -0,0 +1,351 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/localsystem"
+	"github.com/byuoitav/device-monitoring/messenger"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+// Level is the severity of an alert.
+type Level int
+
+const (
+	LevelNone Level = iota
+	LevelWarning
+	LevelCritical
+)
+
+// String returns the name of the level, as used in alert event values.
+func (l Level) String() string {
+	switch l {
+	case LevelWarning:
+		return "warning"
+	case LevelCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// MarshalText lets levels show up by name in JSON.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Threshold defines when a sampled metric should raise an alert.
+// Metric may be a glob (e.g. "*-temp" or "disk-used-percent-*").
+type Threshold struct {
+	Metric     string   `json:"metric"`
+	Warn       *float64 `json:"warn,omitempty"`
+	Critical   *float64 `json:"critical,omitempty"`
+	Duration   string   `json:"duration,omitempty"`
+	Hysteresis float64  `json:"hysteresis,omitempty"`
+
+	duration time.Duration
+}
+
+// Alert is an active (or just resolved) alert on a metric.
+type Alert struct {
+	Metric    string    `json:"metric"`
+	Level     Level     `json:"level"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+	UpdatedAt time.Time `json:"updated-at"`
+}
+
+type state struct {
+	alert Alert
+
+	pendingLevel Level
+	pendingSince time.Time
+}
+
+// Evaluator checks the sampler's metrics against thresholds and emits alert events.
+type Evaluator struct {
+	sampler  *localsystem.Sampler
+	interval time.Duration
+
+	mu         sync.RWMutex
+	thresholds []Threshold
+	states     map[string]*state
+}
+
+var (
+	evaluatorMu sync.RWMutex
+	evaluator   *Evaluator
+)
+
+// Validate parses the threshold's duration and makes sure it has at least one level.
+func (t *Threshold) Validate() error {
+	if t.Metric == "" {
+		return fmt.Errorf("threshold is missing a metric")
+	}
+	if _, err := path.Match(t.Metric, ""); err != nil {
+		return fmt.Errorf("invalid metric pattern %q: %w", t.Metric, err)
+	}
+	if t.Warn == nil && t.Critical == nil {
+		return fmt.Errorf("threshold for %q needs a warn or critical level", t.Metric)
+	}
+	if t.Duration != "" {
+		d, err := time.ParseDuration(t.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid duration for %q: %w", t.Metric, err)
+		}
+		t.duration = d
+	}
+	return nil
+}
+
+// levelFor returns the level a value falls into, given the level the metric is currently at.
+// Hysteresis lowers the bar for staying in a level that has already been reached.
+func (t Threshold) levelFor(v float64, cur Level) (Level, float64) {
+	if t.Critical != nil {
+		limit := *t.Critical
+		if cur >= LevelCritical {
+			limit -= t.Hysteresis
+		}
+		if v >= limit {
+			return LevelCritical, *t.Critical
+		}
+	}
+
+	if t.Warn != nil {
+		limit := *t.Warn
+		if cur >= LevelWarning {
+			limit -= t.Hysteresis
+		}
+		if v >= limit {
+			return LevelWarning, *t.Warn
+		}
+	}
+
+	return LevelNone, 0
+}
+
+// Start begins evaluating thresholds each time the sampler collects metrics. Calling it again
+// replaces the running evaluator's thresholds.
+func Start(ctx context.Context, sampler *localsystem.Sampler, thresholds []Threshold) (*Evaluator, error) {
+	evaluatorMu.Lock()
+	defer evaluatorMu.Unlock()
+
+	if evaluator != nil {
+		evaluator.SetThresholds(thresholds)
+		return evaluator, nil
+	}
+	if sampler == nil {
+		return nil, fmt.Errorf("the metrics sampler isn't running")
+	}
+
+	e := &Evaluator{
+		sampler:  sampler,
+		interval: sampler.Interval(),
+		states:   make(map[string]*state),
+	}
+	e.SetThresholds(thresholds)
+
+	evaluator = e
+	go e.run(ctx)
+	return e, nil
+}
+
+// Get returns the running evaluator, or nil if it hasn't been started.
+func Get() *Evaluator {
+	evaluatorMu.RLock()
+	defer evaluatorMu.RUnlock()
+	return evaluator
+}
+
+// SetThresholds validates and replaces the thresholds being evaluated. Invalid thresholds are
+// skipped with a warning. Levels waiting out a duration start over, and alerts on metrics that
+// no longer match a threshold are resolved the next time metrics are evaluated.
+func (e *Evaluator) SetThresholds(thresholds []Threshold) {
+	validated := make([]Threshold, 0, len(thresholds))
+	for i := range thresholds {
+		t := thresholds[i]
+		if err := t.Validate(); err != nil {
+			slog.Warn("Skipping invalid alert threshold", slog.Int("index", i), slog.Any("error", err))
+			continue
+		}
+		validated = append(validated, t)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.thresholds = validated
+	for _, s := range e.states {
+		s.pendingLevel = s.alert.Level
+		s.pendingSince = time.Time{}
+	}
+}
+
+// Thresholds returns the thresholds being evaluated.
+func (e *Evaluator) Thresholds() []Threshold {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ret := make([]Threshold, len(e.thresholds))
+	copy(ret, e.thresholds)
+	return ret
+}
+
+// Active returns the alerts that are currently raised, sorted by metric.
+func (e *Evaluator) Active() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ret := []Alert{}
+	for _, s := range e.states {
+		if s.alert.Level > LevelNone {
+			ret = append(ret, s.alert)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Metric < ret[j].Metric })
+	return ret
+}
+
+func (e *Evaluator) run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.evaluate(time.Now())
+		}
+	}
+}
+
+func (e *Evaluator) evaluate(now time.Time) {
+	for _, alert := range e.update(e.sampler.Summaries(), now) {
+		sendAlertEvent(alert)
+	}
+}
+
+// update checks each metric against its threshold and returns the alerts whose level changed.
+// Metrics that are no longer sampled (e.g. an unmounted disk) or no longer match a threshold are
+// forgotten, resolving their alert if it was raised.
+func (e *Evaluator) update(summaries map[string]localsystem.MetricSummary, now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []Alert
+	seen := make(map[string]bool, len(e.states))
+	for metric, summary := range summaries {
+		t, ok := e.match(metric)
+		if !ok {
+			continue
+		}
+		seen[metric] = true
+
+		s, ok := e.states[metric]
+		if !ok {
+			s = &state{alert: Alert{Metric: metric}}
+			e.states[metric] = s
+		}
+
+		if alert, ok := s.update(t, summary.Current, now); ok {
+			changed = append(changed, alert)
+		}
+	}
+
+	for metric, s := range e.states {
+		if seen[metric] {
+			continue
+		}
+		if s.alert.Level > LevelNone {
+			s.alert.Level = LevelNone
+			s.alert.Threshold = 0
+			s.alert.UpdatedAt = now
+			changed = append(changed, s.alert)
+		}
+		delete(e.states, metric)
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Metric < changed[j].Metric })
+	return changed
+}
+
+// match returns the first threshold whose metric pattern matches metric.
+func (e *Evaluator) match(metric string) (Threshold, bool) {
+	for _, t := range e.thresholds {
+		if ok, _ := path.Match(t.Metric, metric); ok {
+			return t, true
+		}
+	}
+	return Threshold{}, false
+}
+
+// update moves the state toward the level v falls into. Raising a level requires the
+// value to stay there for the threshold's duration; lowering a level happens right away.
+// It returns the alert and true if the level changed.
+func (s *state) update(t Threshold, v float64, now time.Time) (Alert, bool) {
+	s.alert.Value = v
+	s.alert.UpdatedAt = now
+
+	level, limit := t.levelFor(v, s.alert.Level)
+	switch {
+	case level > s.alert.Level:
+		if s.pendingLevel != level {
+			s.pendingLevel = level
+			s.pendingSince = now
+		}
+		if now.Sub(s.pendingSince) < t.duration {
+			return s.alert, false
+		}
+	case level == s.alert.Level:
+		s.pendingLevel = level
+		return s.alert, false
+	}
+
+	if s.alert.Level == LevelNone {
+		s.alert.Since = now
+	}
+	s.alert.Level = level
+	s.alert.Threshold = limit
+	s.pendingLevel = level
+	return s.alert, true
+}
+
+func sendAlertEvent(alert Alert) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		slog.Warn("unable to send alert event", slog.String("metric", alert.Metric), slog.Any("error", err))
+		return
+	}
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	event := model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        alert.UpdatedAt,
+		EventTags: []string{
+			model.Alert,
+			model.AutoGenerated,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          fmt.Sprintf("%s-alert", alert.Metric),
+		Value:        alert.Level.String(),
+		Data:         alert,
+	}
+
+	if alert.Level == LevelNone {
+		event.Value = "resolved"
+		slog.Info("Alert resolved", slog.String("metric", alert.Metric), slog.Float64("value", alert.Value))
+	} else {
+		slog.Warn("Alert raised", slog.String("metric", alert.Metric), slog.String("level", alert.Level.String()), slog.Float64("value", alert.Value))
+	}
+
+	messenger.Get().SendEvent(model.ToCommonEvent(event))
+}
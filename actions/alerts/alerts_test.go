@@ -0,0 +1,206 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/localsystem"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestLevelFor(t *testing.T) {
+	th := Threshold{Metric: "cpu_thermal0-temp", Warn: float(70), Critical: float(80), Hysteresis: 5}
+
+	tests := []struct {
+		name      string
+		value     float64
+		cur       Level
+		wantLevel Level
+		wantLimit float64
+	}{
+		{name: "below warn", value: 60, cur: LevelNone, wantLevel: LevelNone},
+		{name: "at warn", value: 70, cur: LevelNone, wantLevel: LevelWarning, wantLimit: 70},
+		{name: "above critical", value: 95, cur: LevelNone, wantLevel: LevelCritical, wantLimit: 80},
+		{name: "warning held by hysteresis", value: 66, cur: LevelWarning, wantLevel: LevelWarning, wantLimit: 70},
+		{name: "warning cleared below hysteresis", value: 64, cur: LevelWarning, wantLevel: LevelNone},
+		{name: "critical held by hysteresis", value: 76, cur: LevelCritical, wantLevel: LevelCritical, wantLimit: 80},
+		{name: "critical drops to warning", value: 74, cur: LevelCritical, wantLevel: LevelWarning, wantLimit: 70},
+		{name: "no hysteresis without the level", value: 76, cur: LevelWarning, wantLevel: LevelWarning, wantLimit: 70},
+	}
+
+	for _, tt := range tests {
+		level, limit := th.levelFor(tt.value, tt.cur)
+		if level != tt.wantLevel || limit != tt.wantLimit {
+			t.Errorf("%s: expected %s at %v, got %s at %v", tt.name, tt.wantLevel, tt.wantLimit, level, limit)
+		}
+	}
+
+	criticalOnly := Threshold{Metric: "load", Critical: float(4)}
+	if level, _ := criticalOnly.levelFor(3.9, LevelNone); level != LevelNone {
+		t.Errorf("expected no level below a critical-only threshold, got %s", level)
+	}
+	if level, _ := criticalOnly.levelFor(4, LevelNone); level != LevelCritical {
+		t.Errorf("expected critical at a critical-only threshold, got %s", level)
+	}
+}
+
+func TestStateUpdate(t *testing.T) {
+	th := Threshold{Metric: "cpu-usage-percent", Warn: float(70), Critical: float(90), Duration: "1m", Hysteresis: 5}
+	if err := th.Validate(); err != nil {
+		t.Fatalf("invalid threshold: %s", err)
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		after       time.Duration
+		value       float64
+		wantChanged bool
+		wantLevel   Level
+	}{
+		{after: 0, value: 50, wantLevel: LevelNone},
+		{after: 10 * time.Second, value: 75, wantLevel: LevelNone},                        // warning pending
+		{after: 40 * time.Second, value: 60, wantLevel: LevelNone},                        // dipped back, pending is reset
+		{after: 50 * time.Second, value: 75, wantLevel: LevelNone},                        // pending again from here
+		{after: 100 * time.Second, value: 80, wantLevel: LevelNone},                       // 50s, not held long enough
+		{after: 110 * time.Second, value: 72, wantChanged: true, wantLevel: LevelWarning}, // held for 1m
+		{after: 120 * time.Second, value: 68, wantLevel: LevelWarning},                    // held by hysteresis
+		{after: 130 * time.Second, value: 95, wantLevel: LevelWarning},                    // critical pending
+		{after: 190 * time.Second, value: 92, wantChanged: true, wantLevel: LevelCritical},
+		{after: 200 * time.Second, value: 74, wantChanged: true, wantLevel: LevelWarning}, // lowering is immediate
+		{after: 210 * time.Second, value: 40, wantChanged: true, wantLevel: LevelNone},    // resolved
+		{after: 220 * time.Second, value: 41, wantLevel: LevelNone},
+	}
+
+	s := &state{alert: Alert{Metric: th.Metric}}
+	for i, step := range steps {
+		now := start.Add(step.after)
+		alert, changed := s.update(th, step.value, now)
+		if changed != step.wantChanged || alert.Level != step.wantLevel {
+			t.Fatalf("step %d (%v at %s): expected changed=%v level=%s, got changed=%v level=%s",
+				i, step.value, step.after, step.wantChanged, step.wantLevel, changed, alert.Level)
+		}
+		if alert.Value != step.value || !alert.UpdatedAt.Equal(now) {
+			t.Errorf("step %d: expected value %v at %s, got %v at %s", i, step.value, now, alert.Value, alert.UpdatedAt)
+		}
+	}
+
+	// Since is when the alert was first raised, and isn't moved by a change of level
+	if want := start.Add(110 * time.Second); !s.alert.Since.Equal(want) {
+		t.Errorf("expected the alert to be raised since %s, got %s", want, s.alert.Since)
+	}
+}
+
+func TestStateUpdateNoDuration(t *testing.T) {
+	th := Threshold{Metric: "load", Critical: float(4)}
+	s := &state{alert: Alert{Metric: th.Metric}}
+	now := time.Now()
+
+	if alert, changed := s.update(th, 5, now); !changed || alert.Level != LevelCritical || alert.Threshold != 4 {
+		t.Errorf("expected critical to be raised right away, got changed=%v %+v", changed, alert)
+	}
+	if alert, changed := s.update(th, 3, now.Add(time.Second)); !changed || alert.Level != LevelNone {
+		t.Errorf("expected the alert to resolve, got changed=%v %+v", changed, alert)
+	}
+}
+
+func TestSetThresholdsSkipsInvalid(t *testing.T) {
+	e := &Evaluator{states: make(map[string]*state)}
+	e.SetThresholds([]Threshold{
+		{Metric: "cpu-usage-percent", Warn: float(70)},
+		{Metric: "load"}, // no level
+		{Metric: "disk-[", Warn: float(90)},
+		{Metric: "*-temp", Critical: float(80), Duration: "soon"},
+		{Metric: "*-temp", Critical: float(80), Duration: "2m"},
+	})
+
+	got := e.Thresholds()
+	if len(got) != 2 || got[0].Metric != "cpu-usage-percent" || got[1].Metric != "*-temp" {
+		t.Fatalf("expected the 2 valid thresholds, got %+v", got)
+	}
+	if got[1].duration != 2*time.Minute {
+		t.Errorf("expected the duration to be parsed, got %s", got[1].duration)
+	}
+}
+
+func summaries(values map[string]float64) map[string]localsystem.MetricSummary {
+	ret := make(map[string]localsystem.MetricSummary, len(values))
+	for metric, v := range values {
+		ret[metric] = localsystem.MetricSummary{Current: v}
+	}
+	return ret
+}
+
+func TestUpdateResolvesMissingMetrics(t *testing.T) {
+	e := &Evaluator{states: make(map[string]*state)}
+	e.SetThresholds([]Threshold{{Metric: "disk-used-percent-*", Warn: float(90)}})
+	now := time.Now()
+
+	changed := e.update(summaries(map[string]float64{"disk-used-percent-mnt-usb": 95, "disk-used-percent-root": 50}), now)
+	if len(changed) != 1 || changed[0].Level != LevelWarning {
+		t.Fatalf("expected the usb disk to raise a warning, got %+v", changed)
+	}
+
+	// the usb disk is unmounted
+	changed = e.update(summaries(map[string]float64{"disk-used-percent-root": 50}), now.Add(time.Minute))
+	if len(changed) != 1 || changed[0].Metric != "disk-used-percent-mnt-usb" || changed[0].Level != LevelNone {
+		t.Fatalf("expected the usb disk's alert to resolve, got %+v", changed)
+	}
+	if !changed[0].UpdatedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the alert to be resolved at %s, got %s", now.Add(time.Minute), changed[0].UpdatedAt)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("expected no active alerts, got %+v", active)
+	}
+	if _, ok := e.states["disk-used-percent-mnt-usb"]; ok {
+		t.Errorf("expected the usb disk's state to be forgotten")
+	}
+
+	// nothing is sent for it again
+	if changed := e.update(summaries(map[string]float64{"disk-used-percent-root": 50}), now.Add(2*time.Minute)); len(changed) != 0 {
+		t.Errorf("expected no changes, got %+v", changed)
+	}
+}
+
+func TestUpdateResolvesRemovedThresholds(t *testing.T) {
+	e := &Evaluator{states: make(map[string]*state)}
+	e.SetThresholds([]Threshold{
+		{Metric: "cpu-usage-percent", Warn: float(70)},
+		{Metric: "load", Critical: float(4)},
+	})
+	metrics := summaries(map[string]float64{"cpu-usage-percent": 80, "load": 5})
+	now := time.Now()
+
+	if changed := e.update(metrics, now); len(changed) != 2 {
+		t.Fatalf("expected 2 alerts to be raised, got %+v", changed)
+	}
+
+	e.SetThresholds([]Threshold{{Metric: "load", Critical: float(4)}})
+	changed := e.update(metrics, now.Add(time.Minute))
+	if len(changed) != 1 || changed[0].Metric != "cpu-usage-percent" || changed[0].Level != LevelNone {
+		t.Fatalf("expected the cpu alert to resolve, got %+v", changed)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].Metric != "load" {
+		t.Errorf("expected only the load alert to be active, got %+v", active)
+	}
+}
+
+func TestSetThresholdsRestartsPendingLevels(t *testing.T) {
+	e := &Evaluator{states: make(map[string]*state)}
+	thresholds := []Threshold{{Metric: "load", Critical: float(4), Duration: "1m"}}
+	e.SetThresholds(thresholds)
+	metrics := summaries(map[string]float64{"load": 5})
+	now := time.Now()
+
+	e.update(metrics, now)
+	e.SetThresholds(thresholds)
+
+	if changed := e.update(metrics, now.Add(time.Minute)); len(changed) != 0 {
+		t.Fatalf("expected the duration to start over, got %+v", changed)
+	}
+	if changed := e.update(metrics, now.Add(2*time.Minute)); len(changed) != 1 || changed[0].Level != LevelCritical {
+		t.Fatalf("expected critical once held for the duration, got %+v", changed)
+	}
+}
@@ -0,0 +1,87 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backend names that can be set in the gpio config.
+const (
+	BackendGPIOCDev  = "gpiocdev"
+	BackendSimulator = "sim"
+)
+
+// Backend requests GPIO lines. An empty chip means the backend's default chip.
+type Backend interface {
+	// Name returns the name of the backend, as used in the gpio config.
+	Name() string
+	// Chips lists the chips and lines that can be requested.
+	Chips() ([]ChipInfo, error)
+	// DefaultChip is the chip used when a config doesn't name one.
+	DefaultChip() string
+	RequestInput(chip string, pin int) (Line, error)
+	RequestEdges(chip string, pin int, debounce time.Duration, handler func(EdgeEvent)) (Line, error)
+	RequestOutput(chip string, pin int, initial int) (Line, error)
+}
+
+// Line is a requested GPIO line. It is released by Close.
+type Line interface {
+	Value() (int, error)
+	SetValue(value int) error
+	Close() error
+}
+
+// EdgeEvent is a change in the value of a line watched for edges.
+type EdgeEvent struct {
+	Pin    int
+	Rising bool
+	At     time.Time
+}
+
+// Config selects the GPIO backend and the outputs driven by the service.
+// Chip is the chip used by lines that don't name one; by default the chip wired to the
+// Pi's 40-pin header is detected from its label.
+type Config struct {
+	Backend string   `json:"backend,omitempty"`
+	Chip    string   `json:"chip,omitempty"`
+	Outputs []Output `json:"outputs,omitempty"`
+}
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend = newCDevBackend("")
+)
+
+// Configure sets the backend used for lines requested after this call, then requests the outputs.
+func Configure(cfg Config) error {
+	var b Backend
+	switch cfg.Backend {
+	case "", BackendGPIOCDev:
+		b = newCDevBackend(cfg.Chip)
+	case BackendSimulator:
+		if cfg.Chip != "" && cfg.Chip != simChip {
+			return fmt.Errorf("the gpio simulator only has chip %s", simChip)
+		}
+		b = NewSimulator()
+	default:
+		return fmt.Errorf("unknown gpio backend %q", cfg.Backend)
+	}
+
+	SetBackend(b)
+	return SetOutputs(cfg.Outputs)
+}
+
+// SetBackend sets the backend used for lines requested after this call.
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backend = b
+}
+
+// GetBackend returns the backend lines are requested from.
+func GetBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
@@ -0,0 +1,149 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gpiocdev "github.com/warthog618/go-gpiocdev"
+)
+
+const fallbackChip = "gpiochip0"
+
+// headerChipLabels are the labels of the chips wired to the 40-pin header on each Pi model,
+// in order of preference (the Pi 5's RP1 is gpiochip4 on older kernels and gpiochip0 on newer ones).
+var headerChipLabels = []string{"pinctrl-rp1", "pinctrl-bcm2712", "pinctrl-bcm2711", "pinctrl-bcm2835"}
+
+// cdevBackend requests lines from the GPIO character devices.
+type cdevBackend struct {
+	chip string
+
+	detect sync.Once
+}
+
+type cdevLine struct {
+	*gpiocdev.Line
+}
+
+// newCDevBackend returns a backend whose default chip is chip, or the detected header chip if chip is empty.
+func newCDevBackend(chip string) *cdevBackend {
+	return &cdevBackend{chip: chip}
+}
+
+func (b *cdevBackend) Name() string {
+	return BackendGPIOCDev
+}
+
+// DefaultChip returns the configured chip, detecting it by label the first time if it wasn't set.
+func (b *cdevBackend) DefaultChip() string {
+	b.detect.Do(func() {
+		if b.chip == "" {
+			b.chip = detectHeaderChip()
+		}
+	})
+	return b.chip
+}
+
+func (b *cdevBackend) Chips() ([]ChipInfo, error) {
+	names := gpiocdev.Chips()
+	chips := make([]ChipInfo, 0, len(names))
+	for _, name := range names {
+		c, err := gpiocdev.NewChip(name)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", name, err)
+		}
+
+		info := ChipInfo{Name: c.Name, Path: "/dev/" + c.Name, Label: c.Label}
+		for i := 0; i < c.Lines(); i++ {
+			li, err := c.LineInfo(i)
+			if err != nil {
+				c.Close()
+				return nil, fmt.Errorf("read line info %s/%d: %w", name, i, err)
+			}
+
+			line := LineInfo{Offset: li.Offset, Name: li.Name, Consumer: li.Consumer, Used: li.Used}
+			switch li.Config.Direction {
+			case gpiocdev.LineDirectionInput:
+				line.Direction = "input"
+			case gpiocdev.LineDirectionOutput:
+				line.Direction = "output"
+			}
+			info.Lines = append(info.Lines, line)
+		}
+
+		c.Close()
+		chips = append(chips, info)
+	}
+	return chips, nil
+}
+
+func (b *cdevBackend) RequestInput(chip string, pin int) (Line, error) {
+	chip = b.chipOrDefault(chip)
+	l, err := gpiocdev.RequestLine(chip, pin, gpiocdev.AsInput)
+	if err != nil {
+		return nil, fmt.Errorf("request input %s/%d: %w", chip, pin, err)
+	}
+	return cdevLine{l}, nil
+}
+
+// RequestEdges requests the line as input and calls handler on every rising and falling edge.
+// A non-zero debounce period is applied by the kernel, which needs the v2 GPIO uAPI (Linux 5.10+).
+func (b *cdevBackend) RequestEdges(chip string, pin int, debounce time.Duration, handler func(EdgeEvent)) (Line, error) {
+	opts := []gpiocdev.LineReqOption{
+		gpiocdev.AsInput,
+		gpiocdev.WithBothEdges,
+		gpiocdev.WithEventHandler(func(evt gpiocdev.LineEvent) {
+			handler(EdgeEvent{
+				Pin:    evt.Offset,
+				Rising: evt.Type == gpiocdev.LineEventRisingEdge,
+				At:     time.Now(),
+			})
+		}),
+	}
+	if debounce > 0 {
+		opts = append(opts, gpiocdev.WithDebounce(debounce))
+	}
+
+	chip = b.chipOrDefault(chip)
+	l, err := gpiocdev.RequestLine(chip, pin, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("request edges %s/%d: %w", chip, pin, err)
+	}
+	return cdevLine{l}, nil
+}
+
+func (b *cdevBackend) RequestOutput(chip string, pin int, initial int) (Line, error) {
+	chip = b.chipOrDefault(chip)
+	l, err := gpiocdev.RequestLine(chip, pin, gpiocdev.AsOutput(initial))
+	if err != nil {
+		return nil, fmt.Errorf("request output %s/%d: %w", chip, pin, err)
+	}
+	return cdevLine{l}, nil
+}
+
+func (b *cdevBackend) chipOrDefault(chip string) string {
+	if chip == "" {
+		return b.DefaultChip()
+	}
+	return chip
+}
+
+// detectHeaderChip returns the chip wired to the 40-pin header, falling back to gpiochip0.
+func detectHeaderChip() string {
+	labels := make(map[string]string)
+	for _, name := range gpiocdev.Chips() {
+		c, err := gpiocdev.NewChip(name)
+		if err != nil {
+			continue
+		}
+		labels[c.Label] = c.Name
+		c.Close()
+	}
+
+	for _, label := range headerChipLabels {
+		if name, ok := labels[label]; ok {
+			return name
+		}
+	}
+	return fallbackChip
+}
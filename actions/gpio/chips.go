@@ -0,0 +1,167 @@
+package gpio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChipInfo describes a GPIO chip and its lines.
+type ChipInfo struct {
+	Name  string     `json:"name"`
+	Path  string     `json:"path,omitempty"`
+	Label string     `json:"label,omitempty"`
+	Lines []LineInfo `json:"lines"`
+}
+
+// LineInfo describes a single line on a chip.
+type LineInfo struct {
+	Offset    int    `json:"offset"`
+	Name      string `json:"name,omitempty"`
+	Consumer  string `json:"consumer,omitempty"`
+	Used      bool   `json:"used"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// LineID is a resolved line: the name of its chip and its offset on that chip.
+type LineID struct {
+	Chip   string `json:"chip"`
+	Offset int    `json:"offset"`
+}
+
+func (id LineID) String() string {
+	return fmt.Sprintf("%s/%d", id.Chip, id.Offset)
+}
+
+// LineRef is a line given by offset or by name, e.g. 17, "17" or "GPIO17".
+type LineRef string
+
+// UnmarshalJSON accepts a number or a string.
+func (r *LineRef) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err == nil {
+		*r = LineRef(strconv.Itoa(n))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("line must be a number or a name: %w", err)
+	}
+	*r = LineRef(s)
+	return nil
+}
+
+// ResolveLine finds the line a config refers to. chip is a chip name ("gpiochip0"), path
+// ("/dev/gpiochip0") or label ("pinctrl-bcm2711"), defaulting to the backend's default chip.
+// line is an offset or a line name, defaulting to offset. A line name without a chip is
+// looked up on every chip.
+func ResolveLine(chip string, line LineRef, offset int) (LineID, error) {
+	b := GetBackend()
+	chips, err := b.Chips()
+	if err != nil {
+		return LineID{}, fmt.Errorf("unable to list gpio chips: %w", err)
+	}
+	if len(chips) == 0 {
+		return LineID{}, fmt.Errorf("no gpio chips found")
+	}
+
+	name := strings.TrimSpace(string(line))
+	if name != "" {
+		if n, err := strconv.Atoi(name); err == nil {
+			offset, name = n, ""
+		}
+	}
+
+	var c *ChipInfo
+	switch {
+	case chip != "":
+		if c = findChip(chips, chip); c == nil {
+			return LineID{}, fmt.Errorf("gpio chip %q not found, available chips: %s", chip, chipNames(chips))
+		}
+	case name != "":
+		return findLineByName(chips, b.DefaultChip(), name)
+	default:
+		def := b.DefaultChip()
+		if c = findChip(chips, def); c == nil {
+			return LineID{}, fmt.Errorf("default gpio chip %q not found, available chips: %s", def, chipNames(chips))
+		}
+	}
+
+	if name != "" {
+		for _, l := range c.Lines {
+			if l.Name == name {
+				return LineID{Chip: c.Name, Offset: l.Offset}, nil
+			}
+		}
+		return LineID{}, fmt.Errorf("line %q not found on gpio chip %s", name, describeChip(c))
+	}
+
+	if offset < 0 || offset >= len(c.Lines) {
+		return LineID{}, fmt.Errorf("line %d is out of range for gpio chip %s, which has %d lines", offset, describeChip(c), len(c.Lines))
+	}
+	return LineID{Chip: c.Name, Offset: offset}, nil
+}
+
+// Chips returns the chips (and their lines) available on the configured backend.
+func Chips() ([]ChipInfo, error) {
+	return GetBackend().Chips()
+}
+
+// DefaultChip returns the chip used by the configured backend when a config doesn't name one.
+func DefaultChip() string {
+	return GetBackend().DefaultChip()
+}
+
+// findLineByName looks for a line name on every chip, preferring def if more than one chip has it.
+func findLineByName(chips []ChipInfo, def, name string) (LineID, error) {
+	var found []LineID
+	for i := range chips {
+		for _, l := range chips[i].Lines {
+			if l.Name == name {
+				found = append(found, LineID{Chip: chips[i].Name, Offset: l.Offset})
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return LineID{}, fmt.Errorf("line %q not found on any gpio chip (%s)", name, chipNames(chips))
+	case 1:
+		return found[0], nil
+	}
+
+	names := make([]string, len(found))
+	for i, id := range found {
+		if id.Chip == def {
+			return id, nil
+		}
+		names[i] = id.String()
+	}
+	return LineID{}, fmt.Errorf("line %q is on more than one gpio chip (%s), set a chip", name, strings.Join(names, ", "))
+}
+
+func findChip(chips []ChipInfo, chip string) *ChipInfo {
+	for i := range chips {
+		if chips[i].Name == chip || chips[i].Path == chip || chips[i].Label == chip {
+			return &chips[i]
+		}
+	}
+	return nil
+}
+
+func describeChip(c *ChipInfo) string {
+	if c.Label == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Label)
+}
+
+func chipNames(chips []ChipInfo) string {
+	names := make([]string, len(chips))
+	for i := range chips {
+		names[i] = describeChip(&chips[i])
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,67 @@
+package gpio
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResolveLine(t *testing.T) {
+	SetBackend(NewSimulator())
+	t.Cleanup(func() {
+		SetBackend(newCDevBackend(""))
+	})
+
+	tests := []struct {
+		config string
+		want   LineID
+		err    string
+	}{
+		{config: `{"pin": 17}`, want: LineID{Chip: simChip, Offset: 17}},
+		{config: `{"line": 22}`, want: LineID{Chip: simChip, Offset: 22}},
+		{config: `{"line": "GPIO5"}`, want: LineID{Chip: simChip, Offset: 5}},
+		{config: `{"chip": "gpio-sim", "line": "GPIO6"}`, want: LineID{Chip: simChip, Offset: 6}},
+		{config: `{"chip": "gpiochip4", "pin": 17}`, err: `gpio chip "gpiochip4" not found`},
+		{config: `{"line": "GPIO99"}`, err: `line "GPIO99" not found on any gpio chip`},
+		{config: `{"chip": "gpiochip0", "line": "SCL"}`, err: `line "SCL" not found on gpio chip gpiochip0 (gpio-sim)`},
+		{config: `{"pin": 64}`, err: "line 64 is out of range"},
+	}
+
+	for _, tt := range tests {
+		var pin Pin
+		if err := json.Unmarshal([]byte(tt.config), &pin); err != nil {
+			t.Fatalf("%s: %s", tt.config, err)
+		}
+
+		id, err := ResolveLine(pin.Chip, pin.Line, pin.Pin)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: expected error %q, got %v", tt.config, tt.err, err)
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %s", tt.config, err)
+		case tt.err == "" && id != tt.want:
+			t.Errorf("%s: expected %s, got %s", tt.config, tt.want, id)
+		}
+	}
+}
+
+func TestReloadRejectsUnresolvedLines(t *testing.T) {
+	SetBackend(NewSimulator())
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+
+	err := Reload([]Pin{{Pin: 3}, {Line: "nope"}, {Line: "GPIO3"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{`line "nope" not found`, "gpiochip0/3 is configured more than once"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+	if len(Statuses()) != 0 {
+		t.Errorf("expected no monitors to start: %+v", Statuses())
+	}
+}
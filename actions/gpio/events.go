@@ -0,0 +1,209 @@
+package gpio
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+	"github.com/byuoitav/device-monitoring/localsystem"
+	"github.com/byuoitav/device-monitoring/messenger"
+	"github.com/byuoitav/device-monitoring/model"
+	"github.com/rs/zerolog/log"
+)
+
+const dividerStateKey = "divider-state"
+
+// DividerState is the state of a divider sensor, sent as the data of roomdivide events.
+type DividerState struct {
+	Pin       int               `json:"pin"`
+	Chip      string            `json:"chip,omitempty"`
+	Connected bool              `json:"connected"`
+	Presets   string            `json:"presets,omitempty"`
+	Preset    map[string]string `json:"preset,omitempty"`
+	RoomID    string            `json:"room-id,omitempty"`
+	SystemID  string            `json:"system-id,omitempty"`
+	// Configuration is the room configuration the dividers match, if there's a topology
+	Configuration string `json:"configuration,omitempty"`
+	TrueUp        bool   `json:"true-up,omitempty"`
+}
+
+// savedState is what's persisted for each pin so a restart picks up where it left off.
+type savedState struct {
+	Connected bool      `json:"connected"`
+	ChangedAt time.Time `json:"changed-at"`
+}
+
+var savedMu sync.Mutex
+
+// sendDividerEvent sends a roomdivide event with the current state of p.
+func sendDividerEvent(p *Pin, trueUp bool) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		log.Warn().Err(err).Msgf("unable to send divider event for pin %d", p.Pin)
+		return
+	}
+	roomID, _ := localsystem.RoomID()
+
+	mu.RLock()
+	state := DividerState{
+		Pin:       p.Pin,
+		Chip:      p.id.Chip,
+		Connected: p.Connected,
+		Presets:   p.BlueberryPresets,
+		Preset:    p.Presets.Disconnected,
+		RoomID:    roomID,
+		SystemID:  systemID,
+		TrueUp:    trueUp,
+	}
+	mu.RUnlock()
+	if state.Connected {
+		state.Preset = p.Presets.Connected
+	}
+	state.Configuration = CurrentStatus().Configuration
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	event := model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        time.Now(),
+		EventTags: []string{
+			model.RoomDivide,
+			model.DetailState,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          fmt.Sprintf("divider-%d", p.Pin),
+		Value:        "disconnected",
+		Data:         state,
+	}
+	if state.Connected {
+		event.Value = "connected"
+	}
+	if trueUp {
+		event.AddToTags(model.AutoGenerated)
+	}
+
+	messenger.Get().SendEvent(model.ToCommonEvent(event))
+}
+
+// FlapState is the data of the alert sent when a divider sensor starts or stops flapping.
+type FlapState struct {
+	Pin         int    `json:"pin"`
+	Chip        string `json:"chip,omitempty"`
+	Sensor      string `json:"sensor"`
+	Flapping    bool   `json:"flapping"`
+	Transitions int    `json:"transitions,omitempty"`
+	Window      string `json:"window"`
+	Held        bool   `json:"held,omitempty"`
+}
+
+// sendFlapEvent sends an alert when a divider sensor starts flapping, and a resolved alert when it settles.
+func sendFlapEvent(p *Pin, flapping bool, transitions int, window time.Duration, held bool) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		log.Warn().Err(err).Msgf("unable to send flapping alert for pin %d", p.Pin)
+		return
+	}
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	event := model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        time.Now(),
+		EventTags: []string{
+			model.RoomDivide,
+			model.Alert,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          fmt.Sprintf("divider-%d-flapping", p.Pin),
+		Value:        "resolved",
+		Data: FlapState{
+			Pin:         p.Pin,
+			Chip:        p.id.Chip,
+			Sensor:      p.SensorName(),
+			Flapping:    flapping,
+			Transitions: transitions,
+			Window:      window.String(),
+			Held:        held,
+		},
+	}
+	if flapping {
+		event.Value = "flapping"
+	}
+
+	messenger.Get().SendEvent(model.ToCommonEvent(event))
+}
+
+// sendOutputEvent sends an event with the new state of an output.
+func sendOutputEvent(state OutputState) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		log.Warn().Err(err).Msgf("unable to send event for output %s", state.Name)
+		return
+	}
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	event := model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        state.ChangedAt,
+		EventTags: []string{
+			model.DetailState,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          fmt.Sprintf("gpio-output-%s", state.Name),
+		Value:        CommandOff,
+		Data:         state,
+	}
+	if state.On {
+		event.Value = CommandOn
+	}
+
+	messenger.Get().SendEvent(model.ToCommonEvent(event))
+}
+
+// loadSavedStates returns the last persisted state of each line, keyed by "<chip>/<offset>".
+func loadSavedStates() (map[string]savedState, error) {
+	states := make(map[string]savedState)
+
+	b, nerr := dmdb.Get(dividerStateKey)
+	if nerr != nil {
+		return states, fmt.Errorf("failed to load divider state: %w", nerr)
+	}
+	if len(b) == 0 {
+		return states, nil
+	}
+
+	if err := json.Unmarshal(b, &states); err != nil {
+		return states, fmt.Errorf("failed to parse divider state: %w", err)
+	}
+	return states, nil
+}
+
+// saveState persists the state of a line. Nothing is saved if the stored states can't be read,
+// so the other lines' states aren't lost.
+func saveState(id LineID, connected bool) {
+	savedMu.Lock()
+	defer savedMu.Unlock()
+
+	states, err := loadSavedStates()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to save divider state")
+		return
+	}
+
+	if s, ok := states[id.String()]; ok && s.Connected == connected {
+		return
+	}
+	states[id.String()] = savedState{Connected: connected, ChangedAt: time.Now()}
+
+	b, err := json.Marshal(states)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to save divider state")
+		return
+	}
+	if nerr := dmdb.Put(dividerStateKey, b); nerr != nil {
+		log.Warn().Err(nerr).Msg("failed to save divider state")
+	}
+}
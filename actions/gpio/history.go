@@ -0,0 +1,245 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sources of a transition.
+const (
+	SourceEdge    = "edge"
+	SourcePoll    = "poll"
+	SourceTrueUp  = "true-up"
+	SourceRestore = "restore"
+)
+
+const (
+	maxHistory = 500
+
+	defaultFlapTransitions = 6
+	defaultFlapWindow      = 2 * time.Minute
+)
+
+// FlapConfig sets when a sensor is considered to be flapping: Transitions changes within Window.
+// It's considered settled once it goes Settle (defaulting to Window) without a change. With Hold,
+// changes while it's flapping are recorded but not acted on, and the state from before it started
+// flapping is kept until it settles.
+type FlapConfig struct {
+	Disabled    bool   `json:"disabled,omitempty"`
+	Transitions int    `json:"transitions,omitempty"`
+	Window      string `json:"window,omitempty"`
+	Settle      string `json:"settle,omitempty"`
+	Hold        bool   `json:"hold,omitempty"`
+}
+
+// Transition is a change in the state read from a divider sensor. The counts are of what happened
+// since the previous transition: every read of the line, the reads that disagreed with the state
+// at the time (bounces that didn't last), and the edge events reported by the kernel.
+type Transition struct {
+	Pin        int       `json:"pin"`
+	Chip       string    `json:"chip,omitempty"`
+	Sensor     string    `json:"sensor"`
+	Connected  bool      `json:"connected"`
+	At         time.Time `json:"at"`
+	Source     string    `json:"source"`
+	Reads      int       `json:"reads"`
+	Mismatches int       `json:"mismatches"`
+	Edges      int       `json:"edges"`
+	Flapping   bool      `json:"flapping,omitempty"`
+	Held       bool      `json:"held,omitempty"`
+}
+
+var (
+	historyMu sync.Mutex
+	history   []Transition
+)
+
+// History returns the most recent divider transitions, newest first.
+func History() []Transition {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	ret := make([]Transition, len(history))
+	for i := range history {
+		ret[i] = history[len(history)-1-i]
+	}
+	return ret
+}
+
+func recordTransition(t Transition) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	history = append(history, t)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+}
+
+// validate makes sure the flap settings parse.
+func (c *FlapConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Transitions < 0 {
+		return fmt.Errorf("invalid flap transitions %d", c.Transitions)
+	}
+	for name, d := range map[string]string{"window": c.Window, "settle": c.Settle} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("invalid flap %s %q", name, d)
+		}
+	}
+	return nil
+}
+
+// tracker follows the raw state of a pin for its monitor: it counts reads, records transitions,
+// and decides whether a transition is applied or held while the sensor is flapping.
+type tracker struct {
+	pin *Pin
+
+	disabled    bool
+	transitions int
+	window      time.Duration
+	settle      time.Duration
+	hold        bool
+
+	// raw is the last state read, which differs from the pin's state while one is held
+	raw                     bool
+	reads, mismatches, edge int
+
+	recent   []change
+	flapping bool
+	stable   bool
+	timer    *time.Timer
+}
+
+type change struct {
+	at   time.Time
+	from bool
+}
+
+func newTracker(p *Pin) *tracker {
+	t := &tracker{pin: p, transitions: defaultFlapTransitions, window: defaultFlapWindow}
+	if c := p.Flap; c != nil {
+		t.disabled = c.Disabled
+		t.hold = c.Hold
+		if c.Transitions > 0 {
+			t.transitions = c.Transitions
+		}
+		if d, err := time.ParseDuration(c.Window); err == nil && d > 0 {
+			t.window = d
+		}
+		if d, err := time.ParseDuration(c.Settle); err == nil && d > 0 {
+			t.settle = d
+		}
+	}
+	if t.settle == 0 {
+		t.settle = t.window
+	}
+
+	t.timer = time.NewTimer(t.settle)
+	t.timer.Stop()
+	return t
+}
+
+// read counts a read of the line.
+func (t *tracker) read(connected bool) {
+	t.reads++
+	if connected != t.raw {
+		t.mismatches++
+	}
+}
+
+// edgeEvent counts an edge event.
+func (t *tracker) edgeEvent() {
+	t.edge++
+}
+
+// settled fires once a flapping sensor has gone the settle period without a change.
+func (t *tracker) settled() <-chan time.Time {
+	return t.timer.C
+}
+
+// change records a transition of the raw state and applies it to the pin, unless it's held.
+func (t *tracker) change(ctx context.Context, connected bool, source string) {
+	if connected == t.raw {
+		return
+	}
+
+	now := time.Now()
+	from := t.raw
+	t.raw = connected
+
+	rec := Transition{
+		Pin:        t.pin.Pin,
+		Chip:       t.pin.id.Chip,
+		Sensor:     t.pin.SensorName(),
+		Connected:  connected,
+		At:         now,
+		Source:     source,
+		Reads:      t.reads,
+		Mismatches: t.mismatches,
+		Edges:      t.edge,
+	}
+	t.reads, t.mismatches, t.edge = 0, 0, 0
+
+	if !t.disabled {
+		// forget changes that are outside the window
+		i := 0
+		for i < len(t.recent) && now.Sub(t.recent[i].at) > t.window {
+			i++
+		}
+		t.recent = append(t.recent[i:], change{at: now, from: from})
+
+		if !t.flapping && len(t.recent) >= t.transitions {
+			t.flapping = true
+			t.stable = t.recent[0].from
+			log.Warn().Msgf("pin %d is flapping: %d changes in %v", t.pin.Pin, len(t.recent), t.window)
+			t.pin.setStatus(func(s *MonitorStatus) { s.Flapping = true })
+			go sendFlapEvent(t.pin, true, len(t.recent), t.window, t.hold)
+		}
+		if t.flapping {
+			t.timer.Reset(t.settle)
+		}
+	}
+
+	rec.Flapping = t.flapping
+	rec.Held = t.flapping && t.hold
+	recordTransition(rec)
+
+	if rec.Held {
+		// keep the state from before the flapping started
+		t.pin.setConnected(ctx, t.stable)
+		return
+	}
+	t.pin.setConnected(ctx, connected)
+}
+
+// clearFlapping clears flapping once the sensor has settled, applying the raw state if one was held.
+func (t *tracker) clearFlapping(ctx context.Context) {
+	if !t.flapping {
+		return
+	}
+
+	t.flapping = false
+	t.recent = nil
+	log.Info().Msgf("pin %d has settled", t.pin.Pin)
+	t.pin.setStatus(func(s *MonitorStatus) { s.Flapping = false })
+	go sendFlapEvent(t.pin, false, 0, t.window, t.hold)
+
+	if t.hold {
+		t.pin.setConnected(ctx, t.raw)
+	}
+}
+
+// stop releases the settle timer.
+func (t *tracker) stop() {
+	t.timer.Stop()
+}
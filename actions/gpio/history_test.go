@@ -0,0 +1,74 @@
+package gpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlapHold(t *testing.T) {
+	sim := NewSimulator()
+	SetBackend(sim)
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+
+	// start from a clean slate when run more than once
+	historyMu.Lock()
+	history = nil
+	historyMu.Unlock()
+	saveState(LineID{Chip: simChip, Offset: 30}, false)
+
+	pin := Pin{
+		Pin:               30,
+		Mode:              ModePoll,
+		ReadFrequency:     "2ms",
+		ReadsBeforeChange: 1,
+		Flap:              &FlapConfig{Transitions: 3, Window: "1m", Settle: "100ms", Hold: true},
+	}
+	if err := Reload([]Pin{pin}); err != nil {
+		t.Fatalf("failed to start monitor: %s", err)
+	}
+
+	wait := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s: %+v", what, Statuses())
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	status := func() MonitorStatus { return Statuses()[0] }
+	transitions := func() int {
+		n := 0
+		for _, tr := range History() {
+			if tr.Pin == 30 {
+				n++
+			}
+		}
+		return n
+	}
+
+	wait("the monitor to start", func() bool { return status().State == StateRunning })
+
+	// two changes are applied, the third is a flap and the state goes back to where it started
+	for i, v := range []int{HIGH, LOW, HIGH} {
+		sim.Set(30, v)
+		wait("the change to be read", func() bool { return transitions() == i+1 })
+	}
+
+	if s := status(); !s.Flapping || s.Connected {
+		t.Errorf("expected pin 30 to be flapping and held disconnected: %+v", s)
+	}
+	if last := History()[0]; !last.Held || !last.Connected || last.Reads == 0 {
+		t.Errorf("expected the last transition to be held with its reads counted: %+v", last)
+	}
+
+	// once it settles the held state is released
+	wait("the sensor to settle", func() bool { return !status().Flapping })
+	if s := status(); !s.Connected {
+		t.Errorf("expected pin 30 to be connected once settled: %+v", s)
+	}
+}
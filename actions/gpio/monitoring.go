@@ -1,21 +1,30 @@
 package gpio
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"text/template"
+	"context"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
-// Pin represents a GPIO pin configuration.
+const (
+	// ModeEdge watches a pin for edge events.
+	ModeEdge = "edge"
+	// ModePoll reads a pin on a ticker.
+	ModePoll = "poll"
+
+	defaultDebounce = 50 * time.Millisecond
+)
+
+// Pin represents a GPIO pin configuration. The line is Line (an offset or a line name such as
+// "GPIO17") if it's set and Pin otherwise, on Chip (a name, path or label) or the default chip.
+// Once the line is resolved Pin is its offset. Name identifies the sensor in a Topology.
 type Pin struct {
-	Pin  int  `json:"pin"`
-	Flip bool `json:"flip"`
+	Name string  `json:"name,omitempty"`
+	Pin  int     `json:"pin"`
+	Chip string  `json:"chip,omitempty"`
+	Line LineRef `json:"line,omitempty"`
+	Flip bool    `json:"flip"`
 
 	BlueberryPresets string `json:"blueberry_presets"`
 	Presets          struct {
@@ -23,30 +32,38 @@ type Pin struct {
 		Disconnected map[string]string `json:"disconnected"`
 	} `json:"presets"`
 
+	// Mode is "edge" (the default) or "poll". Debounce is the kernel debounce period used in
+	// edge mode; ReadFrequency and ReadsBeforeChange are only used when polling.
+	Mode              string `json:"mode,omitempty"`
+	Debounce          string `json:"debounce,omitempty"`
 	ReadFrequency     string `json:"read-frequency"`
 	ReadsBeforeChange int    `json:"reads-before-change"`
 	TrueUpFrequency   string `json:"true-up-frequency"`
 
+	// Flap sets when the sensor is considered to be flapping; by default 6 changes in 2 minutes.
+	Flap *FlapConfig `json:"flap,omitempty"`
+
 	ChangeRequests []request `json:"change"`
 	TrueUpRequests []request `json:"true-up"`
 
 	Connected bool `json:"connected"`
-}
 
-type request struct {
-	Method string      `json:"method"`
-	URL    string      `json:"url"`
-	Body   interface{} `json:"body"`
+	monitor *monitor
+	// id is the resolved line
+	id LineID
+	// restored is set when Connected was loaded from the state saved before a restart
+	restored bool
+	// cancel the requests sent for the last change and true-up; only used by the monitor,
+	// but set under mu since GetPins copies them
+	cancelChange context.CancelFunc
+	cancelTrueUp context.CancelFunc
 }
 
-// Monitor starts monitoring the signal on a pin
-func (p *Pin) Monitor() {
-	gpio := NewGPIO(p.Pin)
-	if err := gpio.OpenInput(); err != nil {
-		log.Warn().Err(err).Msgf("open gpio line %d", p.Pin)
-	}
-	defer gpio.Close()
-
+// Monitor starts monitoring the signal on a pin.
+// By default the line is watched for edge events, debounced by the kernel; if that
+// isn't available (or Mode is "poll") it falls back to reading the line every ReadFrequency.
+// It runs until ctx is cancelled, then releases the line.
+func (p *Pin) Monitor(ctx context.Context) {
 	rd, err := time.ParseDuration(p.ReadFrequency)
 	if err != nil {
 		rd = 200 * time.Millisecond
@@ -55,127 +72,190 @@ func (p *Pin) Monitor() {
 	if err != nil {
 		td = 5 * time.Minute
 	}
+	debounce, err := time.ParseDuration(p.Debounce)
+	if err != nil {
+		debounce = defaultDebounce
+	}
+
+	gpio := NewLineGPIO(p.id)
+	defer gpio.Close()
 
-	// Initial read
-	if val, err := gpio.Read(); err == nil {
-		connected := val == 1
-		if p.Flip {
-			connected = !connected
+	t := newTracker(p)
+	defer t.stop()
+
+	// coalesce bursts of edges into a single pending re-read
+	edges := make(chan struct{}, 1)
+	edgeMode := false
+	if p.Mode != ModePoll {
+		err := gpio.OpenEdges(debounce, func(EdgeEvent) {
+			select {
+			case edges <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			log.Warn().Err(err).Msgf("edge events unavailable on pin %d, polling every %v", p.Pin, rd)
+		} else {
+			edgeMode = true
 		}
-		mu.Lock()
-		p.Connected = connected
-		mu.Unlock()
 	}
 
-	newStateCount := 0
-	readTick := time.NewTicker(rd)
+	mode := ModeEdge
+	if !edgeMode {
+		mode = ModePoll
+		if err := gpio.OpenInput(); err != nil {
+			log.Warn().Err(err).Msgf("open gpio line %d", p.Pin)
+		}
+	}
+	p.setStatus(func(s *MonitorStatus) { s.Mode = mode })
+
+	// Initial read; the monitor reports itself running (or failed) once this is done.
+	// If the state was restored from before a restart, a difference is a change that
+	// happened while nothing was watching.
+	mu.RLock()
+	t.raw = p.Connected
+	mu.RUnlock()
+	if connected, err := p.read(gpio); err == nil {
+		if p.restored {
+			t.change(ctx, connected, SourceRestore)
+		} else {
+			t.raw = connected
+			mu.Lock()
+			p.Connected = connected
+			mu.Unlock()
+			saveState(p.id, connected)
+		}
+	}
+
+	var readTick <-chan time.Time
+	if edgeMode {
+		log.Info().Msgf("Watching pin %d for edges (debounce %v)", p.Pin, debounce)
+	} else {
+		log.Info().Msgf("Monitoring pin %d every %v", p.Pin, rd)
+		t := time.NewTicker(rd)
+		defer t.Stop()
+		readTick = t.C
+	}
+
 	trueUpTick := time.NewTicker(td)
+	defer trueUpTick.Stop()
+
+	newStateCount := 0
 
 	// Monitor loop
 	for {
 		select {
-		case <-readTick.C:
-			val, err := gpio.Read()
+		case <-ctx.Done():
+			log.Info().Msgf("Stopped monitoring pin %d", p.Pin)
+			return
+
+		case <-edges:
+			// the kernel has already debounced the line, so the current value is settled
+			t.edgeEvent()
+			connected, err := p.read(gpio)
 			if err != nil {
 				log.Warn().Err(err).Msgf("read pin %d", p.Pin)
 				continue
 			}
+			t.read(connected)
+			t.change(ctx, connected, SourceEdge)
 
-			connected := val == 1
-			if p.Flip {
-				connected = !connected
+		case <-readTick:
+			connected, err := p.read(gpio)
+			if err != nil {
+				log.Warn().Err(err).Msgf("read pin %d", p.Pin)
+				continue
 			}
+			t.read(connected)
 
-			mu.RLock()
-			cur := p.Connected
-			mu.RUnlock()
-
-			if connected != cur {
-				newStateCount++
-				if newStateCount >= p.ReadsBeforeChange {
-					newStateCount = 0
-					mu.Lock()
-					p.Connected = connected
-					mu.Unlock()
-
-					log.Info().Msgf("pin %d state changed -> %v", p.Pin, connected)
-					for i := range p.ChangeRequests {
-						go p.ChangeRequests[i].execute(p)
-					}
-				}
+			// ReadsBeforeChange is counted over consecutive reads; one matching read starts over
+			if connected == t.raw {
+				newStateCount = 0
+				continue
+			}
+
+			newStateCount++
+			if newStateCount >= p.ReadsBeforeChange {
+				newStateCount = 0
+				t.change(ctx, connected, SourcePoll)
 			}
-			// NOTE: parity with old behavior — we do NOT reset newStateCount when equal
+
+		case <-t.settled():
+			t.clearFlapping(ctx)
 
 		case <-trueUpTick.C:
-			for i := range p.TrueUpRequests {
-				go p.TrueUpRequests[i].execute(p)
+			// re-read in case an edge was missed
+			if edgeMode {
+				if connected, err := p.read(gpio); err == nil {
+					t.read(connected)
+					t.change(ctx, connected, SourceTrueUp)
+				}
+			}
+
+			// a true-up still running from last time is stale
+			if p.cancelTrueUp != nil {
+				p.cancelTrueUp()
 			}
+			cancel := p.runRequests(ctx, RequestTrueUp, p.TrueUpRequests)
+			mu.Lock()
+			p.cancelTrueUp = cancel
+			mu.Unlock()
+			sendDividerEvent(p, true)
 		}
 	}
 }
 
-func (r *request) execute(pin *Pin) {
-	url, err := pin.fillTemplate(r.URL)
+// read returns whether the pin is connected, taking Flip into account.
+func (p *Pin) read(gpio *GPIO) (bool, error) {
+	val, err := gpio.Read()
 	if err != nil {
-		log.Warn().Msgf("Failed to execute request against %s: %s", r.URL, err)
-		return
+		p.setStatus(func(s *MonitorStatus) {
+			s.State = StateFailed
+			s.Error = err.Error()
+		})
+		return false, err
 	}
 
-	log.Debug().Msgf("Building %s request against %s", r.Method, url)
-	body := ""
+	p.setStatus(func(s *MonitorStatus) {
+		s.State = StateRunning
+		s.Error = ""
+	})
 
-	if r.Body != nil {
-		// Marshal to JSON first, then allow {{.Field}} and pin methods in the JSON string.
-		raw, err := json.Marshal(r.Body)
-		if err != nil {
-			log.Warn().Msgf("Unable to execute request against %s: %s", url, err)
-			return
-		}
-		body, err = pin.fillTemplate(string(raw))
-		if err != nil {
-			log.Warn().Msgf("Unable to execute request against %s: %s", url, err)
-			return
-		}
-		log.Debug().Msgf("Request body for %s: %s", url, body)
+	connected := val == 1
+	if p.Flip {
+		connected = !connected
 	}
+	return connected, nil
+}
 
-	req, err := http.NewRequest(r.Method, url, bytes.NewReader([]byte(body)))
-	if err != nil {
-		log.Warn().Msgf("Unable to execute request against %s: %s", url, err)
+// setConnected updates the pin's state, sending its change requests if the state changed.
+// Requests still running for the previous state are cancelled.
+func (p *Pin) setConnected(ctx context.Context, connected bool) {
+	mu.Lock()
+	if p.Connected == connected {
+		mu.Unlock()
 		return
 	}
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	log.Info().Msgf("Sending %s request to %s", r.Method, url)
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Warn().Msgf("Unable to execute request against %s: %s", url, err)
-		return
+	p.Connected = connected
+	if p.monitor != nil {
+		now := time.Now()
+		p.monitor.status.LastChange = &now
 	}
-	defer resp.Body.Close()
+	mu.Unlock()
 
-	reply, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Warn().Msgf("Unable to read response from %s: %s", url, err)
-		return
+	log.Info().Msgf("pin %d state changed -> %v", p.Pin, connected)
+	saveState(p.id, connected)
+	if p.cancelChange != nil {
+		p.cancelChange()
 	}
-	log.Info().Msgf("Response from %s: %s", url, reply)
-}
-
-func (p *Pin) fillTemplate(source string) (string, error) {
-	t, err := template.New("pin").Parse(source)
-	if err != nil {
-		return "", fmt.Errorf("unable to fill template: %s", err)
+	if p.cancelTrueUp != nil {
+		p.cancelTrueUp()
 	}
-
-	buf := &bytes.Buffer{}
-	err = t.Execute(buf, *p)
-	if err != nil {
-		return "", fmt.Errorf("unable to fill template: %s", err)
-	}
-
-	return buf.String(), nil
+	cancel := p.runRequests(ctx, RequestChange, p.ChangeRequests)
+	mu.Lock()
+	p.cancelChange = cancel
+	mu.Unlock()
+	sendDividerEvent(p, false)
 }
 
 func (p Pin) CurrentPreset(hostname string) string {
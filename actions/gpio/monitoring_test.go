@@ -0,0 +1,186 @@
+package gpio
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+)
+
+func TestMain(m *testing.M) {
+	// keep persisted divider state out of the source tree
+	dir, err := os.MkdirTemp("", "gpio-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("DMDB_PATH", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type recordedRequest struct {
+	method, path, body string
+}
+
+func newRecorder(t *testing.T) (*httptest.Server, chan recordedRequest) {
+	t.Helper()
+
+	reqs := make(chan recordedRequest, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- recordedRequest{method: r.Method, path: r.URL.Path, body: string(body)}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, reqs
+}
+
+func waitForRequest(t *testing.T, reqs chan recordedRequest, path string) recordedRequest {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case r := <-reqs:
+			if r.path == path {
+				return r
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for a request to %s", path)
+		}
+	}
+}
+
+func TestMonitorSimulated(t *testing.T) {
+	for _, mode := range []string{ModeEdge, ModePoll} {
+		t.Run(mode, func(t *testing.T) {
+			sim := NewSimulator()
+			SetBackend(sim)
+			t.Cleanup(func() {
+				Shutdown()
+				SetBackend(newCDevBackend(""))
+			})
+
+			srv, reqs := newRecorder(t)
+
+			pin := Pin{
+				Pin:               24,
+				Mode:              mode,
+				ReadFrequency:     "5ms",
+				ReadsBeforeChange: 2,
+				TrueUpFrequency:   "100ms",
+				ChangeRequests: []request{{
+					Method: http.MethodPut,
+					URL:    srv.URL + "/change/{{.Pin}}",
+					Body:   map[string]string{"connected": "{{.Connected}}"},
+				}},
+				TrueUpRequests: []request{{
+					Method: http.MethodPut,
+					URL:    srv.URL + "/true-up/{{.Pin}}",
+					Body:   map[string]string{"connected": "{{.Connected}}"},
+				}},
+			}
+
+			if err := Reload([]Pin{pin}); err != nil {
+				t.Fatalf("failed to start monitor: %s", err)
+			}
+
+			// wait for the initial read, so the change isn't mistaken for the initial state
+			for deadline := time.Now().Add(2 * time.Second); Statuses()[0].State != StateRunning; {
+				if time.Now().After(deadline) {
+					t.Fatalf("monitor didn't start: %+v", Statuses())
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			sim.Set(24, HIGH)
+			r := waitForRequest(t, reqs, "/change/24")
+			if r.method != http.MethodPut || r.body != `{"connected":"true"}` {
+				t.Errorf("unexpected change request: %+v", r)
+			}
+
+			if pins := GetPins(); len(pins) != 1 || !pins[0].Connected {
+				t.Errorf("expected pin 24 to be connected: %+v", pins)
+			}
+
+			status := Statuses()
+			if len(status) != 1 || status[0].Mode != mode || status[0].State != StateRunning || status[0].LastChange == nil {
+				t.Errorf("unexpected status: %+v", status)
+			}
+
+			r = waitForRequest(t, reqs, "/true-up/24")
+			if r.body != `{"connected":"true"}` {
+				t.Errorf("unexpected true-up request: %+v", r)
+			}
+
+			sim.Set(24, LOW)
+			r = waitForRequest(t, reqs, "/change/24")
+			if r.body != `{"connected":"false"}` {
+				t.Errorf("unexpected change request: %+v", r)
+			}
+		})
+	}
+}
+
+func TestMonitorRestoresState(t *testing.T) {
+	sim := NewSimulator()
+	SetBackend(sim)
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+
+	srv, reqs := newRecorder(t)
+
+	// the divider was connected when we went down, and opened while we were down
+	saveState(LineID{Chip: simChip, Offset: 25}, true)
+
+	pin := Pin{
+		Pin: 25,
+		ChangeRequests: []request{{
+			Method: http.MethodPut,
+			URL:    srv.URL + "/change/{{.Pin}}",
+			Body:   map[string]string{"connected": "{{.Connected}}"},
+		}},
+	}
+	if err := Reload([]Pin{pin}); err != nil {
+		t.Fatalf("failed to start monitor: %s", err)
+	}
+
+	r := waitForRequest(t, reqs, "/change/25")
+	if r.body != `{"connected":"false"}` {
+		t.Errorf("unexpected change request: %+v", r)
+	}
+
+	states, err := loadSavedStates()
+	if err != nil {
+		t.Fatalf("failed to load saved state: %s", err)
+	}
+	if s, ok := states["gpiochip0/25"]; !ok || s.Connected {
+		t.Errorf("expected pin 25 to be saved as disconnected: %+v", states)
+	}
+}
+
+func TestSaveStateKeepsUnreadableState(t *testing.T) {
+	corrupt := []byte(`{"gpiochip0/25": {"connected": `)
+	if err := dmdb.Put(dividerStateKey, corrupt); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	t.Cleanup(func() { dmdb.Put(dividerStateKey, nil) })
+
+	saveState(LineID{Chip: simChip, Offset: 26}, true)
+
+	b, err := dmdb.Get(dividerStateKey)
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	if string(b) != string(corrupt) {
+		t.Errorf("expected the stored state to be left alone, got %q", b)
+	}
+}
@@ -0,0 +1,260 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Output commands.
+const (
+	CommandOn    = "on"
+	CommandOff   = "off"
+	CommandPulse = "pulse"
+)
+
+const defaultPulse = 500 * time.Millisecond
+
+var (
+	// ErrUnknownOutput is returned when a command is sent to an output that isn't configured.
+	ErrUnknownOutput = errors.New("unknown output")
+	// ErrInvalidCommand is returned for commands other than on, off and pulse.
+	ErrInvalidCommand = errors.New("invalid command")
+)
+
+// Output is a line driven by the service, e.g. a screen lift relay or an indicator LED.
+// The line is chosen the same way as a Pin's: Line (an offset or name) or Pin, on Chip or the default chip.
+// Default is the safe state ("on" or "off", defaulting to off) the line is set to when it's
+// requested and when the service shuts down. Pulse is how long a pulse lasts unless a command
+// says otherwise.
+type Output struct {
+	Name      string  `json:"name"`
+	Pin       int     `json:"pin"`
+	Chip      string  `json:"chip,omitempty"`
+	Line      LineRef `json:"line,omitempty"`
+	Default   string  `json:"default,omitempty"`
+	ActiveLow bool    `json:"active-low,omitempty"`
+	Pulse     string  `json:"pulse,omitempty"`
+}
+
+// OutputState is the current state of an output.
+type OutputState struct {
+	Name      string    `json:"name"`
+	Pin       int       `json:"pin"`
+	Chip      string    `json:"chip"`
+	On        bool      `json:"on"`
+	Pulsing   bool      `json:"pulsing,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	ChangedAt time.Time `json:"changed-at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type output struct {
+	mu     sync.Mutex
+	cfg    Output
+	pulse  time.Duration
+	line   *GPIO
+	state  OutputState
+	timer  *time.Timer
+	closed bool
+}
+
+var (
+	outputsMu sync.RWMutex
+	outputs   = make(map[string]*output)
+)
+
+// SetOutputs releases any outputs that are configured (setting them to their default state first)
+// and requests the given outputs, setting each one to its default state.
+func SetOutputs(cfgs []Output) error {
+	names := make(map[string]bool, len(cfgs))
+	lines := make(map[LineID]bool, len(cfgs))
+	ids := make([]LineID, len(cfgs))
+	pulses := make([]time.Duration, len(cfgs))
+	for i, cfg := range cfgs {
+		switch {
+		case cfg.Name == "":
+			return fmt.Errorf("output %d is missing a name", i)
+		case names[cfg.Name]:
+			return fmt.Errorf("output %q is configured more than once", cfg.Name)
+		case cfg.Default != "" && cfg.Default != CommandOn && cfg.Default != CommandOff:
+			return fmt.Errorf("invalid default %q for output %q, must be on or off", cfg.Default, cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		id, err := ResolveLine(cfg.Chip, cfg.Line, cfg.Pin)
+		if err != nil {
+			return fmt.Errorf("output %q: %w", cfg.Name, err)
+		}
+		if lines[id] {
+			return fmt.Errorf("line %s is used by more than one output", id)
+		}
+		lines[id] = true
+		ids[i] = id
+
+		pulses[i] = defaultPulse
+		if cfg.Pulse != "" {
+			d, err := time.ParseDuration(cfg.Pulse)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid pulse %q for output %q", cfg.Pulse, cfg.Name)
+			}
+			pulses[i] = d
+		}
+	}
+
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+
+	releaseOutputs()
+
+	var errs []error
+	for i, cfg := range cfgs {
+		cfg.Pin = ids[i].Offset
+		o := &output{
+			cfg:   cfg,
+			pulse: pulses[i],
+			line:  NewLineGPIO(ids[i]),
+			state: OutputState{Name: cfg.Name, Pin: cfg.Pin, Chip: ids[i].Chip, On: cfg.Default == CommandOn, ChangedAt: time.Now()},
+		}
+
+		if err := o.line.OpenOutput(o.level(o.state.On)); err != nil {
+			o.state.Error = err.Error()
+			errs = append(errs, fmt.Errorf("output %q: %w", cfg.Name, err))
+		}
+		outputs[cfg.Name] = o
+	}
+
+	if len(outputs) > 0 {
+		log.Info().Msgf("configured %d gpio outputs", len(outputs))
+	}
+	return errors.Join(errs...)
+}
+
+// SetOutput runs a command on an output. A pulse turns the output on for duration (or the
+// output's configured pulse if duration is 0) and then off; any other command cancels a pulse.
+func SetOutput(name, command string, duration time.Duration) (OutputState, error) {
+	outputsMu.RLock()
+	o, ok := outputs[name]
+	outputsMu.RUnlock()
+	if !ok {
+		return OutputState{}, fmt.Errorf("%w %q", ErrUnknownOutput, name)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return o.state, fmt.Errorf("%w %q", ErrUnknownOutput, name)
+	}
+
+	switch command {
+	case CommandOn, CommandOff:
+		o.stopPulse()
+		if err := o.set(command == CommandOn, command); err != nil {
+			return o.state, err
+		}
+	case CommandPulse:
+		if duration <= 0 {
+			duration = o.pulse
+		}
+
+		o.stopPulse()
+		if err := o.set(true, command); err != nil {
+			return o.state, err
+		}
+
+		var timer *time.Timer
+		timer = time.AfterFunc(duration, func() {
+			o.mu.Lock()
+			defer o.mu.Unlock()
+
+			// a newer command replaced this pulse
+			if o.timer != timer || o.closed {
+				return
+			}
+			o.timer = nil
+			if err := o.set(false, command); err != nil {
+				log.Warn().Err(err).Msgf("failed to end pulse on output %s", o.cfg.Name)
+			}
+		})
+		o.timer = timer
+		o.state.Pulsing = true
+	default:
+		return o.state, fmt.Errorf("%w %q, must be on, off or pulse", ErrInvalidCommand, command)
+	}
+
+	return o.state, nil
+}
+
+// Outputs returns the state of each output, sorted by name.
+func Outputs() []OutputState {
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+
+	ret := make([]OutputState, 0, len(outputs))
+	for _, o := range outputs {
+		o.mu.Lock()
+		ret = append(ret, o.state)
+		o.mu.Unlock()
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// releaseOutputs sets every output to its default state and releases its line. outputsMu must be held.
+func releaseOutputs() {
+	for name, o := range outputs {
+		o.mu.Lock()
+		o.stopPulse()
+		if err := o.set(o.cfg.Default == CommandOn, "release"); err != nil {
+			log.Warn().Err(err).Msgf("failed to reset output %s", name)
+		}
+		o.line.Close()
+		o.closed = true
+		o.mu.Unlock()
+
+		delete(outputs, name)
+	}
+}
+
+// level returns the value to write for on, taking ActiveLow into account.
+func (o *output) level(on bool) int {
+	if on != o.cfg.ActiveLow {
+		return HIGH
+	}
+	return LOW
+}
+
+// set drives the line and sends an event if the state changed. o.mu must be held.
+func (o *output) set(on bool, command string) error {
+	if err := o.line.Write(o.level(on)); err != nil {
+		o.state.Error = err.Error()
+		return fmt.Errorf("failed to set output %s: %w", o.cfg.Name, err)
+	}
+
+	changed := o.state.On != on
+	o.state.On = on
+	o.state.Pulsing = false
+	o.state.Command = command
+	o.state.Error = ""
+
+	if changed {
+		o.state.ChangedAt = time.Now()
+		log.Info().Msgf("output %s (%s/%d) -> %v", o.cfg.Name, o.state.Chip, o.cfg.Pin, on)
+		go sendOutputEvent(o.state)
+	}
+	return nil
+}
+
+// stopPulse cancels a pulse in progress. o.mu must be held.
+func (o *output) stopPulse() {
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+}
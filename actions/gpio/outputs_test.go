@@ -0,0 +1,73 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOutputs(t *testing.T) {
+	sim := NewSimulator()
+	SetBackend(sim)
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+
+	err := SetOutputs([]Output{
+		{Name: "led", Pin: 5},
+		{Name: "lift", Pin: 6, ActiveLow: true, Pulse: "20ms"},
+	})
+	if err != nil {
+		t.Fatalf("failed to set outputs: %s", err)
+	}
+
+	// outputs start in their default (off) state; active low idles high
+	if sim.Get(5) != LOW || sim.Get(6) != HIGH {
+		t.Fatalf("unexpected initial values: led=%d lift=%d", sim.Get(5), sim.Get(6))
+	}
+
+	if state, err := SetOutput("led", CommandOn, 0); err != nil || !state.On || sim.Get(5) != HIGH {
+		t.Errorf("failed to turn led on: %+v, %v", state, err)
+	}
+
+	state, err := SetOutput("lift", CommandPulse, 0)
+	if err != nil || !state.On || !state.Pulsing || sim.Get(6) != LOW {
+		t.Fatalf("failed to pulse lift: %+v, %v", state, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for sim.Get(6) != HIGH {
+		if time.Now().After(deadline) {
+			t.Fatalf("pulse didn't end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a command during a pulse cancels it
+	if _, err := SetOutput("lift", CommandPulse, 50*time.Millisecond); err != nil {
+		t.Fatalf("failed to pulse lift: %s", err)
+	}
+	if _, err := SetOutput("lift", CommandOn, 0); err != nil {
+		t.Fatalf("failed to turn lift on: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if sim.Get(6) != LOW {
+		t.Errorf("cancelled pulse turned the lift off")
+	}
+
+	if _, err := SetOutput("lift", "up", 0); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("expected an invalid command error, got %v", err)
+	}
+	if _, err := SetOutput("screen", CommandOn, 0); !errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("expected an unknown output error, got %v", err)
+	}
+
+	// reconfiguring puts the old outputs back to their default state
+	if err := SetOutputs(nil); err != nil {
+		t.Fatalf("failed to clear outputs: %s", err)
+	}
+	if sim.Get(5) != LOW || sim.Get(6) != HIGH || len(Outputs()) != 0 {
+		t.Errorf("outputs weren't released: led=%d lift=%d", sim.Get(5), sim.Get(6))
+	}
+}
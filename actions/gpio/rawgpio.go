@@ -2,8 +2,7 @@ package gpio
 
 import (
 	"fmt"
-
-	gpiocdev "github.com/warthog618/go-gpiocdev"
+	"time"
 )
 
 const (
@@ -15,20 +14,39 @@ const (
 )
 
 type GPIO struct {
-	chip string
-	pin  int
-	line *gpiocdev.Line
+	backend Backend
+	chip    string
+	pin     int
+	line    Line
 }
 
+// NewGPIO returns a handle for pin on the default chip of the configured backend.
+// No line is requested until it's opened.
 func NewGPIO(pin int) *GPIO {
-	return &GPIO{chip: "gpiochip0", pin: pin}
+	return &GPIO{backend: GetBackend(), pin: pin}
+}
+
+// NewLineGPIO returns a handle for a resolved line on the configured backend.
+func NewLineGPIO(id LineID) *GPIO {
+	return &GPIO{backend: GetBackend(), chip: id.Chip, pin: id.Offset}
 }
 
 // OpenInput requests the line as input and keeps it open.
 func (g *GPIO) OpenInput() error {
-	l, err := gpiocdev.RequestLine(g.chip, g.pin, gpiocdev.AsInput)
+	l, err := g.backend.RequestInput(g.chip, g.pin)
+	if err != nil {
+		return err
+	}
+	g.line = l
+	return nil
+}
+
+// OpenEdges requests the line as input and calls handler on every rising and falling edge,
+// debounced by debounce where the backend supports it.
+func (g *GPIO) OpenEdges(debounce time.Duration, handler func(EdgeEvent)) error {
+	l, err := g.backend.RequestEdges(g.chip, g.pin, debounce, handler)
 	if err != nil {
-		return fmt.Errorf("request input %s/%d: %w", g.chip, g.pin, err)
+		return err
 	}
 	g.line = l
 	return nil
@@ -40,9 +58,9 @@ func (g *GPIO) OpenOutput(initial int) error {
 	if initial != 0 {
 		init = 1
 	}
-	l, err := gpiocdev.RequestLine(g.chip, g.pin, gpiocdev.AsOutput(init))
+	l, err := g.backend.RequestOutput(g.chip, g.pin, init)
 	if err != nil {
-		return fmt.Errorf("request output %s/%d: %w", g.chip, g.pin, err)
+		return err
 	}
 	g.line = l
 	return nil
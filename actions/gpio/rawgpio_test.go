@@ -1,41 +1,130 @@
 package gpio
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"testing"
 	"time"
+
+	gpiocdev "github.com/warthog618/go-gpiocdev"
 )
 
-func main() {
-	in := NewGPIO(24) // <-- change if needed
-	out := NewGPIO(4) // <-- change if needed
+// TestHardwareLoopback toggles an output wired to an input on a real Pi.
+// Set GPIO_LOOPBACK to "<input>,<output>" (e.g. "24,4") to run it.
+func TestHardwareLoopback(t *testing.T) {
+	var inPin, outPin int
+	if _, err := fmt.Sscanf(os.Getenv("GPIO_LOOPBACK"), "%d,%d", &inPin, &outPin); err != nil {
+		t.Skip("GPIO_LOOPBACK is not set")
+	}
+
+	b := newCDevBackend("")
+	in := &GPIO{backend: b, pin: inPin}
+	out := &GPIO{backend: b, pin: outPin}
 
-	// Open lines
 	if err := in.OpenInput(); err != nil {
-		panic(fmt.Errorf("open input: %w", err))
+		t.Fatalf("open input: %s", err)
 	}
 	defer in.Close()
 
 	if err := out.OpenOutput(LOW); err != nil {
-		panic(fmt.Errorf("open output: %w", err))
+		t.Fatalf("open output: %s", err)
 	}
 	defer out.Close()
 
-	fmt.Println("Toggling output and reading input (10 cycles):")
 	for i := 0; i < 10; i++ {
 		val := i % 2
 		if err := out.Write(val); err != nil {
-			panic(fmt.Errorf("write: %w", err))
+			t.Fatalf("write: %s", err)
 		}
 		// small settle time
 		time.Sleep(50 * time.Millisecond)
 
 		r, err := in.Read()
 		if err != nil {
-			panic(fmt.Errorf("read: %w", err))
+			t.Fatalf("read: %s", err)
+		}
+		if r != val {
+			t.Errorf("cycle %d: wrote %d, read %d", i, val, r)
+		}
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	sim := NewSimulator()
+
+	in := &GPIO{backend: sim, pin: 24}
+	edges := make(chan EdgeEvent, 2)
+	if err := in.OpenEdges(0, func(e EdgeEvent) { edges <- e }); err != nil {
+		t.Fatalf("open edges: %s", err)
+	}
+
+	if err := (&GPIO{backend: sim, pin: 24}).OpenInput(); err == nil {
+		t.Errorf("expected a busy line to fail")
+	}
+
+	sim.Set(24, HIGH)
+	sim.Set(24, HIGH) // no change, no edge
+	sim.Set(24, LOW)
+
+	for _, rising := range []bool{true, false} {
+		select {
+		case e := <-edges:
+			if e.Pin != 24 || e.Rising != rising {
+				t.Errorf("got edge %+v, want rising=%v", e, rising)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for edge")
+		}
+	}
+	if len(edges) != 0 {
+		t.Errorf("got %d extra edges", len(edges))
+	}
+
+	if err := in.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+
+	out := &GPIO{backend: sim, pin: 24}
+	if err := out.OpenOutput(HIGH); err != nil {
+		t.Fatalf("open output after close: %s", err)
+	}
+	defer out.Close()
+
+	if v, _ := out.Read(); v != HIGH {
+		t.Errorf("got %d, want %d", v, HIGH)
+	}
+	if err := out.Write(LOW); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	if sim.Get(24) != LOW {
+		t.Errorf("got %d, want %d", sim.Get(24), LOW)
+	}
+}
+
+func TestSimulatorOutOfRange(t *testing.T) {
+	sim := NewSimulator()
+
+	for _, pin := range []int{-1, simLines, simLines + 10} {
+		if _, err := sim.RequestInput("", pin); !errors.Is(err, gpiocdev.ErrInvalidOffset) {
+			t.Errorf("request %d: expected an invalid offset, got %v", pin, err)
+		}
+		if _, err := sim.RequestOutput(simChip, pin, HIGH); !errors.Is(err, gpiocdev.ErrInvalidOffset) {
+			t.Errorf("request output %d: expected an invalid offset, got %v", pin, err)
 		}
-		fmt.Printf("out=%d -> in=%d\n", val, r)
+		if err := sim.Set(pin, HIGH); !errors.Is(err, gpiocdev.ErrInvalidOffset) {
+			t.Errorf("set %d: expected an invalid offset, got %v", pin, err)
+		}
+		if sim.Get(pin) != LOW {
+			t.Errorf("expected %d to be left unset", pin)
+		}
+	}
 
-		time.Sleep(450 * time.Millisecond)
+	// the last line is still in range
+	if _, err := sim.RequestInput("", simLines-1); err != nil {
+		t.Errorf("request %d: %s", simLines-1, err)
+	}
+	if err := sim.Set(simLines-1, HIGH); err != nil {
+		t.Errorf("set %d: %s", simLines-1, err)
 	}
-	fmt.Println("Done.")
 }
@@ -0,0 +1,255 @@
+package gpio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of requests sent for a pin.
+const (
+	RequestChange = "change"
+	RequestTrueUp = "true-up"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultRetries        = 2
+	defaultBackoff        = time.Second
+	maxBackoff            = 30 * time.Second
+
+	maxRequestResults = 100
+	maxResponseLength = 512
+)
+
+// request is an HTTP request sent when a pin changes or trues up. URL and Body are templates
+// filled with the pin, compiled when the config is loaded. A request succeeds on a 2xx status unless SuccessCodes is set, and failed
+// attempts are retried Retries times, waiting Backoff (doubling each time) in between.
+type request struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Body         interface{} `json:"body"`
+	Timeout      string      `json:"timeout,omitempty"`
+	Retries      *int        `json:"retries,omitempty"`
+	Backoff      string      `json:"backoff,omitempty"`
+	SuccessCodes []int       `json:"success-codes,omitempty"`
+
+	url, body  *template.Template
+	bodySource string
+}
+
+// RequestResult is the outcome of sending one change or true-up request.
+type RequestResult struct {
+	Pin        int       `json:"pin"`
+	Kind       string    `json:"kind"`
+	Index      int       `json:"index"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Connected  bool      `json:"connected"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status-code,omitempty"`
+	Success    bool      `json:"success"`
+	Cancelled  bool      `json:"cancelled,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	StartedAt  time.Time `json:"started-at"`
+	FinishedAt time.Time `json:"finished-at"`
+}
+
+var (
+	resultsMu sync.Mutex
+	results   []RequestResult
+)
+
+// RecentRequests returns the outcome of the most recent change and true-up requests, newest first.
+func RecentRequests() []RequestResult {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	ret := make([]RequestResult, len(results))
+	for i := range results {
+		ret[i] = results[len(results)-1-i]
+	}
+	return ret
+}
+
+func recordResult(res RequestResult) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	results = append(results, res)
+	if len(results) > maxRequestResults {
+		results = results[len(results)-maxRequestResults:]
+	}
+}
+
+// validate makes sure the request's settings parse.
+func (r *request) validate() error {
+	if r.URL == "" {
+		return fmt.Errorf("request is missing a url")
+	}
+	for name, d := range map[string]string{"timeout": r.Timeout, "backoff": r.Backoff} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid %s for %s: %w", name, r.URL, err)
+		}
+	}
+	if r.Retries != nil && *r.Retries < 0 {
+		return fmt.Errorf("invalid retries for %s: %d", r.URL, *r.Retries)
+	}
+	return nil
+}
+
+func (r *request) succeeded(status int) bool {
+	if len(r.SuccessCodes) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, code := range r.SuccessCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// runRequests sends reqs one at a time, in order, with a snapshot of the pin's current state.
+// The returned func cancels whatever hasn't finished yet.
+func (p *Pin) runRequests(ctx context.Context, kind string, reqs []request) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if len(reqs) == 0 {
+		return cancel
+	}
+
+	mu.RLock()
+	snapshot := *p
+	mu.RUnlock()
+
+	go func() {
+		defer cancel()
+
+		for i := range reqs {
+			if ctx.Err() != nil {
+				return
+			}
+
+			res := reqs[i].execute(ctx, &snapshot)
+			res.Pin = snapshot.Pin
+			res.Kind = kind
+			res.Index = i
+			res.Connected = snapshot.Connected
+			recordResult(res)
+
+			if res.Cancelled {
+				log.Info().Msgf("Cancelled stale %s request %d for pin %d", kind, i, snapshot.Pin)
+				return
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// execute sends the request, retrying until it succeeds, runs out of retries, or ctx is cancelled.
+func (r *request) execute(ctx context.Context, pin *Pin) RequestResult {
+	res := RequestResult{Method: r.Method, URL: r.URL, StartedAt: time.Now()}
+	defer func() { res.FinishedAt = time.Now() }()
+
+	url, body, err := r.render(pin)
+	if err != nil {
+		log.Warn().Msgf("Failed to execute request against %s: %s", r.URL, err)
+		res.Error = err.Error()
+		return res
+	}
+	res.URL = url
+
+	log.Debug().Msgf("Building %s request against %s", r.Method, url)
+	if body != "" {
+		log.Debug().Msgf("Request body for %s: %s", url, body)
+	}
+
+	timeout, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		timeout = defaultRequestTimeout
+	}
+	backoff, err := time.ParseDuration(r.Backoff)
+	if err != nil {
+		backoff = defaultBackoff
+	}
+	retries := defaultRetries
+	if r.Retries != nil {
+		retries = *r.Retries
+	}
+
+	client := &http.Client{Timeout: timeout}
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+		if ctx.Err() != nil {
+			res.Cancelled = true
+			res.Error = ctx.Err().Error()
+			return res
+		}
+
+		res.Attempts++
+		status, reply, err := r.send(ctx, client, url, body)
+		res.StatusCode = status
+		res.Response = reply
+
+		switch {
+		case ctx.Err() != nil:
+			res.Cancelled = true
+			res.Error = ctx.Err().Error()
+			return res
+		case err != nil:
+			res.Error = err.Error()
+		case !r.succeeded(status):
+			res.Error = fmt.Sprintf("unexpected status %d", status)
+		default:
+			res.Success = true
+			res.Error = ""
+			log.Info().Msgf("Response from %s: %d %s", url, status, reply)
+			return res
+		}
+
+		log.Warn().Msgf("%s request to %s failed (attempt %d of %d): %s", r.Method, url, attempt+1, retries+1, res.Error)
+	}
+
+	return res
+}
+
+// send makes a single attempt at the request, returning the status code and (truncated) response.
+func (r *request) send(ctx context.Context, client *http.Client, url, body string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return 0, "", fmt.Errorf("unable to build request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	log.Info().Msgf("Sending %s request to %s", r.Method, url)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseLength))
+	if err != nil {
+		return resp.StatusCode, "", fmt.Errorf("unable to read response: %w", err)
+	}
+
+	return resp.StatusCode, string(reply), nil
+}
@@ -0,0 +1,83 @@
+package gpio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	retries := 2
+	r := request{Method: http.MethodPut, URL: srv.URL + "/{{.Pin}}", Retries: &retries, Backoff: "1ms"}
+	res := r.execute(context.Background(), &Pin{Pin: 3})
+	if !res.Success || res.Attempts != 3 || res.StatusCode != http.StatusAccepted || res.URL != srv.URL+"/3" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	// only the configured codes count as success
+	calls.Store(0)
+	r.SuccessCodes = []int{http.StatusOK}
+	res = r.execute(context.Background(), &Pin{Pin: 3})
+	if res.Success || res.Attempts != 3 || res.Error == "" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestRequestsCancelledWhenStale(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	reqs := []request{
+		{Method: http.MethodGet, URL: srv.URL + "/first"},
+		{Method: http.MethodGet, URL: srv.URL + "/second"},
+	}
+
+	cancel := (&Pin{Pin: 7}).runRequests(context.Background(), RequestChange, reqs)
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		recent := RecentRequests()
+		if len(recent) > 0 && recent[0].Pin == 7 {
+			if !recent[0].Cancelled || recent[0].Index != 0 || recent[0].Kind != RequestChange {
+				t.Errorf("unexpected result: %+v", recent[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the cancelled request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 1 {
+		t.Errorf("expected the second request to be skipped, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,159 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gpiocdev "github.com/warthog618/go-gpiocdev"
+)
+
+const (
+	simChip  = "gpiochip0"
+	simLines = 64
+)
+
+// Simulator is an in-memory backend with a single chip whose lines are named GPIO0, GPIO1, ...
+// Lines start low and are changed with Set, so divider logic can be exercised without a Pi.
+type Simulator struct {
+	mu     sync.Mutex
+	values map[int]int
+	lines  map[int]*simLine
+}
+
+type simLine struct {
+	sim     *Simulator
+	pin     int
+	output  bool
+	handler func(EdgeEvent)
+}
+
+// NewSimulator returns a simulator with every line low.
+func NewSimulator() *Simulator {
+	return &Simulator{
+		values: make(map[int]int),
+		lines:  make(map[int]*simLine),
+	}
+}
+
+func (s *Simulator) Name() string {
+	return BackendSimulator
+}
+
+func (s *Simulator) DefaultChip() string {
+	return simChip
+}
+
+func (s *Simulator) Chips() ([]ChipInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chip := ChipInfo{Name: simChip, Label: "gpio-sim", Lines: make([]LineInfo, simLines)}
+	for i := range chip.Lines {
+		chip.Lines[i] = LineInfo{Offset: i, Name: fmt.Sprintf("GPIO%d", i), Direction: "input"}
+		if l, ok := s.lines[i]; ok {
+			chip.Lines[i].Used = true
+			chip.Lines[i].Consumer = "device-monitoring"
+			if l.output {
+				chip.Lines[i].Direction = "output"
+			}
+		}
+	}
+	return []ChipInfo{chip}, nil
+}
+
+func (s *Simulator) RequestInput(chip string, pin int) (Line, error) {
+	return s.request(chip, &simLine{pin: pin})
+}
+
+// RequestEdges requests the line as input; handler is called whenever Set changes its value.
+// Simulated lines don't bounce, so debounce is ignored.
+func (s *Simulator) RequestEdges(chip string, pin int, _ time.Duration, handler func(EdgeEvent)) (Line, error) {
+	return s.request(chip, &simLine{pin: pin, handler: handler})
+}
+
+func (s *Simulator) RequestOutput(chip string, pin int, initial int) (Line, error) {
+	l, err := s.request(chip, &simLine{pin: pin, output: true})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Set(pin, initial)
+	return l, nil
+}
+
+// Set sets the value of a simulated line, sending an edge event if it changed.
+// Like a real chip, it only has simLines lines.
+func (s *Simulator) Set(pin int, value int) error {
+	if pin < 0 || pin >= simLines {
+		return fmt.Errorf("set sim/%d: %w", pin, gpiocdev.ErrInvalidOffset)
+	}
+	if value != 0 {
+		value = 1
+	}
+
+	s.mu.Lock()
+	prev := s.values[pin]
+	s.values[pin] = value
+
+	var handler func(EdgeEvent)
+	if l, ok := s.lines[pin]; ok {
+		handler = l.handler
+	}
+	s.mu.Unlock()
+
+	if prev != value && handler != nil {
+		handler(EdgeEvent{Pin: pin, Rising: value == 1, At: time.Now()})
+	}
+	return nil
+}
+
+// Get returns the value of a simulated line.
+func (s *Simulator) Get(pin int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[pin]
+}
+
+// request reserves a line, failing if it's out of range or already in use like the kernel does.
+func (s *Simulator) request(chip string, l *simLine) (Line, error) {
+	if chip != "" && chip != simChip {
+		return nil, fmt.Errorf("request %s/%d: no such sim chip", chip, l.pin)
+	}
+	if l.pin < 0 || l.pin >= simLines {
+		return nil, fmt.Errorf("request sim/%d: %w", l.pin, gpiocdev.ErrInvalidOffset)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lines[l.pin]; ok {
+		return nil, fmt.Errorf("request sim/%d: line is busy", l.pin)
+	}
+
+	l.sim = s
+	s.lines[l.pin] = l
+	return l, nil
+}
+
+func (l *simLine) Value() (int, error) {
+	return l.sim.Get(l.pin), nil
+}
+
+func (l *simLine) SetValue(value int) error {
+	if !l.output {
+		return fmt.Errorf("set sim/%d: line is an input", l.pin)
+	}
+
+	return l.sim.Set(l.pin, value)
+}
+
+func (l *simLine) Close() error {
+	l.sim.mu.Lock()
+	defer l.sim.mu.Unlock()
+
+	if l.sim.lines[l.pin] == l {
+		delete(l.sim.lines, l.pin)
+	}
+	return nil
+}
@@ -3,32 +3,20 @@ package gpio
 import "sync"
 
 var (
-	mu   sync.RWMutex
-	pins []Pin
+	// mu guards the monitors and the state of their pins
+	mu       sync.RWMutex
+	monitors = make(map[LineID]*monitor)
+	// order is the line of each configured pin, in config order
+	order []LineID
 )
 
-// SetPins sets the canonical pin list (copy-on-write)
-func SetPins(p []Pin) {
-	mu.Lock()
-	defer mu.Unlock()
-	pins = make([]Pin, len(p))
-	copy(pins, p)
-}
-
-// GetPins returns a COPY (safe snapshot) of the current pin list
+// GetPins returns a COPY (safe snapshot) of the monitored pins, in config order
 func GetPins() []Pin {
 	mu.RLock()
 	defer mu.RUnlock()
-	cp := make([]Pin, len(pins))
-	copy(cp, pins)
-	return cp
-}
-
-// StartAllMonitors launches Monitor() for each pin in the canonical slice.
-func StartAllMonitors() {
-	mu.RLock()
-	defer mu.RUnlock()
-	for i := range pins {
-		go (&pins[i]).Monitor()
+	cp := make([]Pin, 0, len(order))
+	for _, line := range order {
+		cp = append(cp, *monitors[line].pin)
 	}
+	return cp
 }
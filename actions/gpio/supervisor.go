@@ -0,0 +1,269 @@
+package gpio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Monitor states reported by Statuses.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateFailed   = "failed"
+	StateStopped  = "stopped"
+)
+
+// MonitorStatus is the state of the monitor watching a single line.
+type MonitorStatus struct {
+	Pin        int        `json:"pin"`
+	Chip       string     `json:"chip"`
+	Line       string     `json:"line,omitempty"`
+	Presets    string     `json:"presets,omitempty"`
+	State      string     `json:"state"`
+	Mode       string     `json:"mode,omitempty"`
+	Connected  bool       `json:"connected"`
+	Flapping   bool       `json:"flapping,omitempty"`
+	StartedAt  time.Time  `json:"started-at"`
+	LastChange *time.Time `json:"last-change,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type monitor struct {
+	pin    *Pin
+	config string
+	cancel context.CancelFunc
+	done   chan struct{}
+	status MonitorStatus
+}
+
+// reloadMu serializes reloads so two callers can't race to request the same line, and keeps the
+// topology from being set against pins that are being replaced.
+var reloadMu sync.Mutex
+
+// Reload makes the running monitors match pins: monitors for removed or changed pins are
+// stopped (releasing their lines) and monitors for new or changed pins are started.
+// Pins whose config is unchanged keep running and keep their state.
+// Every pin's line is resolved first; if any can't be, nothing is changed.
+// A topology that refers to a sensor that's no longer configured is cleared.
+func Reload(pins []Pin) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	pins = append([]Pin(nil), pins...)
+	configs := make(map[LineID]string, len(pins))
+	sensors := make(map[string]bool, len(pins))
+	var errs []error
+	for i := range pins {
+		cfg, err := pinConfig(pins[i])
+		if err != nil {
+			return err
+		}
+
+		id, err := ResolveLine(pins[i].Chip, pins[i].Line, pins[i].Pin)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("divider %d: %w", i, err))
+			continue
+		}
+		if _, ok := configs[id]; ok {
+			errs = append(errs, fmt.Errorf("divider %d: line %s is configured more than once", i, id))
+			continue
+		}
+		pins[i].id = id
+		pins[i].Pin = id.Offset
+
+		if name := pins[i].SensorName(); sensors[name] {
+			errs = append(errs, fmt.Errorf("divider %d: sensor name %q is used more than once", i, name))
+		} else {
+			sensors[name] = true
+		}
+
+		for _, reqs := range [][]request{pins[i].ChangeRequests, pins[i].TrueUpRequests} {
+			for j := range reqs {
+				if err := reqs[j].validate(); err != nil {
+					errs = append(errs, fmt.Errorf("invalid request for line %s: %w", id, err))
+				}
+			}
+		}
+		if err := pins[i].Flap.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("line %s: %w", id, err))
+		}
+
+		configs[id] = cfg
+	}
+
+	// templates can refer to other sensors, so they're checked once every sensor is known
+	var statuses []TemplateStatus
+	for i := range pins {
+		pins[i].ChangeRequests = append([]request(nil), pins[i].ChangeRequests...)
+		pins[i].TrueUpRequests = append([]request(nil), pins[i].TrueUpRequests...)
+
+		for _, kr := range []struct {
+			kind string
+			reqs []request
+		}{{RequestChange, pins[i].ChangeRequests}, {RequestTrueUp, pins[i].TrueUpRequests}} {
+			kind, reqs := kr.kind, kr.reqs
+			for j := range reqs {
+				for _, status := range reqs[j].check(pins[i], kind, j, sensors) {
+					statuses = append(statuses, status)
+					if status.Error != "" {
+						errs = append(errs, fmt.Errorf("invalid %s template for %s request %d of sensor %s: %s", status.Field, kind, j, status.Sensor, status.Error))
+					}
+				}
+			}
+		}
+	}
+	setTemplates(statuses)
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	var stopping []*monitor
+	for line, m := range monitors {
+		if cfg, ok := configs[line]; !ok || cfg != m.config {
+			stopping = append(stopping, m)
+			delete(monitors, line)
+		}
+	}
+	mu.Unlock()
+
+	// wait for old monitors to release their lines before requesting them again
+	for _, m := range stopping {
+		m.stop()
+	}
+
+	saved, err := loadSavedStates()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to restore divider state")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	order = make([]LineID, 0, len(pins))
+	started := 0
+	for i := range pins {
+		line := pins[i].id
+		order = append(order, line)
+
+		if _, ok := monitors[line]; ok {
+			continue
+		}
+
+		p := pins[i]
+		if s, ok := saved[line.String()]; ok {
+			p.Connected = s.Connected
+			p.restored = true
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		m := &monitor{
+			pin:    &p,
+			config: configs[line],
+			cancel: cancel,
+			done:   make(chan struct{}),
+			status: MonitorStatus{
+				Pin:       line.Offset,
+				Chip:      line.Chip,
+				Line:      string(p.Line),
+				Presets:   p.BlueberryPresets,
+				State:     StateStarting,
+				StartedAt: time.Now(),
+			},
+		}
+		p.monitor = m
+		monitors[line] = m
+		started++
+
+		go m.run(ctx)
+	}
+	revalidateTopology(pins)
+
+	if len(stopping) > 0 || started > 0 {
+		log.Info().Msgf("reloaded gpio monitors: %d stopped, %d started, %d total", len(stopping), started, len(monitors))
+	}
+	return nil
+}
+
+// Shutdown stops every monitor and waits for their lines to be released,
+// then sets every output to its default state and releases it.
+func Shutdown() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	defer func() {
+		outputsMu.Lock()
+		releaseOutputs()
+		outputsMu.Unlock()
+	}()
+
+	mu.Lock()
+	stopping := make([]*monitor, 0, len(monitors))
+	for line, m := range monitors {
+		stopping = append(stopping, m)
+		delete(monitors, line)
+	}
+	order = nil
+	mu.Unlock()
+
+	for _, m := range stopping {
+		m.stop()
+	}
+}
+
+// Statuses returns the status of each monitor, in config order.
+func Statuses() []MonitorStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	ret := make([]MonitorStatus, 0, len(order))
+	for _, line := range order {
+		m := monitors[line]
+		s := m.status
+		s.Connected = m.pin.Connected
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func (m *monitor) run(ctx context.Context) {
+	defer close(m.done)
+	m.pin.Monitor(ctx)
+
+	mu.Lock()
+	m.status.State = StateStopped
+	mu.Unlock()
+}
+
+func (m *monitor) stop() {
+	m.cancel()
+	<-m.done
+}
+
+// setStatus updates the status of the monitor running p, if there is one.
+func (p *Pin) setStatus(update func(*MonitorStatus)) {
+	if p.monitor == nil {
+		return
+	}
+
+	mu.Lock()
+	update(&p.monitor.status)
+	mu.Unlock()
+}
+
+// pinConfig returns the config of a pin, without its state, for comparing across reloads.
+func pinConfig(p Pin) (string, error) {
+	p.Connected = false
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("invalid config for pin %d: %w", p.Pin, err)
+	}
+	return string(b), nil
+}
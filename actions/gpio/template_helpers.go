@@ -1,33 +1,300 @@
 package gpio
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/byuoitav/device-monitoring/localsystem"
 )
 
+// envError is returned by template functions that depend on the environment (e.g. SYSTEM_ID),
+// so validating a template doesn't fail just because it's done somewhere that isn't set up.
+type envError struct {
+	err error
+}
+
+func (e envError) Error() string { return e.err.Error() }
+func (e envError) Unwrap() error { return e.err }
+
+// Templates can only read these environment variables, and ones starting with templateEnvPrefixes,
+// so a divider config can't send credentials (e.g. DB_PASSWORD) anywhere.
+var (
+	templateEnvNames    = []string{"SYSTEM_ID", "PI_HOSTNAME"}
+	templateEnvPrefixes = []string{"DM_", "ROOM_"}
+)
+
+// templateFuncs are the functions available to change and true-up templates.
+var templateFuncs = template.FuncMap{
+	"json":          toJSON,
+	"env":           templateEnv,
+	"systemID":      systemID,
+	"roomID":        roomID,
+	"buildingID":    buildingID,
+	"now":           time.Now,
+	"timestamp":     timestamp,
+	"state":         sensorState,
+	"connected":     sensorConnected,
+	"configuration": func() string { return CurrentStatus().Configuration },
+}
+
+// TemplateStatus is the result of compiling one of a pin's request templates.
+type TemplateStatus struct {
+	Pin    int    `json:"pin"`
+	Sensor string `json:"sensor"`
+	Kind   string `json:"kind"`
+	Index  int    `json:"index"`
+	Field  string `json:"field"`
+	Source string `json:"source"`
+	Error  string `json:"error,omitempty"`
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   []TemplateStatus
+)
+
+// Templates returns the templates compiled by the last reload, with any errors.
+func Templates() []TemplateStatus {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	return append([]TemplateStatus(nil), templates...)
+}
+
+func setTemplates(t []TemplateStatus) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	templates = t
+}
+
+// compile parses the request's URL and body templates.
+func (r *request) compile() error {
+	if err := r.compileURL(); err != nil {
+		return err
+	}
+	return r.compileBody()
+}
+
+func (r *request) compileURL() error {
+	url, err := template.New("url").Funcs(templateFuncs).Parse(r.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url template: %w", err)
+	}
+	r.url = url
+	return nil
+}
+
+// compileBody marshals the body to JSON, then parses that as a template so {{.Field}}, pin methods
+// and functions can be used in its strings. JSON escapes double quotes, so string arguments in a
+// body are written with backticks.
+func (r *request) compileBody() error {
+	r.body, r.bodySource = nil, ""
+	if r.Body == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(r.Body)
+	if err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+	body, err := template.New("body").Funcs(templateFuncs).Parse(string(raw))
+	if err != nil {
+		return fmt.Errorf("invalid body template: %w", err)
+	}
+	r.body, r.bodySource = body, string(raw)
+	return nil
+}
+
+// check compiles the request's templates and runs them against p, with sensors as the names of
+// every configured sensor. It returns the status of each template.
+func (r *request) check(p Pin, kind string, index int, sensors map[string]bool) []TemplateStatus {
+	status := func(field, source string, err error) TemplateStatus {
+		s := TemplateStatus{Pin: p.Pin, Sensor: p.SensorName(), Kind: kind, Index: index, Field: field, Source: source}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		return s
+	}
+
+	url := status("url", r.URL, r.compileURL())
+	if url.Error == "" {
+		url.Error = dryRun(r.url, p, sensors)
+	}
+	statuses := []TemplateStatus{url}
+
+	if r.Body != nil {
+		body := status("body", r.bodySource, r.compileBody())
+		if body.Error == "" {
+			body.Source = r.bodySource
+			body.Error = dryRun(r.body, p, sensors)
+		}
+		statuses = append(statuses, body)
+	}
+	return statuses
+}
+
+// dryRun executes t against p, looking up sensors in the given set rather than the running monitors.
+// Errors from functions that depend on the environment are ignored.
+func dryRun(t *template.Template, p Pin, sensors map[string]bool) string {
+	lookup := func(name string) error {
+		if !sensors[name] {
+			return fmt.Errorf("unknown divider sensor %q", name)
+		}
+		return nil
+	}
+
+	clone, err := t.Clone()
+	if err != nil {
+		return err.Error()
+	}
+	clone.Funcs(template.FuncMap{
+		"state":         func(name string) (string, error) { return StateDisconnected, lookup(name) },
+		"connected":     func(name string) (bool, error) { return false, lookup(name) },
+		"configuration": func() string { return "" },
+	})
+
+	var envErr envError
+	if err := clone.Execute(&bytes.Buffer{}, p); err != nil && !errors.As(err, &envErr) {
+		return err.Error()
+	}
+	return ""
+}
+
+// render fills the request's URL and body templates with the pin, compiling them first if needed.
+func (r *request) render(p *Pin) (string, string, error) {
+	if r.url == nil {
+		if err := r.compile(); err != nil {
+			return "", "", err
+		}
+	}
+
+	url, err := execTemplate(r.url, p)
+	if err != nil {
+		return "", "", err
+	}
+	if r.body == nil {
+		return url, "", nil
+	}
+
+	body, err := execTemplate(r.body, p)
+	if err != nil {
+		return "", "", err
+	}
+	return url, body, nil
+}
+
+func execTemplate(t *template.Template, p *Pin) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, *p); err != nil {
+		return "", fmt.Errorf("unable to fill template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// timestamp formats the current time: "rfc3339", "unix", "unix-ms", or a Go time layout.
+func timestamp(format string) string {
+	now := time.Now()
+	switch strings.ToLower(format) {
+	case "rfc3339":
+		return now.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(now.Unix(), 10)
+	case "unix-ms":
+		return strconv.FormatInt(now.UnixMilli(), 10)
+	}
+	return now.Format(format)
+}
+
+// templateEnv returns an environment variable that templates are allowed to read.
+func templateEnv(name string) (string, error) {
+	allowed := slices.Contains(templateEnvNames, name)
+	for _, prefix := range templateEnvPrefixes {
+		allowed = allowed || strings.HasPrefix(name, prefix)
+	}
+	if !allowed {
+		return "", fmt.Errorf("environment variable %q can't be used in templates", name)
+	}
+	return os.Getenv(name), nil
+}
+
+func sensorState(name string) (string, error) {
+	for _, p := range GetPins() {
+		if p.SensorName() == name {
+			return p.State(), nil
+		}
+	}
+	return "", fmt.Errorf("unknown divider sensor %q", name)
+}
+
+func sensorConnected(name string) (bool, error) {
+	state, err := sensorState(name)
+	return state == StateConnected, err
+}
+
+func systemID() (string, error) {
+	id, err := localsystem.SystemID()
+	if err != nil {
+		return "", envError{err}
+	}
+	return id, nil
+}
+
+func roomID() (string, error) {
+	id, err := localsystem.RoomID()
+	if err != nil {
+		return "", envError{err}
+	}
+	return id, nil
+}
+
+func buildingID() (string, error) {
+	id, err := localsystem.BuildingID()
+	if err != nil {
+		return "", envError{err}
+	}
+	return id, nil
+}
+
 func (p Pin) Time() string {
 	return time.Now().Format(time.RFC3339)
 }
 
-func (p Pin) SystemID() string {
-	return localsystem.MustSystemID()
+func (p Pin) SystemID() (string, error) {
+	return systemID()
 }
 
-func (p Pin) RoomID() string {
-	return localsystem.MustRoomID()
+func (p Pin) RoomID() (string, error) {
+	return roomID()
 }
 
-func (p Pin) Room() string {
-	id := localsystem.MustRoomID()
+func (p Pin) Room() (string, error) {
+	id, err := roomID()
+	if err != nil {
+		return "", err
+	}
 	split := strings.Split(id, "-")
 	if len(split) == 2 {
-		return split[1]
+		return split[1], nil
 	}
-	return id
+	return id, nil
 }
 
-func (p Pin) BuildingID() string {
-	return localsystem.MustBuildingID()
+func (p Pin) BuildingID() (string, error) {
+	return buildingID()
 }
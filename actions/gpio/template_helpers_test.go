@@ -0,0 +1,113 @@
+package gpio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateValidation(t *testing.T) {
+	SetBackend(NewSimulator())
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+
+	bad := []Pin{
+		{Name: "north", Pin: 12, ChangeRequests: []request{{Method: "PUT", URL: "http://x/{{.Pin"}}},
+		{Name: "south", Pin: 13, TrueUpRequests: []request{{Method: "PUT", URL: "http://x/{{state \"east\"}}"}}},
+		{Name: "west", Pin: 14, ChangeRequests: []request{{Method: "PUT", URL: "http://x/{{.Nope}}"}}},
+	}
+	err := Reload(bad)
+	if err == nil {
+		t.Fatal("expected invalid templates to be rejected")
+	}
+	for _, want := range []string{"unclosed action", `unknown divider sensor "east"`, "can't evaluate field Nope"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+
+	failed := 0
+	for _, s := range Templates() {
+		if s.Error != "" {
+			failed++
+		}
+	}
+	if failed != 3 {
+		t.Errorf("expected 3 failed templates, got %+v", Templates())
+	}
+
+	// environment lookups that can't be done here don't fail validation
+	good := []Pin{
+		{Name: "north", Pin: 12, ChangeRequests: []request{{
+			Method: "PUT",
+			URL:    "http://x/{{roomID}}/{{.Room}}/{{state \"south\"}}",
+			Body:   map[string]string{"at": "{{timestamp `unix`}}", "env": "{{env `DM_ZONE`}}", "pin": "{{json .Connected}}"},
+		}}},
+		{Name: "south", Pin: 13},
+	}
+	if err := Reload(good); err != nil {
+		t.Fatalf("expected templates to be valid: %s", err)
+	}
+	if n := len(Templates()); n != 2 {
+		t.Errorf("expected a url and body template, got %+v", Templates())
+	}
+
+	// once loaded, they're filled with the pin and the other sensors' state
+	r := GetPins()[0].ChangeRequests[0]
+	r.URL = "http://x/{{.Pin}}/{{state \"south\"}}/{{connected \"south\"}}"
+	r.url = nil
+	url, body, err := r.render(&Pin{Pin: 12})
+	if err != nil {
+		t.Fatalf("failed to render: %s", err)
+	}
+	if url != "http://x/12/disconnected/false" || !strings.Contains(body, `"pin":"false"`) {
+		t.Errorf("unexpected url %q or body %q", url, body)
+	}
+}
+
+func TestTemplateEnv(t *testing.T) {
+	t.Setenv("DM_ZONE", "north")
+	t.Setenv("DB_PASSWORD", "secret")
+
+	tests := []struct {
+		name    string
+		want    string
+		allowed bool
+	}{
+		{name: "DM_ZONE", want: "north", allowed: true},
+		{name: "ROOM_NAME", want: "", allowed: true},
+		{name: "SYSTEM_ID", want: "", allowed: true},
+		{name: "DB_PASSWORD"},
+		{name: "HOME"},
+		{name: "DM"},
+	}
+	for _, tt := range tests {
+		got, err := templateEnv(tt.name)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed to be %v, got %v", tt.name, tt.allowed, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateEnvValidation(t *testing.T) {
+	SetBackend(NewSimulator())
+	t.Cleanup(func() {
+		Shutdown()
+		SetBackend(newCDevBackend(""))
+	})
+	t.Setenv("DB_PASSWORD", "secret")
+
+	pins := []Pin{{Name: "north", Pin: 12, ChangeRequests: []request{{
+		Method: "PUT",
+		URL:    "http://x/",
+		Body:   map[string]string{"password": "{{env `DB_PASSWORD`}}"},
+	}}}}
+	err := Reload(pins)
+	if err == nil || !strings.Contains(err.Error(), `"DB_PASSWORD" can't be used in templates`) {
+		t.Fatalf("expected a template reading DB_PASSWORD to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Divider sensor states used in a topology.
+const (
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+)
+
+var (
+	// ErrNoConfiguration is returned when no room configuration matches the divider sensors.
+	ErrNoConfiguration = errors.New("no room configuration matches the divider sensors")
+	// ErrUnknownHostname is returned when the current room configuration has no preset for a hostname.
+	ErrUnknownHostname = errors.New("not a valid hostname")
+	// ErrNotSupported is returned for preset lookups in a room with several sensors and no topology.
+	ErrNotSupported = errors.New("not supported in this room")
+)
+
+// Topology maps combinations of divider sensor states to room configurations, for rooms with
+// more than one divider. Configurations are checked in order and the first match wins.
+type Topology struct {
+	Configurations []RoomConfiguration `json:"configurations"`
+}
+
+// RoomConfiguration is a named layout of a divisible room. When maps sensor names to the
+// state ("connected" or "disconnected") they must be in; sensors left out can be in either.
+// Presets maps each hostname to the preset it should be on in this layout.
+type RoomConfiguration struct {
+	Name    string            `json:"name"`
+	When    map[string]string `json:"when"`
+	Presets map[string]string `json:"presets"`
+}
+
+// DividerStatus is the combined state of the divider sensors.
+type DividerStatus struct {
+	// Configuration is the name of the matching room configuration, if there's a topology and one matches.
+	Configuration string `json:"configuration,omitempty"`
+	// Sensors is the state of each sensor, by name.
+	Sensors map[string]string `json:"sensors"`
+}
+
+var (
+	topologyMu sync.RWMutex
+	topology   Topology
+)
+
+// SetTopology validates t against the configured pins and replaces the current topology.
+// An empty topology falls back to a single pin's presets.
+func SetTopology(t Topology) error {
+	// hold off reloads so t can't be checked against pins that are about to change
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := validateTopology(t, GetPins()); err != nil {
+		return err
+	}
+
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	topology = t
+	return nil
+}
+
+// revalidateTopology clears the topology if it refers to sensors that aren't in pins, since it
+// could otherwise never match. It's called when the pins change.
+func revalidateTopology(pins []Pin) {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+
+	if err := validateTopology(topology, pins); err != nil {
+		log.Warn().Err(err).Msg("clearing the divider topology, it no longer matches the pins")
+		topology = Topology{}
+	}
+}
+
+// validateTopology checks that each room configuration in t has a unique name and only refers to
+// sensors in pins.
+func validateTopology(t Topology, pins []Pin) error {
+	sensors := make(map[string]bool)
+	for _, p := range pins {
+		sensors[p.SensorName()] = true
+	}
+
+	names := make(map[string]bool, len(t.Configurations))
+	for i, cfg := range t.Configurations {
+		switch {
+		case cfg.Name == "":
+			return fmt.Errorf("room configuration %d is missing a name", i)
+		case names[cfg.Name]:
+			return fmt.Errorf("room configuration %q is configured more than once", cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		for sensor, state := range cfg.When {
+			if !sensors[sensor] {
+				return fmt.Errorf("room configuration %q: unknown divider sensor %q", cfg.Name, sensor)
+			}
+			if state != StateConnected && state != StateDisconnected {
+				return fmt.Errorf("room configuration %q: invalid state %q for sensor %q, must be connected or disconnected", cfg.Name, state, sensor)
+			}
+		}
+	}
+	return nil
+}
+
+// GetTopology returns the current topology.
+func GetTopology() Topology {
+	topologyMu.RLock()
+	defer topologyMu.RUnlock()
+	return topology
+}
+
+// CurrentStatus returns the state of each sensor and the room configuration it matches.
+func CurrentStatus() DividerStatus {
+	status := DividerStatus{Sensors: make(map[string]string)}
+	for _, p := range GetPins() {
+		status.Sensors[p.SensorName()] = p.State()
+	}
+
+	if cfg := matchConfiguration(status.Sensors); cfg != nil {
+		status.Configuration = cfg.Name
+	}
+	return status
+}
+
+// PresetFor returns the preset hostname should be on. With a topology it comes from the matching
+// room configuration; without one, the room must have a single sensor.
+func PresetFor(hostname string) (string, error) {
+	if len(GetTopology().Configurations) == 0 {
+		pins := GetPins()
+		if len(pins) != 1 {
+			return "", ErrNotSupported
+		}
+		return pins[0].CurrentPreset(hostname), nil
+	}
+
+	status := CurrentStatus()
+	cfg := matchConfiguration(status.Sensors)
+	if cfg == nil {
+		return "", ErrNoConfiguration
+	}
+
+	preset, ok := cfg.Presets[hostname]
+	if !ok {
+		return "", fmt.Errorf("%w for room configuration %q", ErrUnknownHostname, cfg.Name)
+	}
+	return preset, nil
+}
+
+// matchConfiguration returns the first room configuration matching sensors, or nil.
+func matchConfiguration(sensors map[string]string) *RoomConfiguration {
+	topologyMu.RLock()
+	defer topologyMu.RUnlock()
+
+	for i := range topology.Configurations {
+		cfg := &topology.Configurations[i]
+
+		matches := true
+		for sensor, state := range cfg.When {
+			if sensors[sensor] != state {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			c := *cfg
+			return &c
+		}
+	}
+	return nil
+}
+
+// SensorName is the name of the sensor in a topology: Name if it's set, otherwise its line.
+func (p Pin) SensorName() string {
+	switch {
+	case p.Name != "":
+		return p.Name
+	case p.Line != "":
+		return string(p.Line)
+	}
+	return strconv.Itoa(p.Pin)
+}
+
+// State returns "connected" or "disconnected".
+func (p Pin) State() string {
+	if p.Connected {
+		return StateConnected
+	}
+	return StateDisconnected
+}
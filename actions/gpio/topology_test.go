@@ -0,0 +1,111 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTopology(t *testing.T) {
+	sim := NewSimulator()
+	SetBackend(sim)
+	t.Cleanup(func() {
+		Shutdown()
+		SetTopology(Topology{})
+		SetBackend(newCDevBackend(""))
+	})
+
+	pins := []Pin{{Name: "north", Pin: 10, Mode: ModePoll, ReadFrequency: "5ms", ReadsBeforeChange: 1}, {Name: "south", Pin: 11, Mode: ModePoll, ReadFrequency: "5ms", ReadsBeforeChange: 1}}
+	if err := Reload(pins); err != nil {
+		t.Fatalf("failed to start monitors: %s", err)
+	}
+
+	if err := SetTopology(Topology{Configurations: []RoomConfiguration{{Name: "bad", When: map[string]string{"east": StateConnected}}}}); err == nil {
+		t.Error("expected an unknown sensor to be rejected")
+	}
+
+	err := SetTopology(Topology{Configurations: []RoomConfiguration{
+		{Name: "split", When: map[string]string{"north": StateConnected, "south": StateConnected}, Presets: map[string]string{"cp1": "A", "cp2": "C"}},
+		{Name: "north-split", When: map[string]string{"north": StateConnected}, Presets: map[string]string{"cp1": "A", "cp2": "BC"}},
+		{Name: "open", When: map[string]string{"north": StateDisconnected, "south": StateDisconnected}, Presets: map[string]string{"cp1": "ABC"}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to set topology: %s", err)
+	}
+
+	waitFor := func(configuration string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for CurrentStatus().Configuration != configuration {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected configuration %q, got %+v", configuration, CurrentStatus())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("open")
+	if preset, err := PresetFor("cp1"); err != nil || preset != "ABC" {
+		t.Errorf("expected ABC, got %q (%v)", preset, err)
+	}
+	if _, err := PresetFor("cp2"); !errors.Is(err, ErrUnknownHostname) {
+		t.Errorf("expected ErrUnknownHostname, got %v", err)
+	}
+
+	sim.Set(10, HIGH)
+	waitFor("north-split")
+	if preset, err := PresetFor("cp2"); err != nil || preset != "BC" {
+		t.Errorf("expected BC, got %q (%v)", preset, err)
+	}
+
+	sim.Set(11, HIGH)
+	waitFor("split")
+
+	sim.Set(10, LOW)
+	waitFor("")
+	if _, err := PresetFor("cp1"); !errors.Is(err, ErrNoConfiguration) {
+		t.Errorf("expected ErrNoConfiguration, got %v", err)
+	}
+}
+
+func TestReloadRevalidatesTopology(t *testing.T) {
+	SetBackend(NewSimulator())
+	t.Cleanup(func() {
+		Shutdown()
+		SetTopology(Topology{})
+		SetBackend(newCDevBackend(""))
+	})
+
+	north := Pin{Name: "north", Pin: 10, Mode: ModePoll, ReadFrequency: "5ms", ReadsBeforeChange: 1}
+	south := Pin{Name: "south", Pin: 11, Mode: ModePoll, ReadFrequency: "5ms", ReadsBeforeChange: 1}
+	if err := Reload([]Pin{north, south}); err != nil {
+		t.Fatalf("failed to start monitors: %s", err)
+	}
+
+	northOnly := Topology{Configurations: []RoomConfiguration{
+		{Name: "north-split", When: map[string]string{"north": StateConnected}, Presets: map[string]string{"cp1": "A"}},
+		{Name: "open", When: map[string]string{"north": StateDisconnected}, Presets: map[string]string{"cp1": "AB"}},
+	}}
+	if err := SetTopology(northOnly); err != nil {
+		t.Fatalf("failed to set topology: %s", err)
+	}
+
+	// a topology that only uses the remaining sensors is kept
+	if err := Reload([]Pin{north}); err != nil {
+		t.Fatalf("failed to reload monitors: %s", err)
+	}
+	if got := len(GetTopology().Configurations); got != 2 {
+		t.Fatalf("expected the topology to be kept, got %d configurations", got)
+	}
+
+	// one that uses a removed sensor is cleared
+	if err := Reload([]Pin{south}); err != nil {
+		t.Fatalf("failed to reload monitors: %s", err)
+	}
+	if got := GetTopology(); len(got.Configurations) != 0 {
+		t.Errorf("expected the topology to be cleared, got %+v", got)
+	}
+	if _, err := PresetFor("cp1"); errors.Is(err, ErrNoConfiguration) {
+		t.Errorf("expected the single pin's presets without a topology, got %v", err)
+	}
+}
@@ -21,6 +21,7 @@ const (
 )
 
 // RoomDevicesInfo queries every non‑Pi device in the room for its hardware info.
+// Devices without a HardwareInfo command are queried over SNMP if their device type has an SNMP config.
 // Returns a map of deviceID -> HardwareInfo, or an error if the room lookup or DB call fails.
 func RoomDevicesInfo(ctx context.Context) (map[string]model.HardwareInfo, error) {
 	// get the current room ID
@@ -48,15 +49,35 @@ func RoomDevicesInfo(ctx context.Context) (map[string]model.HardwareInfo, error)
 		// skip the pi's
 		if devices[i].Type.ID == "Pi3" ||
 			devices[i].Address == "0.0.0.0" ||
-			len(devices[i].Address) == 0 ||
-			!devices[i].HasCommand(hardwareInfoCommandID) {
+			len(devices[i].Address) == 0 {
 			slog.Debug("Skipping device", slog.Any("device", devices[i]))
 			continue
 		}
+
+		hasCommand := devices[i].HasCommand(hardwareInfoCommandID)
+		snmp, timeout, hasSNMP := snmpConfigFor(devices[i].Type.ID)
+		if !hasCommand && !hasSNMP {
+			slog.Debug("Skipping device", slog.Any("device", devices[i]))
+			continue
+		}
+
 		wg.Add(1)
 		go func(d model.Device) {
 			defer wg.Done()
-			hw := getHardwareInfo(ctx, d)
+
+			var hw model.HardwareInfo
+			if hasCommand {
+				hw = getHardwareInfo(ctx, d)
+			} else {
+				var err error
+				hw, err = getSNMPHardwareInfo(ctx, d, snmp, timeout)
+				if err != nil {
+					slog.Warn("snmp hardware info request failed",
+						slog.String("device", d.ID),
+						slog.Any("error", err),
+					)
+				}
+			}
 
 			mu.Lock()
 			infoMap[d.ID] = hw
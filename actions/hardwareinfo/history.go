@@ -0,0 +1,168 @@
+package hardwareinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+const (
+	historyKey = "hardware-info-history"
+
+	// maxChangesPerDevice caps how many changes are kept for each device.
+	maxChangesPerDevice = 100
+
+	// DeviceReplaced is the event key sent when a device's serial number, model or MAC address changes.
+	DeviceReplaced = "device-replaced"
+	// FirmwareChanged is the event key sent when a device's firmware version changes.
+	FirmwareChanged = "firmware-changed"
+)
+
+// HardwareChange is a single change in a device's hardware info.
+type HardwareChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	DeviceID  string    `json:"device-id"`
+	Kind      string    `json:"kind"`
+	Field     string    `json:"field"`
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+}
+
+// DeviceHistory is the last known hardware info of a device, and how it has changed.
+type DeviceHistory struct {
+	Last      model.HardwareInfo `json:"last"`
+	UpdatedAt time.Time          `json:"updated-at"`
+	Changes   []HardwareChange   `json:"changes"`
+}
+
+var historyMu sync.Mutex
+
+// RecordHardwareInfo compares info against the last known info for each device,
+// persists the new info, and returns the changes that were detected. The stored history is left
+// alone if it can't be read, so every device isn't reported as new the next time.
+func RecordHardwareInfo(info map[string]model.HardwareInfo) ([]HardwareChange, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	history, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := recordHardwareInfo(history, info, time.Now())
+	if err := saveHistory(history); err != nil {
+		return changes, err
+	}
+
+	return changes, nil
+}
+
+// recordHardwareInfo updates history with info, returning the changes since each device's last info.
+func recordHardwareInfo(history map[string]DeviceHistory, info map[string]model.HardwareInfo, now time.Time) []HardwareChange {
+	var changes []HardwareChange
+	for id, cur := range info {
+		// a device that didn't respond comes back empty; don't treat that as a change
+		if isEmptyHardwareInfo(cur) {
+			continue
+		}
+
+		h, ok := history[id]
+		if ok {
+			for _, c := range diffHardwareInfo(h.Last, cur) {
+				c.Timestamp = now
+				c.DeviceID = id
+				changes = append(changes, c)
+				h.Changes = append(h.Changes, c)
+			}
+			if len(h.Changes) > maxChangesPerDevice {
+				h.Changes = h.Changes[len(h.Changes)-maxChangesPerDevice:]
+			}
+		}
+
+		h.Last = cur
+		h.UpdatedAt = now
+		history[id] = h
+	}
+
+	return changes
+}
+
+// History returns the hardware history of every device that has reported hardware info.
+func History() (map[string]DeviceHistory, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	return loadHistory()
+}
+
+// diffHardwareInfo returns the identity and firmware fields that differ between old and cur.
+// Fields that are empty on either side are ignored, since devices don't always report every field.
+func diffHardwareInfo(old, cur model.HardwareInfo) []HardwareChange {
+	fields := []struct {
+		kind, name string
+		old, cur   string
+	}{
+		{DeviceReplaced, "serial-number", old.SerialNumber, cur.SerialNumber},
+		{DeviceReplaced, "model-name", old.ModelName, cur.ModelName},
+		{DeviceReplaced, "mac-address", old.NetworkInfo.MACAddress, cur.NetworkInfo.MACAddress},
+		{FirmwareChanged, "firmware-version", old.FirmwareVersion, cur.FirmwareVersion},
+	}
+
+	var changes []HardwareChange
+	for _, f := range fields {
+		if f.old == "" || f.cur == "" || f.old == f.cur {
+			continue
+		}
+
+		changes = append(changes, HardwareChange{
+			Kind:  f.kind,
+			Field: f.name,
+			Old:   f.old,
+			New:   f.cur,
+		})
+	}
+
+	return changes
+}
+
+func isEmptyHardwareInfo(info model.HardwareInfo) bool {
+	return info.SerialNumber == "" &&
+		info.ModelName == "" &&
+		info.FirmwareVersion == "" &&
+		info.NetworkInfo.MACAddress == ""
+}
+
+func loadHistory() (map[string]DeviceHistory, error) {
+	history := make(map[string]DeviceHistory)
+
+	b, nerr := dmdb.Get(historyKey)
+	if nerr != nil {
+		return history, fmt.Errorf("failed to load hardware info history: %w", nerr)
+	}
+	if len(b) == 0 {
+		return history, nil
+	}
+
+	if err := json.Unmarshal(b, &history); err != nil {
+		return history, fmt.Errorf("failed to parse hardware info history: %w", err)
+	}
+
+	return history, nil
+}
+
+func saveHistory(history map[string]DeviceHistory) error {
+	b, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to save hardware info history: %w", err)
+	}
+
+	if nerr := dmdb.Put(historyKey, b); nerr != nil {
+		return fmt.Errorf("failed to save hardware info history: %w", nerr)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package hardwareinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hardwareinfo")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("DMDB_PATH", filepath.Join(dir, "db"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func projector(serial, firmware string) model.HardwareInfo {
+	return model.HardwareInfo{
+		ModelName:       "VPL-FHZ75",
+		SerialNumber:    serial,
+		FirmwareVersion: firmware,
+		NetworkInfo:     model.NetworkInfo{MACAddress: "00:11:22:33:44:55"},
+	}
+}
+
+func TestDiffHardwareInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		old  model.HardwareInfo
+		cur  model.HardwareInfo
+		want []HardwareChange
+	}{
+		{
+			name: "unchanged",
+			old:  projector("1001", "1.0"),
+			cur:  projector("1001", "1.0"),
+		},
+		{
+			name: "replaced",
+			old:  projector("1001", "1.0"),
+			cur:  projector("2002", "1.0"),
+			want: []HardwareChange{{Kind: DeviceReplaced, Field: "serial-number", Old: "1001", New: "2002"}},
+		},
+		{
+			name: "firmware",
+			old:  projector("1001", "1.0"),
+			cur:  projector("1001", "1.1"),
+			want: []HardwareChange{{Kind: FirmwareChanged, Field: "firmware-version", Old: "1.0", New: "1.1"}},
+		},
+		{
+			name: "field missing from the new info",
+			old:  projector("1001", "1.0"),
+			cur:  projector("1001", ""),
+		},
+		{
+			name: "field missing from the old info",
+			old:  projector("", "1.0"),
+			cur:  projector("1001", "1.0"),
+		},
+		{
+			name: "model and mac",
+			old:  projector("1001", "1.0"),
+			cur: model.HardwareInfo{
+				ModelName:       "VPL-FHZ80",
+				SerialNumber:    "1001",
+				FirmwareVersion: "1.0",
+				NetworkInfo:     model.NetworkInfo{MACAddress: "00:11:22:33:44:66"},
+			},
+			want: []HardwareChange{
+				{Kind: DeviceReplaced, Field: "model-name", Old: "VPL-FHZ75", New: "VPL-FHZ80"},
+				{Kind: DeviceReplaced, Field: "mac-address", Old: "00:11:22:33:44:55", New: "00:11:22:33:44:66"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffHardwareInfo(tt.old, tt.cur)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("change %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsEmptyHardwareInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  model.HardwareInfo
+		empty bool
+	}{
+		{name: "no response", info: model.HardwareInfo{}, empty: true},
+		{name: "only status", info: model.HardwareInfo{PowerStatus: "standby", Hostname: "proj1"}, empty: true},
+		{name: "serial", info: model.HardwareInfo{SerialNumber: "1001"}},
+		{name: "mac", info: model.HardwareInfo{NetworkInfo: model.NetworkInfo{MACAddress: "00:11:22:33:44:55"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmptyHardwareInfo(tt.info); got != tt.empty {
+				t.Errorf("got %v, want %v", got, tt.empty)
+			}
+		})
+	}
+}
+
+func TestRecordHardwareInfo(t *testing.T) {
+	history := make(map[string]DeviceHistory)
+	now := time.Now()
+
+	// the first info of a device isn't a change
+	if changes := recordHardwareInfo(history, map[string]model.HardwareInfo{"ITB-1101-D1": projector("1001", "1.0")}, now); len(changes) != 0 {
+		t.Fatalf("expected no changes for a new device, got %+v", changes)
+	}
+
+	// neither is a device that didn't respond, which mustn't replace its last info
+	if changes := recordHardwareInfo(history, map[string]model.HardwareInfo{"ITB-1101-D1": {}}, now.Add(time.Minute)); len(changes) != 0 {
+		t.Fatalf("expected no changes for a device that didn't respond, got %+v", changes)
+	}
+	if got := history["ITB-1101-D1"].Last.SerialNumber; got != "1001" {
+		t.Fatalf("expected the last info to be kept, got serial %q", got)
+	}
+
+	changes := recordHardwareInfo(history, map[string]model.HardwareInfo{"ITB-1101-D1": projector("2002", "1.0")}, now.Add(2*time.Minute))
+	if len(changes) != 1 || changes[0].DeviceID != "ITB-1101-D1" || !changes[0].Timestamp.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("expected the device replacement, got %+v", changes)
+	}
+	if got := len(history["ITB-1101-D1"].Changes); got != 1 {
+		t.Errorf("expected 1 change in the history, got %d", got)
+	}
+}
+
+func TestRecordHardwareInfoCapsChanges(t *testing.T) {
+	history := make(map[string]DeviceHistory)
+	now := time.Now()
+
+	for i := 0; i < maxChangesPerDevice+10; i++ {
+		firmware := "1.0"
+		if i%2 == 1 {
+			firmware = "1.1"
+		}
+		recordHardwareInfo(history, map[string]model.HardwareInfo{"ITB-1101-D1": projector("1001", firmware)}, now.Add(time.Duration(i)*time.Minute))
+	}
+
+	changes := history["ITB-1101-D1"].Changes
+	if len(changes) != maxChangesPerDevice {
+		t.Fatalf("expected %d changes, got %d", maxChangesPerDevice, len(changes))
+	}
+	// the oldest are dropped
+	if want := now.Add(time.Duration(maxChangesPerDevice+9) * time.Minute); !changes[len(changes)-1].Timestamp.Equal(want) {
+		t.Errorf("expected the newest change last, got %s", changes[len(changes)-1].Timestamp)
+	}
+	if want := now.Add(10 * time.Minute); !changes[0].Timestamp.Equal(want) {
+		t.Errorf("expected the oldest kept change at %s, got %s", want, changes[0].Timestamp)
+	}
+}
+
+func TestRecordHardwareInfoKeepsUnreadableHistory(t *testing.T) {
+	corrupt := []byte(`{"ITB-1101-D1": {"last": `)
+	if err := dmdb.Put(historyKey, corrupt); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	t.Cleanup(func() { dmdb.Put(historyKey, nil) })
+
+	if _, err := RecordHardwareInfo(map[string]model.HardwareInfo{"ITB-1101-D1": projector("1001", "1.0")}); err == nil {
+		t.Fatalf("expected an error for an unreadable history")
+	}
+
+	b, err := dmdb.Get(historyKey)
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	if string(b) != string(corrupt) {
+		t.Errorf("expected the stored history to be left alone, got %q", b)
+	}
+}
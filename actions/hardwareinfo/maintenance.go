@@ -0,0 +1,347 @@
+package hardwareinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+const (
+	maintenanceKey = "maintenance-history"
+
+	// TimerLamp and TimerFilter are the kinds of usage timers that are tracked.
+	TimerLamp   = "lamp"
+	TimerFilter = "filter"
+
+	// MaintenanceDue is the event key sent when a lamp or filter is close to the end of its life.
+	MaintenanceDue = "maintenance-due"
+
+	// usage rates are calculated over this much history
+	rateWindow = 30 * 24 * time.Hour
+	// a new sample is kept if the hours changed, or the last one is older than this
+	sampleEvery       = 6 * time.Hour
+	maxTimerSamples   = 500
+	defaultWarnDays   = 30
+	defaultWarnHours  = 100
+	minRateWindowDays = 1
+)
+
+// MaintenanceLimits is the rated life of a projector's lamp and filter.
+type MaintenanceLimits struct {
+	LampLifeHours   int `json:"lamp-life-hours,omitempty"`
+	FilterLifeHours int `json:"filter-life-hours,omitempty"`
+}
+
+// MaintenanceConfig sets the rated lamp/filter life, optionally per model, and how early to warn.
+type MaintenanceConfig struct {
+	MaintenanceLimits
+	WarnRemainingHours int                          `json:"warn-remaining-hours,omitempty"`
+	WarnDays           int                          `json:"warn-days,omitempty"`
+	Models             map[string]MaintenanceLimits `json:"models,omitempty"`
+}
+
+// Timer is a typed usage timer from a device's timer info.
+type Timer struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Hours int    `json:"hours"`
+}
+
+// Forecast is the usage rate of a timer and when it is expected to need replacing.
+type Forecast struct {
+	DeviceID       string     `json:"device-id"`
+	Timer          string     `json:"timer"`
+	Kind           string     `json:"kind"`
+	Hours          int        `json:"hours"`
+	LifeHours      int        `json:"life-hours,omitempty"`
+	RemainingHours int        `json:"remaining-hours,omitempty"`
+	HoursPerDay    float64    `json:"hours-per-day"`
+	ReplaceBy      *time.Time `json:"replace-by,omitempty"`
+	Due            bool       `json:"due"`
+}
+
+type timerSample struct {
+	At    time.Time `json:"at"`
+	Hours int       `json:"hours"`
+}
+
+type timerHistory struct {
+	Kind    string        `json:"kind"`
+	Model   string        `json:"model,omitempty"`
+	Samples []timerSample `json:"samples"`
+	Due     bool          `json:"due"`
+}
+
+var (
+	maintenanceMu  sync.Mutex
+	maintenanceCfg MaintenanceConfig
+)
+
+// SetMaintenanceConfig sets the lamp/filter life used for forecasts.
+func SetMaintenanceConfig(cfg MaintenanceConfig) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	maintenanceCfg = cfg
+}
+
+// limitsFor returns the lamp/filter life for a model, falling back to the defaults.
+func (c MaintenanceConfig) limitsFor(modelName string) MaintenanceLimits {
+	limits := c.MaintenanceLimits
+	if m, ok := c.Models[modelName]; ok {
+		if m.LampLifeHours > 0 {
+			limits.LampLifeHours = m.LampLifeHours
+		}
+		if m.FilterLifeHours > 0 {
+			limits.FilterLifeHours = m.FilterLifeHours
+		}
+	}
+	return limits
+}
+
+// ParseTimers pulls the lamp and filter hours out of a device's timer info.
+// Keys containing "lamp" or "filter" are kept; anything else is ignored.
+func ParseTimers(info []map[string]int) []Timer {
+	var timers []Timer
+	for _, m := range info {
+		for name, hours := range m {
+			lower := strings.ToLower(name)
+
+			var kind string
+			switch {
+			case strings.Contains(lower, TimerLamp):
+				kind = TimerLamp
+			case strings.Contains(lower, TimerFilter):
+				kind = TimerFilter
+			default:
+				continue
+			}
+
+			timers = append(timers, Timer{Name: name, Kind: kind, Hours: hours})
+		}
+	}
+
+	sort.Slice(timers, func(i, j int) bool { return timers[i].Name < timers[j].Name })
+	return timers
+}
+
+// RecordTimers tracks the lamp/filter timers of each device and returns a forecast for each timer,
+// along with the forecasts whose due state changed since the last call. The stored history is left
+// alone if it can't be read, rather than replaced with what was seen this time.
+func RecordTimers(info map[string]model.HardwareInfo) (forecasts []Forecast, changed []Forecast, err error) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	history, err := loadMaintenance()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	forecasts, changed = recordTimers(history, info, maintenanceCfg, time.Now())
+	if err := saveMaintenance(history); err != nil {
+		return forecasts, changed, err
+	}
+
+	return forecasts, changed, nil
+}
+
+// recordTimers adds the timers in info to history, and returns a forecast for each of them along
+// with the ones whose due state changed.
+func recordTimers(history map[string]map[string]timerHistory, info map[string]model.HardwareInfo, cfg MaintenanceConfig, now time.Time) (forecasts []Forecast, changed []Forecast) {
+	for id, hw := range info {
+		timers := ParseTimers(hw.TimerInfo)
+		if len(timers) == 0 {
+			continue
+		}
+
+		if history[id] == nil {
+			history[id] = make(map[string]timerHistory)
+		}
+		limits := cfg.limitsFor(hw.ModelName)
+
+		for _, t := range timers {
+			h := history[id][t.Name]
+			h.Kind = t.Kind
+			h.Model = hw.ModelName
+			h.Samples = addTimerSample(h.Samples, timerSample{At: now, Hours: t.Hours})
+
+			f := forecast(id, t, h.Samples, limits, cfg, now)
+			if f.Due != h.Due {
+				changed = append(changed, f)
+			}
+			h.Due = f.Due
+
+			history[id][t.Name] = h
+			forecasts = append(forecasts, f)
+		}
+	}
+
+	return forecasts, changed
+}
+
+// Forecasts returns the latest forecast for every tracked timer.
+func Forecasts() ([]Forecast, error) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	history, err := loadMaintenance()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	forecasts := []Forecast{}
+	for id, timers := range history {
+		for name, h := range timers {
+			if len(h.Samples) == 0 {
+				continue
+			}
+
+			last := h.Samples[len(h.Samples)-1]
+			t := Timer{Name: name, Kind: h.Kind, Hours: last.Hours}
+			f := forecast(id, t, h.Samples, maintenanceCfg.limitsFor(h.Model), maintenanceCfg, now)
+			f.Due = h.Due
+			forecasts = append(forecasts, f)
+		}
+	}
+
+	sort.Slice(forecasts, func(i, j int) bool {
+		if forecasts[i].DeviceID != forecasts[j].DeviceID {
+			return forecasts[i].DeviceID < forecasts[j].DeviceID
+		}
+		return forecasts[i].Timer < forecasts[j].Timer
+	})
+	return forecasts, nil
+}
+
+// EventKey returns the timer name normalized for use in event keys, e.g. "Lamp1_Hours" -> "lamp1-hours".
+func (t Timer) EventKey() string {
+	key := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(t.Name))
+
+	key = strings.Trim(key, "-")
+	if !strings.HasSuffix(key, "hours") {
+		key += "-hours"
+	}
+	return key
+}
+
+// addTimerSample appends a sample, starting over if the hours went down (the lamp or filter was replaced).
+func addTimerSample(samples []timerSample, s timerSample) []timerSample {
+	if len(samples) > 0 {
+		last := samples[len(samples)-1]
+		switch {
+		case s.Hours < last.Hours:
+			return []timerSample{s}
+		case s.Hours == last.Hours && s.At.Sub(last.At) < sampleEvery:
+			return samples
+		}
+	}
+
+	samples = append(samples, s)
+	if len(samples) > maxTimerSamples {
+		samples = samples[len(samples)-maxTimerSamples:]
+	}
+	return samples
+}
+
+func forecast(deviceID string, t Timer, samples []timerSample, limits MaintenanceLimits, cfg MaintenanceConfig, now time.Time) Forecast {
+	f := Forecast{
+		DeviceID: deviceID,
+		Timer:    t.Name,
+		Kind:     t.Kind,
+		Hours:    t.Hours,
+	}
+
+	switch t.Kind {
+	case TimerLamp:
+		f.LifeHours = limits.LampLifeHours
+	case TimerFilter:
+		f.LifeHours = limits.FilterLifeHours
+	}
+
+	// usage rate over the recent samples
+	var first *timerSample
+	for i := range samples {
+		if now.Sub(samples[i].At) <= rateWindow {
+			first = &samples[i]
+			break
+		}
+	}
+	if first != nil {
+		days := now.Sub(first.At).Hours() / 24
+		if days >= minRateWindowDays {
+			f.HoursPerDay = math.Round(float64(t.Hours-first.Hours)/days*100) / 100
+		}
+	}
+
+	if f.LifeHours <= 0 {
+		return f
+	}
+
+	f.RemainingHours = f.LifeHours - t.Hours
+	if f.RemainingHours < 0 {
+		f.RemainingHours = 0
+	}
+
+	if f.HoursPerDay > 0 {
+		replaceBy := now.Add(time.Duration(float64(f.RemainingHours) / f.HoursPerDay * 24 * float64(time.Hour)))
+		f.ReplaceBy = &replaceBy
+	}
+
+	warnHours := cfg.WarnRemainingHours
+	if warnHours <= 0 {
+		warnHours = defaultWarnHours
+	}
+	warnDays := cfg.WarnDays
+	if warnDays <= 0 {
+		warnDays = defaultWarnDays
+	}
+
+	f.Due = f.RemainingHours <= warnHours ||
+		(f.ReplaceBy != nil && f.ReplaceBy.Before(now.AddDate(0, 0, warnDays)))
+	return f
+}
+
+func loadMaintenance() (map[string]map[string]timerHistory, error) {
+	history := make(map[string]map[string]timerHistory)
+
+	b, nerr := dmdb.Get(maintenanceKey)
+	if nerr != nil {
+		return history, fmt.Errorf("failed to load maintenance history: %w", nerr)
+	}
+	if len(b) == 0 {
+		return history, nil
+	}
+
+	if err := json.Unmarshal(b, &history); err != nil {
+		return history, fmt.Errorf("failed to parse maintenance history: %w", err)
+	}
+
+	return history, nil
+}
+
+func saveMaintenance(history map[string]map[string]timerHistory) error {
+	b, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to save maintenance history: %w", err)
+	}
+
+	if nerr := dmdb.Put(maintenanceKey, b); nerr != nil {
+		return fmt.Errorf("failed to save maintenance history: %w", nerr)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package hardwareinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/dmdb"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+func TestParseTimers(t *testing.T) {
+	timers := ParseTimers([]map[string]int{
+		{"Lamp1_Hours": 1200, "Power_On_Hours": 4000},
+		{"FILTER": 300, "Lamp2_Hours": 900},
+	})
+
+	want := []Timer{
+		{Name: "FILTER", Kind: TimerFilter, Hours: 300},
+		{Name: "Lamp1_Hours", Kind: TimerLamp, Hours: 1200},
+		{Name: "Lamp2_Hours", Kind: TimerLamp, Hours: 900},
+	}
+	if len(timers) != len(want) {
+		t.Fatalf("got %+v, want %+v", timers, want)
+	}
+	for i := range want {
+		if timers[i] != want[i] {
+			t.Errorf("timer %d: got %+v, want %+v", i, timers[i], want[i])
+		}
+	}
+
+	if got := ParseTimers(nil); len(got) != 0 {
+		t.Errorf("expected no timers, got %+v", got)
+	}
+}
+
+func TestTimerEventKey(t *testing.T) {
+	tests := map[string]string{
+		"Lamp1_Hours": "lamp1-hours",
+		"FILTER":      "filter-hours",
+		"lamp hours":  "lamp-hours",
+	}
+	for name, want := range tests {
+		if got := (Timer{Name: name}).EventKey(); got != want {
+			t.Errorf("%q: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAddTimerSample(t *testing.T) {
+	start := time.Now()
+	samples := addTimerSample(nil, timerSample{At: start, Hours: 100})
+
+	// the same hours aren't kept again until sampleEvery has passed
+	samples = addTimerSample(samples, timerSample{At: start.Add(time.Hour), Hours: 100})
+	if len(samples) != 1 {
+		t.Fatalf("expected the unchanged sample to be skipped, got %d samples", len(samples))
+	}
+	samples = addTimerSample(samples, timerSample{At: start.Add(sampleEvery), Hours: 100})
+	if len(samples) != 2 {
+		t.Fatalf("expected an unchanged sample after %s, got %d samples", sampleEvery, len(samples))
+	}
+
+	// new hours always are
+	samples = addTimerSample(samples, timerSample{At: start.Add(sampleEvery + time.Minute), Hours: 101})
+	if len(samples) != 3 {
+		t.Fatalf("expected a sample with new hours, got %d samples", len(samples))
+	}
+
+	// fewer hours means the lamp was replaced, so the history starts over
+	samples = addTimerSample(samples, timerSample{At: start.Add(2 * sampleEvery), Hours: 2})
+	if len(samples) != 1 || samples[0].Hours != 2 {
+		t.Fatalf("expected the samples to start over, got %+v", samples)
+	}
+}
+
+func TestAddTimerSampleCap(t *testing.T) {
+	start := time.Now()
+	var samples []timerSample
+	for i := 0; i < maxTimerSamples+20; i++ {
+		samples = addTimerSample(samples, timerSample{At: start.Add(time.Duration(i) * time.Minute), Hours: i})
+	}
+
+	if len(samples) != maxTimerSamples {
+		t.Fatalf("expected %d samples, got %d", maxTimerSamples, len(samples))
+	}
+	if samples[0].Hours != 20 || samples[len(samples)-1].Hours != maxTimerSamples+19 {
+		t.Errorf("expected the oldest samples to be dropped, got %d to %d", samples[0].Hours, samples[len(samples)-1].Hours)
+	}
+}
+
+func TestLimitsFor(t *testing.T) {
+	cfg := MaintenanceConfig{
+		MaintenanceLimits: MaintenanceLimits{LampLifeHours: 3000, FilterLifeHours: 2000},
+		Models: map[string]MaintenanceLimits{
+			"VPL-FHZ75": {LampLifeHours: 20000},
+			"EB-L1500":  {LampLifeHours: 20000, FilterLifeHours: 10000},
+		},
+	}
+
+	tests := []struct {
+		model string
+		want  MaintenanceLimits
+	}{
+		{model: "unknown", want: MaintenanceLimits{LampLifeHours: 3000, FilterLifeHours: 2000}},
+		{model: "VPL-FHZ75", want: MaintenanceLimits{LampLifeHours: 20000, FilterLifeHours: 2000}},
+		{model: "EB-L1500", want: MaintenanceLimits{LampLifeHours: 20000, FilterLifeHours: 10000}},
+	}
+	for _, tt := range tests {
+		if got := cfg.limitsFor(tt.model); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestForecast(t *testing.T) {
+	now := time.Now()
+	// 10 hours a day over the last 10 days, after an older sample outside of the rate window
+	samples := []timerSample{
+		{At: now.Add(-60 * 24 * time.Hour), Hours: 0},
+		{At: now.Add(-10 * 24 * time.Hour), Hours: 1000},
+		{At: now, Hours: 1100},
+	}
+	lamp := Timer{Name: "Lamp1_Hours", Kind: TimerLamp, Hours: 1100}
+
+	tests := []struct {
+		name          string
+		timer         Timer
+		samples       []timerSample
+		life          int
+		cfg           MaintenanceConfig
+		wantRate      float64
+		wantRemaining int
+		wantDays      float64 // days until it's due to be replaced, 0 for none
+		wantDue       bool
+	}{
+		{name: "plenty left", timer: lamp, samples: samples, life: 3000, wantRate: 10, wantRemaining: 1900, wantDays: 190},
+		{name: "within the warn hours", timer: lamp, samples: samples, life: 1150, wantRate: 10, wantRemaining: 50, wantDays: 5, wantDue: true},
+		{name: "outside the warn days", timer: lamp, samples: samples, life: 1500, wantRate: 10, wantRemaining: 400, wantDays: 40},
+		{
+			name: "within the configured warn days", timer: lamp, samples: samples, life: 1500,
+			cfg:      MaintenanceConfig{WarnRemainingHours: 10, WarnDays: 60},
+			wantRate: 10, wantRemaining: 400, wantDays: 40, wantDue: true,
+		},
+		{
+			name: "outside the configured warn hours", timer: lamp, samples: samples, life: 1150,
+			cfg:      MaintenanceConfig{WarnRemainingHours: 10, WarnDays: 1},
+			wantRate: 10, wantRemaining: 50, wantDays: 5,
+		},
+		{name: "past its life", timer: Timer{Name: "Lamp1_Hours", Kind: TimerLamp, Hours: 1100}, samples: samples, life: 1000, wantRate: 10, wantDue: true},
+		{name: "no rated life", timer: lamp, samples: samples, wantRate: 10},
+		{
+			name:          "less than a day of history",
+			timer:         lamp,
+			samples:       []timerSample{{At: now.Add(-time.Hour), Hours: 1099}, {At: now, Hours: 1100}},
+			life:          3000,
+			wantRemaining: 1900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := forecast("ITB-1101-D1", tt.timer, tt.samples, MaintenanceLimits{LampLifeHours: tt.life}, tt.cfg, now)
+
+			if f.HoursPerDay != tt.wantRate {
+				t.Errorf("expected %v hours a day, got %v", tt.wantRate, f.HoursPerDay)
+			}
+			if f.RemainingHours != tt.wantRemaining {
+				t.Errorf("expected %d hours remaining, got %d", tt.wantRemaining, f.RemainingHours)
+			}
+			switch {
+			case tt.wantDays == 0 && f.ReplaceBy != nil && tt.wantRemaining > 0:
+				t.Errorf("expected no replacement date, got %s", f.ReplaceBy)
+			case tt.wantDays > 0 && f.ReplaceBy == nil:
+				t.Errorf("expected a replacement date")
+			case tt.wantDays > 0:
+				if days := f.ReplaceBy.Sub(now).Hours() / 24; days < tt.wantDays-0.01 || days > tt.wantDays+0.01 {
+					t.Errorf("expected it to be replaced in %v days, got %v", tt.wantDays, days)
+				}
+			}
+			if f.Due != tt.wantDue {
+				t.Errorf("expected due to be %v", tt.wantDue)
+			}
+		})
+	}
+}
+
+func TestRecordTimersDueChanges(t *testing.T) {
+	history := make(map[string]map[string]timerHistory)
+	cfg := MaintenanceConfig{MaintenanceLimits: MaintenanceLimits{LampLifeHours: 1000}}
+	now := time.Now()
+
+	record := func(hours int, at time.Time) []Forecast {
+		t.Helper()
+		info := map[string]model.HardwareInfo{
+			"ITB-1101-D1": {ModelName: "VPL-FHZ75", TimerInfo: []map[string]int{{"Lamp1_Hours": hours}}},
+			"ITB-1101-D2": {ModelName: "unknown"},
+		}
+		forecasts, changed := recordTimers(history, info, cfg, at)
+		if len(forecasts) != 1 {
+			t.Fatalf("expected a forecast for the one timer, got %+v", forecasts)
+		}
+		return changed
+	}
+
+	if changed := record(800, now); len(changed) != 0 {
+		t.Fatalf("expected no change while there's plenty left, got %+v", changed)
+	}
+	if changed := record(950, now.Add(time.Hour)); len(changed) != 1 || !changed[0].Due {
+		t.Fatalf("expected the lamp to become due, got %+v", changed)
+	}
+	if changed := record(960, now.Add(2*time.Hour)); len(changed) != 0 {
+		t.Fatalf("expected no change while it stays due, got %+v", changed)
+	}
+	if changed := record(0, now.Add(3*time.Hour)); len(changed) != 1 || changed[0].Due {
+		t.Fatalf("expected the replaced lamp to no longer be due, got %+v", changed)
+	}
+
+	h := history["ITB-1101-D1"]["Lamp1_Hours"]
+	if h.Kind != TimerLamp || h.Model != "VPL-FHZ75" || len(h.Samples) != 1 {
+		t.Errorf("unexpected history %+v", h)
+	}
+	if _, ok := history["ITB-1101-D2"]; ok {
+		t.Errorf("expected no history for a device without timers")
+	}
+}
+
+func TestRecordTimersKeepsUnreadableHistory(t *testing.T) {
+	corrupt := []byte(`{"ITB-1101-D1": {"Lamp1_Hours": `)
+	if err := dmdb.Put(maintenanceKey, corrupt); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	t.Cleanup(func() { dmdb.Put(maintenanceKey, nil) })
+
+	info := map[string]model.HardwareInfo{"ITB-1101-D1": {TimerInfo: []map[string]int{{"Lamp1_Hours": 100}}}}
+	if _, _, err := RecordTimers(info); err == nil {
+		t.Fatalf("expected an error for an unreadable history")
+	}
+
+	b, err := dmdb.Get(maintenanceKey)
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	if string(b) != string(corrupt) {
+		t.Errorf("expected the stored history to be left alone, got %q", b)
+	}
+}
@@ -16,6 +16,8 @@ type HardwareInfo struct {
 	Network map[string]interface{} `json:"network,omitempty"`
 	Docker  map[string]interface{} `json:"docker,omitempty"`
 	Procs   map[string]interface{} `json:"procs,omitempty"`
+
+	Metrics map[string]localsystem.MetricSummary `json:"metrics,omitempty"`
 }
 
 // PiInfo .
@@ -59,5 +61,9 @@ func PiInfo() (HardwareInfo, error) {
 		return info, fmt.Errorf("failed to get hardware info: %w", err)
 	}
 
+	if sampler := localsystem.GetSampler(); sampler != nil {
+		info.Metrics = sampler.Summaries()
+	}
+
 	return info, nil
 }
@@ -0,0 +1,424 @@
+package hardwareinfo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/byuoitav/device-monitoring/model"
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	defaultSNMPPort    = 161
+	defaultSNMPTimeout = 5 * time.Second
+
+	oidIfDescr      = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfOperStatus = ".1.3.6.1.2.1.2.2.1.8"
+)
+
+// defaultSNMPFields are queried for every SNMP device unless its config overrides them.
+// The ENTITY-MIB fields are empty on agents that don't implement it.
+var defaultSNMPFields = map[string]string{
+	"description":      ".1.3.6.1.2.1.1.1.0",           // sysDescr
+	"uptime":           ".1.3.6.1.2.1.1.3.0",           // sysUpTime
+	"hostname":         ".1.3.6.1.2.1.1.5.0",           // sysName
+	"firmware_version": ".1.3.6.1.2.1.47.1.1.1.1.10.1", // entPhysicalSoftwareRev
+	"serial_number":    ".1.3.6.1.2.1.47.1.1.1.1.11.1", // entPhysicalSerialNum
+	"model_name":       ".1.3.6.1.2.1.47.1.1.1.1.13.1", // entPhysicalModelName
+}
+
+// ifOperStatus values, from IF-MIB
+var ifOperStatus = map[int]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+// SNMPConfig is how to reach devices of each device type over SNMP.
+type SNMPConfig struct {
+	Timeout     string                      `json:"timeout,omitempty"`
+	DeviceTypes map[string]SNMPDeviceConfig `json:"device-types,omitempty"`
+
+	timeout time.Duration
+}
+
+// SNMPDeviceConfig is the SNMP settings and OID-to-field mappings for a device type.
+// Fields are keyed by the json name of the model.HardwareInfo field (e.g. "serial_number")
+// and are merged over the defaults; an empty OID removes a default field.
+type SNMPDeviceConfig struct {
+	Version    string            `json:"version,omitempty"`
+	Port       uint16            `json:"port,omitempty"`
+	Community  string            `json:"community,omitempty"`
+	V3         *SNMPv3Config     `json:"v3,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Interfaces *bool             `json:"interfaces,omitempty"`
+}
+
+// SNMPv3Config is the user-based security settings for SNMP v3.
+type SNMPv3Config struct {
+	Username       string `json:"username"`
+	SecurityLevel  string `json:"security-level,omitempty"`
+	AuthProtocol   string `json:"auth-protocol,omitempty"`
+	AuthPassphrase string `json:"auth-passphrase,omitempty"`
+	PrivProtocol   string `json:"priv-protocol,omitempty"`
+	PrivPassphrase string `json:"priv-passphrase,omitempty"`
+	ContextName    string `json:"context-name,omitempty"`
+}
+
+var (
+	snmpMu  sync.RWMutex
+	snmpCfg SNMPConfig
+)
+
+// SetSNMPConfig validates and sets the SNMP config used for devices without a HardwareInfo command.
+func SetSNMPConfig(cfg SNMPConfig) error {
+	cfg.timeout = defaultSNMPTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid snmp timeout: %w", err)
+		}
+		cfg.timeout = d
+	}
+
+	for typeID, dc := range cfg.DeviceTypes {
+		if _, err := dc.client("127.0.0.1", cfg.timeout); err != nil {
+			return fmt.Errorf("invalid snmp config for %q: %w", typeID, err)
+		}
+		for field := range dc.Fields {
+			if !setHardwareField(&model.HardwareInfo{}, field, "") {
+				return fmt.Errorf("invalid snmp config for %q: unknown field %q", typeID, field)
+			}
+		}
+	}
+
+	snmpMu.Lock()
+	defer snmpMu.Unlock()
+	snmpCfg = cfg
+	return nil
+}
+
+// snmpConfigFor returns the SNMP config for a device type, if it has one.
+func snmpConfigFor(typeID string) (SNMPDeviceConfig, time.Duration, bool) {
+	snmpMu.RLock()
+	defer snmpMu.RUnlock()
+
+	dc, ok := snmpCfg.DeviceTypes[typeID]
+	return dc, snmpCfg.timeout, ok
+}
+
+// fields returns the configured OIDs merged over the defaults.
+func (c SNMPDeviceConfig) fields() map[string]string {
+	fields := make(map[string]string, len(defaultSNMPFields)+len(c.Fields))
+	for k, v := range defaultSNMPFields {
+		fields[k] = v
+	}
+	for k, v := range c.Fields {
+		if v == "" {
+			delete(fields, k)
+			continue
+		}
+		fields[k] = v
+	}
+	return fields
+}
+
+// client builds an (unconnected) SNMP client for the device at address.
+func (c SNMPDeviceConfig) client(address string, timeout time.Duration) (*gosnmp.GoSNMP, error) {
+	port := c.Port
+	if port == 0 {
+		port = defaultSNMPPort
+	}
+
+	// addresses may be given as host:port
+	if host, p, err := net.SplitHostPort(address); err == nil {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in address %q", address)
+		}
+		address, port = host, uint16(n)
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:             address,
+		Port:               port,
+		Transport:          "udp",
+		Timeout:            timeout,
+		Retries:            1,
+		ExponentialTimeout: true,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     20,
+	}
+
+	switch c.Version {
+	case "", "2c", "v2c":
+		client.Version = gosnmp.Version2c
+		client.Community = c.Community
+		if client.Community == "" {
+			client.Community = "public"
+		}
+	case "3", "v3":
+		if c.V3 == nil || c.V3.Username == "" {
+			return nil, fmt.Errorf("snmp v3 requires a username")
+		}
+
+		params, flags, err := c.V3.securityParameters()
+		if err != nil {
+			return nil, err
+		}
+
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = flags
+		client.SecurityParameters = params
+		client.ContextName = c.V3.ContextName
+	default:
+		return nil, fmt.Errorf("unsupported snmp version %q", c.Version)
+	}
+
+	return client, nil
+}
+
+func (c SNMPv3Config) securityParameters() (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	params := &gosnmp.UsmSecurityParameters{UserName: c.Username}
+
+	var flags gosnmp.SnmpV3MsgFlags
+	switch strings.ToLower(c.SecurityLevel) {
+	case "noauthnopriv":
+		flags = gosnmp.NoAuthNoPriv
+	case "authnopriv":
+		flags = gosnmp.AuthNoPriv
+	case "", "authpriv":
+		flags = gosnmp.AuthPriv
+	default:
+		return nil, 0, fmt.Errorf("unknown snmp v3 security level %q", c.SecurityLevel)
+	}
+
+	if flags&gosnmp.AuthNoPriv != 0 {
+		switch strings.ToUpper(c.AuthProtocol) {
+		case "MD5":
+			params.AuthenticationProtocol = gosnmp.MD5
+		case "", "SHA":
+			params.AuthenticationProtocol = gosnmp.SHA
+		case "SHA224":
+			params.AuthenticationProtocol = gosnmp.SHA224
+		case "SHA256":
+			params.AuthenticationProtocol = gosnmp.SHA256
+		case "SHA384":
+			params.AuthenticationProtocol = gosnmp.SHA384
+		case "SHA512":
+			params.AuthenticationProtocol = gosnmp.SHA512
+		default:
+			return nil, 0, fmt.Errorf("unknown snmp v3 auth protocol %q", c.AuthProtocol)
+		}
+		params.AuthenticationPassphrase = c.AuthPassphrase
+	}
+
+	if flags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		switch strings.ToUpper(c.PrivProtocol) {
+		case "DES":
+			params.PrivacyProtocol = gosnmp.DES
+		case "", "AES":
+			params.PrivacyProtocol = gosnmp.AES
+		case "AES192":
+			params.PrivacyProtocol = gosnmp.AES192
+		case "AES256":
+			params.PrivacyProtocol = gosnmp.AES256
+		case "AES192C":
+			params.PrivacyProtocol = gosnmp.AES192C
+		case "AES256C":
+			params.PrivacyProtocol = gosnmp.AES256C
+		default:
+			return nil, 0, fmt.Errorf("unknown snmp v3 privacy protocol %q", c.PrivProtocol)
+		}
+		params.PrivacyPassphrase = c.PrivPassphrase
+	}
+
+	return params, flags, nil
+}
+
+// getSNMPHardwareInfo queries a device over SNMP, filling in the configured fields and interface status.
+func getSNMPHardwareInfo(ctx context.Context, device model.Device, cfg SNMPDeviceConfig, timeout time.Duration) (model.HardwareInfo, error) {
+	var info model.HardwareInfo
+
+	client, err := cfg.client(device.Address, timeout)
+	if err != nil {
+		return info, err
+	}
+	client.Context = ctx
+
+	if err := client.Connect(); err != nil {
+		return info, fmt.Errorf("failed to connect to %s: %w", device.Address, err)
+	}
+	defer client.Conn.Close()
+
+	fields := cfg.fields()
+	oids := make([]string, 0, len(fields))
+	byOID := make(map[string]string, len(fields))
+	for field, oid := range fields {
+		oid = normalizeOID(oid)
+		oids = append(oids, oid)
+		byOID[oid] = field
+	}
+
+	if len(oids) > 0 {
+		resp, err := client.Get(oids)
+		if err != nil {
+			return info, fmt.Errorf("failed to get hardware info from %s: %w", device.Address, err)
+		}
+		if resp.Error != gosnmp.NoError {
+			return info, fmt.Errorf("failed to get hardware info from %s: %s", device.Address, resp.Error)
+		}
+
+		for _, pdu := range resp.Variables {
+			field, ok := byOID[normalizeOID(pdu.Name)]
+			if !ok {
+				continue
+			}
+
+			if v, ok := pduString(pdu, field); ok {
+				setHardwareField(&info, field, v)
+			}
+		}
+	}
+
+	if cfg.Interfaces == nil || *cfg.Interfaces {
+		ifaces, err := interfaceStates(client)
+		if err != nil {
+			// plenty of devices only expose the system group; keep what we have
+			slog.Debug("failed to walk snmp interfaces", slog.String("device", device.ID), slog.Any("error", err))
+		}
+		info.Interfaces = ifaces
+	}
+
+	return info, nil
+}
+
+// interfaceStates walks the ifTable for each interface's name and operational status.
+func interfaceStates(client *gosnmp.GoSNMP) ([]model.InterfaceState, error) {
+	names, err := client.BulkWalkAll(oidIfDescr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk ifDescr: %w", err)
+	}
+	statuses, err := client.BulkWalkAll(oidIfOperStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk ifOperStatus: %w", err)
+	}
+
+	byIndex := make(map[int]string, len(statuses))
+	for _, pdu := range statuses {
+		idx, ok := oidIndex(pdu.Name)
+		if !ok {
+			continue
+		}
+		status := ifOperStatus[int(gosnmp.ToBigInt(pdu.Value).Int64())]
+		if status == "" {
+			status = "unknown"
+		}
+		byIndex[idx] = status
+	}
+
+	var ifaces []model.InterfaceState
+	for _, pdu := range names {
+		idx, ok := oidIndex(pdu.Name)
+		if !ok {
+			continue
+		}
+		name, _ := pduString(pdu, "")
+		ifaces = append(ifaces, model.InterfaceState{
+			Index:  idx,
+			Name:   name,
+			Status: byIndex[idx],
+		})
+	}
+
+	return ifaces, nil
+}
+
+// pduString converts a value into the string stored in a HardwareInfo field.
+// It returns false if the agent doesn't have the OID.
+func pduString(pdu gosnmp.SnmpPDU, field string) (string, bool) {
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return "", false
+	case gosnmp.OctetString:
+		b, _ := pdu.Value.([]byte)
+		if field == "mac_address" && len(b) == 6 {
+			return net.HardwareAddr(b).String(), true
+		}
+		return strings.TrimFunc(string(b), func(r rune) bool {
+			return unicode.IsSpace(r) || !unicode.IsPrint(r)
+		}), true
+	case gosnmp.TimeTicks:
+		ticks := gosnmp.ToBigInt(pdu.Value).Int64()
+		if field == "uptime" {
+			return (time.Duration(ticks) * 10 * time.Millisecond).String(), true
+		}
+		return strconv.FormatInt(ticks, 10), true
+	case gosnmp.IPAddress, gosnmp.ObjectIdentifier:
+		return fmt.Sprintf("%v", pdu.Value), true
+	default:
+		return gosnmp.ToBigInt(pdu.Value).String(), true
+	}
+}
+
+// setHardwareField sets the HardwareInfo field with the given json name, returning false if there isn't one.
+func setHardwareField(info *model.HardwareInfo, field, v string) bool {
+	switch field {
+	case "hostname":
+		info.Hostname = v
+	case "model_name":
+		info.ModelName = v
+	case "serial_number":
+		info.SerialNumber = v
+	case "build_date":
+		info.BuildDate = v
+	case "firmware_version":
+		info.FirmwareVersion = v
+	case "protocol_version":
+		info.ProtocolVersion = v
+	case "description":
+		info.Description = v
+	case "uptime":
+		info.Uptime = v
+	case "filter_status":
+		info.FilterStatus = v
+	case "power_status":
+		info.PowerStatus = v
+	case "power_saving_mode_status":
+		info.PowerSavingModeStatus = v
+	case "temperature":
+		info.Temperature = v
+	case "ip_address":
+		info.NetworkInfo.IPAddress = v
+	case "mac_address":
+		info.NetworkInfo.MACAddress = v
+	case "gateway":
+		info.NetworkInfo.Gateway = v
+	default:
+		return false
+	}
+	return true
+}
+
+func normalizeOID(oid string) string {
+	return "." + strings.TrimPrefix(strings.TrimSpace(oid), ".")
+}
+
+// oidIndex returns the last component of an OID, i.e. the table index.
+func oidIndex(oid string) (int, bool) {
+	i := strings.LastIndex(oid, ".")
+	idx, err := strconv.Atoi(oid[i+1:])
+	return idx, err == nil
+}
@@ -0,0 +1,223 @@
+package hardwareinfo
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/model"
+	"github.com/gosnmp/gosnmp"
+)
+
+// fakeAgent is a minimal SNMP v2c agent serving a fixed set of OIDs over UDP.
+type fakeAgent struct {
+	conn      net.PacketConn
+	community string
+	values    map[string]gosnmp.SnmpPDU
+	oids      []string
+}
+
+func newFakeAgent(t *testing.T, community string, pdus []gosnmp.SnmpPDU) *fakeAgent {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	a := &fakeAgent{
+		conn:      conn,
+		community: community,
+		values:    make(map[string]gosnmp.SnmpPDU),
+	}
+	for _, pdu := range pdus {
+		a.values[pdu.Name] = pdu
+		a.oids = append(a.oids, pdu.Name)
+	}
+	sort.Slice(a.oids, func(i, j int) bool { return oidLess(a.oids[i], a.oids[j]) })
+
+	go a.serve()
+	return a
+}
+
+func (a *fakeAgent) addr() string {
+	return a.conn.LocalAddr().String()
+}
+
+func (a *fakeAgent) serve() {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	buf := make([]byte, 65535)
+
+	for {
+		n, from, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || req.Community != a.community {
+			continue
+		}
+
+		resp := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: a.community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: req.RequestID,
+		}
+
+		switch req.PDUType {
+		case gosnmp.GetRequest:
+			for _, v := range req.Variables {
+				pdu, ok := a.values[v.Name]
+				if !ok {
+					pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject}
+				}
+				resp.Variables = append(resp.Variables, pdu)
+			}
+		case gosnmp.GetNextRequest, gosnmp.GetBulkRequest:
+			reps := int(req.MaxRepetitions)
+			if req.PDUType == gosnmp.GetNextRequest || reps == 0 {
+				reps = 1
+			}
+			for _, v := range req.Variables {
+				resp.Variables = append(resp.Variables, a.next(v.Name, reps)...)
+			}
+		default:
+			continue
+		}
+
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		a.conn.WriteTo(out, from)
+	}
+}
+
+// next returns up to n values following oid in lexicographic order.
+func (a *fakeAgent) next(oid string, n int) []gosnmp.SnmpPDU {
+	i := sort.Search(len(a.oids), func(i int) bool { return oidLess(oid, a.oids[i]) })
+
+	var ret []gosnmp.SnmpPDU
+	for ; i < len(a.oids) && len(ret) < n; i++ {
+		ret = append(ret, a.values[a.oids[i]])
+	}
+	if len(ret) < n {
+		ret = append(ret, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+	}
+	return ret
+}
+
+func oidLess(a, b string) bool {
+	as := strings.Split(strings.TrimPrefix(a, "."), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+func TestSNMPHardwareInfo(t *testing.T) {
+	agent := newFakeAgent(t, "av-monitoring", []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: "Cisco SG350-10 10-Port Gigabit Switch"},
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(360000)},
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: "ITB-1101-SW1"},
+		{Name: ".1.3.6.1.2.1.47.1.1.1.1.10.1", Type: gosnmp.OctetString, Value: "2.5.7.85"},
+		{Name: ".1.3.6.1.4.1.9.6.1.101.53.14.1.5.1", Type: gosnmp.OctetString, Value: "PSZ21111ABC"},
+		{Name: ".1.3.6.1.4.1.9.6.1.101.2.0", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x54, 0xaa, 0xbb, 0xcc}},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: "gi1"},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: "gi2"},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.1", Type: gosnmp.Integer, Value: 1},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 2},
+	})
+
+	err := SetSNMPConfig(SNMPConfig{
+		Timeout: "1s",
+		DeviceTypes: map[string]SNMPDeviceConfig{
+			"SG350": {
+				Community: "av-monitoring",
+				Fields: map[string]string{
+					"serial_number": ".1.3.6.1.4.1.9.6.1.101.53.14.1.5.1",
+					"mac_address":   "1.3.6.1.4.1.9.6.1.101.2.0",
+					"model_name":    "",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to set config: %s", err)
+	}
+
+	cfg, timeout, ok := snmpConfigFor("SG350")
+	if !ok {
+		t.Fatalf("expected an snmp config for SG350")
+	}
+
+	device := model.Device{ID: "ITB-1101-SW1", Address: agent.addr()}
+	info, err := getSNMPHardwareInfo(context.Background(), device, cfg, timeout)
+	if err != nil {
+		t.Fatalf("failed to get hardware info: %s", err)
+	}
+
+	want := model.HardwareInfo{
+		Hostname:        "ITB-1101-SW1",
+		SerialNumber:    "PSZ21111ABC",
+		FirmwareVersion: "2.5.7.85",
+		Description:     "Cisco SG350-10 10-Port Gigabit Switch",
+		Uptime:          (time.Hour).String(),
+		NetworkInfo:     model.NetworkInfo{MACAddress: "00:1b:54:aa:bb:cc"},
+		Interfaces: []model.InterfaceState{
+			{Index: 1, Name: "gi1", Status: "up"},
+			{Index: 2, Name: "gi2", Status: "down"},
+		},
+	}
+
+	if info.Hostname != want.Hostname ||
+		info.SerialNumber != want.SerialNumber ||
+		info.FirmwareVersion != want.FirmwareVersion ||
+		info.Description != want.Description ||
+		info.Uptime != want.Uptime ||
+		info.ModelName != "" ||
+		info.NetworkInfo.MACAddress != want.NetworkInfo.MACAddress {
+		t.Errorf("unexpected hardware info:\ngot  %+v\nwant %+v", info, want)
+	}
+
+	if len(info.Interfaces) != len(want.Interfaces) {
+		t.Fatalf("got %d interfaces, want %d", len(info.Interfaces), len(want.Interfaces))
+	}
+	for i := range want.Interfaces {
+		if info.Interfaces[i] != want.Interfaces[i] {
+			t.Errorf("interface %d: got %+v, want %+v", i, info.Interfaces[i], want.Interfaces[i])
+		}
+	}
+}
+
+func TestSetSNMPConfigRejectsUnknownField(t *testing.T) {
+	err := SetSNMPConfig(SNMPConfig{
+		DeviceTypes: map[string]SNMPDeviceConfig{
+			"PDU": {Fields: map[string]string{"outlet_count": ".1.3.6.1.4.1.318.1.1.4.5.1.0"}},
+		},
+	})
+	if err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+
+	err = SetSNMPConfig(SNMPConfig{
+		DeviceTypes: map[string]SNMPDeviceConfig{
+			"PDU": {Version: "3"},
+		},
+	})
+	if err == nil {
+		t.Errorf("expected an error for snmp v3 without a username")
+	}
+}
@@ -0,0 +1,364 @@
+// Package screencheck looks for a blank or frozen touch panel by analyzing periodic screenshots.
+package screencheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/actions/screenshot"
+	"github.com/byuoitav/device-monitoring/localsystem"
+	"github.com/byuoitav/device-monitoring/messenger"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+// Problems found with the screen.
+const (
+	ProblemBlack       = "black"
+	ProblemSingleColor = "single-color"
+	ProblemFrozen      = "frozen"
+)
+
+const (
+	defaultBlackLevel      = 16
+	defaultColorTolerance  = 8
+	defaultUniformFraction = 0.99
+	defaultChangeTolerance = 1.0
+	defaultAnalysisSize    = 640
+	signatureWidth         = 32
+	signatureHeight        = 18
+	eventKey               = "screen-state"
+)
+
+// Config sets how screenshots are checked. A frame is black or a single color when at least
+// UniformFraction of its pixels are darker than BlackLevel (0-255 luma), or within ColorTolerance
+// of its most common color. It's frozen once it hasn't changed for FrozenAfter, which is off
+// unless set; ChangeTolerance is how much (0-255) it must change by on average to count.
+type Config struct {
+	Output          string  `json:"output,omitempty"`
+	FrozenAfter     string  `json:"frozen-after,omitempty"`
+	BlackLevel      int     `json:"black-level,omitempty"`
+	ColorTolerance  int     `json:"color-tolerance,omitempty"`
+	UniformFraction float64 `json:"uniform-fraction,omitempty"`
+	ChangeTolerance float64 `json:"change-tolerance,omitempty"`
+}
+
+// Status is the result of the most recent check.
+type Status struct {
+	CheckedAt       time.Time `json:"checked-at"`
+	Output          string    `json:"output,omitempty"`
+	Backend         string    `json:"backend,omitempty"`
+	Problem         string    `json:"problem,omitempty"`
+	ProblemSince    time.Time `json:"problem-since,omitempty"`
+	Luma            float64   `json:"luma"`
+	DominantColor   string    `json:"dominant-color"`
+	DominantPercent float64   `json:"dominant-percent"`
+	UnchangedSince  time.Time `json:"unchanged-since"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Frame is a screenshot that was found to have a problem.
+type Frame struct {
+	Status
+	Data        []byte `json:"-"`
+	ContentType string `json:"-"`
+}
+
+var (
+	mu         sync.Mutex
+	status     Status
+	signature  []float64
+	suspicious *Frame
+)
+
+// GetStatus returns the result of the most recent check.
+func GetStatus() Status {
+	mu.Lock()
+	defer mu.Unlock()
+	return status
+}
+
+// SuspiciousFrame returns the most recent frame that had a problem, if there's been one.
+func SuspiciousFrame() (Frame, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if suspicious == nil {
+		return Frame{}, false
+	}
+	return *suspicious, true
+}
+
+// Check takes a screenshot and analyzes it, sending an event when a problem is found or resolved.
+func Check(ctx context.Context, cfg Config) (Status, error) {
+	frozenAfter, err := cfg.validate()
+	if err != nil {
+		return Status{}, err
+	}
+
+	now := time.Now()
+	img, err := screenshot.Take(ctx, screenshot.Options{
+		Output:    cfg.Output,
+		Format:    screenshot.FormatPNG,
+		MaxWidth:  defaultAnalysisSize,
+		MaxHeight: defaultAnalysisSize,
+	})
+	if err != nil {
+		mu.Lock()
+		status.CheckedAt = now
+		status.Error = err.Error()
+		mu.Unlock()
+		return Status{}, fmt.Errorf("unable to take screenshot: %w", err)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to decode screenshot: %w", err)
+	}
+	next, prevProblem := update(analyze(decoded, cfg), img, cfg, frozenAfter, now)
+	if next.Problem != prevProblem {
+		sendScreenEvent(next, prevProblem)
+	}
+	return next, nil
+}
+
+// update records the analysis of a screenshot taken at now, and returns the new status along with
+// the problem the screen had before.
+func update(a analysis, img screenshot.Image, cfg Config, frozenAfter time.Duration, now time.Time) (Status, string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	prev := status
+	next := Status{
+		CheckedAt:       now,
+		Output:          img.Output,
+		Backend:         img.Backend,
+		Luma:            a.luma,
+		DominantColor:   a.dominant,
+		DominantPercent: a.dominantFraction * 100,
+		UnchangedSince:  now,
+	}
+	if signature != nil && signatureDiff(signature, a.signature) <= cfg.ChangeTolerance {
+		next.UnchangedSince = prev.UnchangedSince
+	}
+	signature = a.signature
+
+	switch {
+	case a.darkFraction >= cfg.UniformFraction:
+		next.Problem = ProblemBlack
+	case a.dominantFraction >= cfg.UniformFraction:
+		next.Problem = ProblemSingleColor
+	case frozenAfter > 0 && now.Sub(next.UnchangedSince) >= frozenAfter:
+		next.Problem = ProblemFrozen
+	}
+
+	if next.Problem != "" {
+		next.ProblemSince = now
+		if next.Problem == prev.Problem {
+			next.ProblemSince = prev.ProblemSince
+		}
+		suspicious = &Frame{Status: next, Data: img.Data, ContentType: img.ContentType}
+	}
+	status = next
+	return next, prev.Problem
+}
+
+// validate fills in the defaults and returns how long until an unchanged screen is frozen.
+func (c *Config) validate() (time.Duration, error) {
+	if c.BlackLevel == 0 {
+		c.BlackLevel = defaultBlackLevel
+	}
+	if c.ColorTolerance == 0 {
+		c.ColorTolerance = defaultColorTolerance
+	}
+	if c.UniformFraction == 0 {
+		c.UniformFraction = defaultUniformFraction
+	}
+	if c.ChangeTolerance == 0 {
+		c.ChangeTolerance = defaultChangeTolerance
+	}
+
+	switch {
+	case c.BlackLevel < 0 || c.BlackLevel > 255:
+		return 0, fmt.Errorf("invalid black-level %d, must be 0-255", c.BlackLevel)
+	case c.ColorTolerance < 0 || c.ColorTolerance > 255:
+		return 0, fmt.Errorf("invalid color-tolerance %d, must be 0-255", c.ColorTolerance)
+	case c.UniformFraction < 0 || c.UniformFraction > 1:
+		return 0, fmt.Errorf("invalid uniform-fraction %v, must be 0-1", c.UniformFraction)
+	case c.ChangeTolerance < 0:
+		return 0, fmt.Errorf("invalid change-tolerance %v", c.ChangeTolerance)
+	}
+
+	if c.FrozenAfter == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.FrozenAfter)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid frozen-after %q", c.FrozenAfter)
+	}
+	return d, nil
+}
+
+type analysis struct {
+	luma             float64
+	darkFraction     float64
+	dominant         string
+	dominantFraction float64
+	signature        []float64
+}
+
+// analyze measures how dark and how uniform img is, and reduces it to a small grayscale signature
+// that's compared between checks to tell if the screen changed.
+func analyze(img image.Image, cfg Config) analysis {
+	b := img.Bounds()
+	total := b.Dx() * b.Dy()
+	if total == 0 {
+		return analysis{}
+	}
+
+	// the dominant color is the most common one after dropping the low bits, refined below by
+	// counting every pixel within the tolerance of that bucket's average
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[uint32]*bucket)
+	lumas := make([]float64, 0, total)
+	var lumaSum float64
+	dark := 0
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r32, g32, b32, _ := img.At(x, y).RGBA()
+			r, g, bl := int(r32>>8), int(g32>>8), int(b32>>8)
+
+			l := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			lumas = append(lumas, l)
+			lumaSum += l
+			if l < float64(cfg.BlackLevel) {
+				dark++
+			}
+
+			key := uint32(r>>4)<<8 | uint32(g>>4)<<4 | uint32(bl>>4)
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += r
+			bk.g += g
+			bk.b += bl
+		}
+	}
+
+	var top *bucket
+	for _, bk := range buckets {
+		if top == nil || bk.count > top.count {
+			top = bk
+		}
+	}
+	dr, dg, db := top.r/top.count, top.g/top.count, top.b/top.count
+
+	near := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r32, g32, b32, _ := img.At(x, y).RGBA()
+			if abs(int(r32>>8)-dr) <= cfg.ColorTolerance && abs(int(g32>>8)-dg) <= cfg.ColorTolerance && abs(int(b32>>8)-db) <= cfg.ColorTolerance {
+				near++
+			}
+		}
+	}
+
+	return analysis{
+		luma:             lumaSum / float64(total),
+		darkFraction:     float64(dark) / float64(total),
+		dominant:         fmt.Sprintf("#%02x%02x%02x", dr, dg, db),
+		dominantFraction: float64(near) / float64(total),
+		signature:        reduce(lumas, b.Dx(), b.Dy()),
+	}
+}
+
+// reduce averages the lumas of a w x h image into a signatureWidth x signatureHeight grid.
+func reduce(lumas []float64, w, h int) []float64 {
+	sig := make([]float64, signatureWidth*signatureHeight)
+	counts := make([]int, len(sig))
+
+	for y := 0; y < h; y++ {
+		sy := y * signatureHeight / h
+		for x := 0; x < w; x++ {
+			i := sy*signatureWidth + x*signatureWidth/w
+			sig[i] += lumas[y*w+x]
+			counts[i]++
+		}
+	}
+
+	for i := range sig {
+		if counts[i] > 0 {
+			sig[i] /= float64(counts[i])
+		}
+	}
+	return sig
+}
+
+// signatureDiff returns the mean absolute difference between two signatures.
+func signatureDiff(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 255
+	}
+
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum / float64(len(a))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sendScreenEvent(s Status, previous string) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		slog.Warn("unable to send screen event", slog.Any("error", err))
+		return
+	}
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	event := model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        s.CheckedAt,
+		EventTags: []string{
+			model.UICommunication,
+			model.Alert,
+			model.AutoGenerated,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          eventKey,
+		Value:        s.Problem,
+		Data:         s,
+	}
+
+	if s.Problem == "" {
+		event.Value = "resolved"
+		slog.Info("Screen problem resolved", slog.String("problem", previous))
+	} else {
+		slog.Warn("Screen problem detected", slog.String("problem", s.Problem), slog.String("color", s.DominantColor), slog.Float64("luma", s.Luma))
+	}
+
+	messenger.Get().SendEvent(model.ToCommonEvent(event))
+}
@@ -0,0 +1,259 @@
+package screencheck
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/actions/screenshot"
+)
+
+func solid(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func noisy(seed int64) *image.RGBA {
+	r := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 0xff
+	}
+	return img
+}
+
+func defaultConfig(t *testing.T, frozenAfter string) (Config, time.Duration) {
+	t.Helper()
+
+	cfg := Config{FrozenAfter: frozenAfter}
+	d, err := cfg.validate()
+	if err != nil {
+		t.Fatalf("invalid config: %s", err)
+	}
+	return cfg, d
+}
+
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	status, signature, suspicious = Status{}, nil, nil
+}
+
+func TestAnalyze(t *testing.T) {
+	cfg, _ := defaultConfig(t, "")
+
+	tests := []struct {
+		name         string
+		img          image.Image
+		wantBlack    bool
+		wantDominant string
+		wantUniform  bool
+		wantLuma     float64
+	}{
+		{name: "black", img: solid(color.RGBA{A: 0xff}), wantBlack: true, wantDominant: "#000000", wantUniform: true},
+		{name: "near black", img: solid(color.RGBA{R: 10, G: 10, B: 10, A: 0xff}), wantBlack: true, wantDominant: "#0a0a0a", wantUniform: true, wantLuma: 10},
+		{name: "grey", img: solid(color.RGBA{R: 128, G: 128, B: 128, A: 0xff}), wantDominant: "#808080", wantUniform: true, wantLuma: 128},
+		{name: "noisy", img: noisy(1)},
+	}
+
+	for _, tt := range tests {
+		a := analyze(tt.img, cfg)
+		if black := a.darkFraction >= cfg.UniformFraction; black != tt.wantBlack {
+			t.Errorf("%s: expected black=%v, got a dark fraction of %v", tt.name, tt.wantBlack, a.darkFraction)
+		}
+		if tt.wantDominant != "" && a.dominant != tt.wantDominant {
+			t.Errorf("%s: expected %s to be dominant, got %s", tt.name, tt.wantDominant, a.dominant)
+		}
+		if uniform := a.dominantFraction >= cfg.UniformFraction; uniform != tt.wantUniform {
+			t.Errorf("%s: expected uniform=%v, got a dominant fraction of %v", tt.name, tt.wantUniform, a.dominantFraction)
+		}
+		if tt.wantLuma != 0 && (a.luma < tt.wantLuma-0.01 || a.luma > tt.wantLuma+0.01) {
+			t.Errorf("%s: expected a luma of %v, got %v", tt.name, tt.wantLuma, a.luma)
+		}
+		if len(a.signature) != signatureWidth*signatureHeight {
+			t.Errorf("%s: expected a %dx%d signature, got %d values", tt.name, signatureWidth, signatureHeight, len(a.signature))
+		}
+	}
+}
+
+func TestAnalyzeTolerance(t *testing.T) {
+	cfg, _ := defaultConfig(t, "")
+
+	// grey with 0.5% of its pixels a little lighter (within tolerance) and 0.5% white
+	img := solid(color.RGBA{R: 100, G: 100, B: 100, A: 0xff})
+	n := len(img.Pix) / 4
+	for i := 0; i < n/200; i++ {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2] = 105, 105, 105
+	}
+	for i := n - n/200; i < n; i++ {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2] = 255, 255, 255
+	}
+
+	a := analyze(img, cfg)
+	if a.dominantFraction < 0.99 || a.dominantFraction == 1 {
+		t.Errorf("expected the near pixels to count and the white ones not to, got %v", a.dominantFraction)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	// a 64x36 image whose left half is 0 and right half is 100 reduces to the same halves
+	w, h := 64, 36
+	lumas := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			lumas[y*w+x] = 100
+		}
+	}
+
+	sig := reduce(lumas, w, h)
+	for y := 0; y < signatureHeight; y++ {
+		for x := 0; x < signatureWidth; x++ {
+			want := 0.0
+			if x >= signatureWidth/2 {
+				want = 100
+			}
+			if got := sig[y*signatureWidth+x]; got != want {
+				t.Fatalf("%d,%d: expected %v, got %v", x, y, want, got)
+			}
+		}
+	}
+
+	// an image smaller than the signature still fills the cells it covers
+	small := reduce([]float64{10, 20, 30, 40}, 2, 2)
+	if small[0] != 10 || small[signatureWidth/2] != 20 || small[(signatureHeight/2)*signatureWidth] != 30 {
+		t.Errorf("unexpected signature of a small image: %v", small[:signatureWidth])
+	}
+}
+
+func TestSignatureDiff(t *testing.T) {
+	a := []float64{10, 20, 30, 40}
+	tests := []struct {
+		name string
+		b    []float64
+		want float64
+	}{
+		{name: "same", b: []float64{10, 20, 30, 40}, want: 0},
+		{name: "changed", b: []float64{20, 10, 30, 44}, want: 6},
+		{name: "different size", b: []float64{10, 20}, want: 255},
+		{name: "empty", b: nil, want: 255},
+	}
+
+	for _, tt := range tests {
+		if got := signatureDiff(a, tt.b); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	reset()
+	t.Cleanup(reset)
+
+	cfg, frozenAfter := defaultConfig(t, "10m")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	img := screenshot.Image{Output: "HDMI-A-1", Backend: screenshot.BackendGrim, Data: []byte("png"), ContentType: "image/png"}
+
+	steps := []struct {
+		name             string
+		after            time.Duration
+		frame            image.Image
+		wantProblem      string
+		wantProblemSince time.Duration
+		wantEvent        bool
+	}{
+		{name: "normal", after: 0, frame: noisy(1)},
+		{name: "goes black", after: time.Minute, frame: solid(color.RGBA{A: 0xff}), wantProblem: ProblemBlack, wantProblemSince: time.Minute, wantEvent: true},
+		{name: "still black", after: 2 * time.Minute, frame: solid(color.RGBA{A: 0xff}), wantProblem: ProblemBlack, wantProblemSince: time.Minute},
+		{name: "turns grey", after: 3 * time.Minute, frame: solid(color.RGBA{R: 128, G: 128, B: 128, A: 0xff}), wantProblem: ProblemSingleColor, wantProblemSince: 3 * time.Minute, wantEvent: true},
+		{name: "recovers", after: 4 * time.Minute, frame: noisy(2), wantEvent: true},
+		{name: "unchanged", after: 10 * time.Minute, frame: noisy(2)},
+		{name: "frozen", after: 14 * time.Minute, frame: noisy(2), wantProblem: ProblemFrozen, wantProblemSince: 14 * time.Minute, wantEvent: true},
+		{name: "still frozen", after: 15 * time.Minute, frame: noisy(2), wantProblem: ProblemFrozen, wantProblemSince: 14 * time.Minute},
+		{name: "changes again", after: 16 * time.Minute, frame: noisy(3), wantEvent: true},
+		{name: "stays fine", after: 17 * time.Minute, frame: noisy(4)},
+	}
+
+	for _, step := range steps {
+		now := start.Add(step.after)
+		next, prevProblem := update(analyze(step.frame, cfg), img, cfg, frozenAfter, now)
+
+		if next.Problem != step.wantProblem {
+			t.Fatalf("%s: expected problem %q, got %q", step.name, step.wantProblem, next.Problem)
+		}
+		if event := next.Problem != prevProblem; event != step.wantEvent {
+			t.Errorf("%s: expected event=%v, went from %q to %q", step.name, step.wantEvent, prevProblem, next.Problem)
+		}
+
+		wantSince := time.Time{}
+		if step.wantProblem != "" {
+			wantSince = start.Add(step.wantProblemSince)
+		}
+		if !next.ProblemSince.Equal(wantSince) {
+			t.Errorf("%s: expected the problem since %s, got %s", step.name, wantSince, next.ProblemSince)
+		}
+		if next.Output != "HDMI-A-1" || next.Backend != screenshot.BackendGrim || !next.CheckedAt.Equal(now) {
+			t.Errorf("%s: unexpected status: %+v", step.name, next)
+		}
+		if got := GetStatus(); got != next {
+			t.Errorf("%s: expected the status to be recorded", step.name)
+		}
+	}
+
+	if want := start.Add(17 * time.Minute); !GetStatus().UnchangedSince.Equal(want) {
+		t.Errorf("expected the screen to be unchanged since %s, got %s", want, GetStatus().UnchangedSince)
+	}
+
+	frame, ok := SuspiciousFrame()
+	if !ok || frame.Problem != ProblemFrozen || !frame.CheckedAt.Equal(start.Add(15*time.Minute)) || string(frame.Data) != "png" {
+		t.Errorf("expected the last frozen frame to be kept, got %+v (%v)", frame.Status, ok)
+	}
+}
+
+func TestUpdateFrozenOff(t *testing.T) {
+	reset()
+	t.Cleanup(reset)
+
+	cfg, frozenAfter := defaultConfig(t, "")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		next, _ := update(analyze(noisy(1), cfg), screenshot.Image{}, cfg, frozenAfter, start.Add(time.Duration(i)*time.Hour))
+		if next.Problem != "" {
+			t.Fatalf("expected no problem without frozen-after, got %q", next.Problem)
+		}
+		if !next.UnchangedSince.Equal(start) {
+			t.Errorf("expected the screen to be unchanged since the first check, got %s", next.UnchangedSince)
+		}
+	}
+
+	if _, ok := SuspiciousFrame(); ok {
+		t.Errorf("expected no suspicious frame")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{}
+	if d, err := cfg.validate(); err != nil || d != 0 {
+		t.Fatalf("expected the defaults to be valid with frozen off, got %s, %v", d, err)
+	}
+	if cfg.BlackLevel != defaultBlackLevel || cfg.ColorTolerance != defaultColorTolerance || cfg.UniformFraction != defaultUniformFraction || cfg.ChangeTolerance != defaultChangeTolerance {
+		t.Errorf("expected the defaults to be filled in, got %+v", cfg)
+	}
+
+	for _, bad := range []Config{
+		{BlackLevel: 256},
+		{ColorTolerance: -1},
+		{UniformFraction: 1.5},
+		{ChangeTolerance: -1},
+		{FrozenAfter: "-1m"},
+		{FrozenAfter: "a while"},
+	} {
+		if _, err := bad.validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
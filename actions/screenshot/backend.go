@@ -0,0 +1,153 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend names that can be set in the screenshot config.
+const (
+	BackendAuto        = "auto"
+	BackendGrim        = "grim"
+	BackendX11         = "x11"
+	BackendFramebuffer = "framebuffer"
+)
+
+// ErrNoBackend is returned when none of the capture backends can be used on this device.
+var ErrNoBackend = errors.New("no screenshot backend is available")
+
+// Backend captures the screen.
+type Backend interface {
+	// Name returns the name of the backend, as used in the screenshot config.
+	Name() string
+	// Available returns why the backend can't be used here, or nil if it can.
+	Available() error
+	// Outputs lists the outputs that can be captured.
+	Outputs(ctx context.Context) ([]Output, error)
+	// Capture captures output, or the whole screen if output is empty.
+	Capture(ctx context.Context, output string) (Frame, error)
+}
+
+// Frame is a captured frame, either PNG encoded or already decoded.
+type Frame struct {
+	PNG    []byte
+	Image  image.Image
+	Output string
+}
+
+// Config selects the capture backend. By default ("auto") the first one that's available is used,
+// trying grim (Wayland), then X11, then the framebuffer. Stream sets up the live screen stream.
+type Config struct {
+	Backend string       `json:"backend,omitempty"`
+	Stream  StreamConfig `json:"stream,omitempty"`
+}
+
+// redetectInterval is how often auto-detection checks if a better backend than the current one
+// has become available, e.g. when the display server starts after this service.
+const redetectInterval = 30 * time.Second
+
+var (
+	backends = []Backend{grimBackend{}, x11Backend{}, framebufferBackend{device: defaultFramebuffer}}
+
+	backendMu  sync.Mutex
+	configured string
+	backend    Backend
+	detectedAt time.Time
+)
+
+// Configure sets the capture backend, detecting it now if it's "auto", and the stream config. The
+// backend is set even if the stream config is invalid.
+func Configure(cfg Config) error {
+	return errors.Join(configureBackend(cfg.Backend), ConfigureStream(cfg.Stream))
+}
+
+func configureBackend(name string) error {
+	switch name = strings.ToLower(name); name {
+	case "":
+		name = BackendAuto
+	case "drm":
+		name = BackendFramebuffer
+	}
+
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	if name == BackendAuto {
+		configured, backend, detectedAt = name, detect(), time.Now()
+		return nil
+	}
+
+	for _, b := range backends {
+		if b.Name() == name {
+			if err := b.Available(); err != nil {
+				slog.Warn("Configured screenshot backend isn't available yet", slog.String("backend", name), slog.Any("error", err))
+			}
+			configured, backend = name, b
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown screenshot backend %q", name)
+}
+
+// CurrentBackend returns the backend used for screenshots. When auto-detecting, it's re-detected if
+// the last one stopped being available (e.g. the Wayland session restarted) or none was found yet,
+// and every redetectInterval it switches to a better one that has become available.
+func CurrentBackend() (Backend, error) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	if configured == "" {
+		configured = BackendAuto
+	}
+	if configured == BackendAuto {
+		switch {
+		case backend == nil || backend.Available() != nil:
+			backend, detectedAt = detect(), time.Now()
+		case time.Since(detectedAt) >= redetectInterval:
+			detectedAt = time.Now()
+			if b := betterBackend(backend); b != nil {
+				slog.Info("Switching screenshot backend", slog.String("from", backend.Name()), slog.String("to", b.Name()))
+				backend = b
+			}
+		}
+	}
+
+	if backend == nil {
+		return nil, ErrNoBackend
+	}
+	return backend, nil
+}
+
+// betterBackend returns the first available backend that's tried before cur, or nil.
+func betterBackend(cur Backend) Backend {
+	for _, b := range backends {
+		if b.Name() == cur.Name() {
+			return nil
+		}
+		if b.Available() == nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// detect returns the first available backend, or nil.
+func detect() Backend {
+	for _, b := range backends {
+		err := b.Available()
+		if err == nil {
+			slog.Info("Detected screenshot backend", slog.String("backend", b.Name()))
+			return b
+		}
+		slog.Debug("Screenshot backend unavailable", slog.String("backend", b.Name()), slog.Any("error", err))
+	}
+
+	slog.Warn("No screenshot backend is available")
+	return nil
+}
@@ -0,0 +1,89 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	name      string
+	available *bool
+}
+
+func (b fakeBackend) Name() string { return b.name }
+
+func (b fakeBackend) Available() error {
+	if !*b.available {
+		return errors.New("not available")
+	}
+	return nil
+}
+
+func (fakeBackend) Outputs(context.Context) ([]Output, error) { return nil, nil }
+
+func (fakeBackend) Capture(context.Context, string) (Frame, error) {
+	return Frame{Image: image.NewRGBA(image.Rect(0, 0, 64, 36))}, nil
+}
+
+func TestCurrentBackendSwitchesToBetterBackend(t *testing.T) {
+	wayland, fb := false, true
+	saved := backends
+	backends = []Backend{fakeBackend{name: BackendGrim, available: &wayland}, fakeBackend{name: BackendFramebuffer, available: &fb}}
+	t.Cleanup(func() {
+		backends = saved
+		configured, backend = "", nil
+	})
+
+	if err := Configure(Config{}); err != nil {
+		t.Fatalf("failed to configure: %s", err)
+	}
+	if b, err := CurrentBackend(); err != nil || b.Name() != BackendFramebuffer {
+		t.Fatalf("expected the framebuffer before the compositor is up, got %v, %v", b, err)
+	}
+
+	// the compositor starts, but it isn't checked for until redetectInterval has passed
+	wayland = true
+	if b, _ := CurrentBackend(); b.Name() != BackendFramebuffer {
+		t.Errorf("expected to keep the framebuffer until it's time to detect again, got %s", b.Name())
+	}
+
+	backendMu.Lock()
+	detectedAt = time.Now().Add(-redetectInterval)
+	backendMu.Unlock()
+	if b, _ := CurrentBackend(); b.Name() != BackendGrim {
+		t.Errorf("expected to switch to grim, got %s", b.Name())
+	}
+
+	// the compositor stops
+	wayland = false
+	if b, _ := CurrentBackend(); b.Name() != BackendFramebuffer {
+		t.Errorf("expected to fall back to the framebuffer, got %s", b.Name())
+	}
+}
+
+func TestCurrentBackendKeepsConfiguredBackend(t *testing.T) {
+	wayland, fb := true, true
+	saved := backends
+	backends = []Backend{fakeBackend{name: BackendGrim, available: &wayland}, fakeBackend{name: BackendFramebuffer, available: &fb}}
+	t.Cleanup(func() {
+		backends = saved
+		configured, backend = "", nil
+	})
+
+	if err := Configure(Config{Backend: "drm"}); err != nil {
+		t.Fatalf("failed to configure: %s", err)
+	}
+	backendMu.Lock()
+	detectedAt = time.Now().Add(-redetectInterval)
+	backendMu.Unlock()
+
+	if b, _ := CurrentBackend(); b.Name() != BackendFramebuffer {
+		t.Errorf("expected the configured framebuffer backend, got %s", b.Name())
+	}
+	if err := Configure(Config{Backend: "wayland"}); err == nil {
+		t.Errorf("expected an error for an unknown backend")
+	}
+}
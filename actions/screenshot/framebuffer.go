@@ -0,0 +1,218 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultFramebuffer = "fb0"
+	framebufferSysfs   = "/sys/class/graphics"
+
+	fbiogetVscreeninfo = 0x4600
+)
+
+// framebufferBackend reads the screen straight out of a Linux framebuffer device. With KMS/DRM the
+// kernel's fbdev emulation provides one, so this also works without a display server.
+type framebufferBackend struct {
+	device string
+}
+
+// framebufferInfo is the geometry of a framebuffer. width x height is the visible part, which starts
+// at xoffset,yoffset of the virtual screen when it's double-buffered or panned.
+type framebufferInfo struct {
+	width, height, bpp, stride  int
+	xoffset, yoffset            int
+	virtualWidth, virtualHeight int
+}
+
+// fbVarScreeninfo is struct fb_var_screeninfo from linux/fb.h, of which only the resolution,
+// offsets and depth are used.
+type fbVarScreeninfo struct {
+	xres, yres               uint32
+	xresVirtual, yresVirtual uint32
+	xoffset, yoffset         uint32
+	bitsPerPixel             uint32
+	_                        [33]uint32
+}
+
+func (framebufferBackend) Name() string {
+	return BackendFramebuffer
+}
+
+func (b framebufferBackend) Available() error {
+	info, err := readFramebufferInfo(b.device)
+	if err != nil {
+		return err
+	}
+	if info.bpp != 16 && info.bpp != 24 && info.bpp != 32 {
+		return fmt.Errorf("unsupported framebuffer depth %d", info.bpp)
+	}
+
+	f, err := os.Open(filepath.Join("/dev", b.device))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Outputs lists each framebuffer device as an output.
+func (framebufferBackend) Outputs(context.Context) ([]Output, error) {
+	matches, err := filepath.Glob(filepath.Join(framebufferSysfs, "fb*"))
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]Output, 0, len(matches))
+	for _, m := range matches {
+		name := filepath.Base(m)
+		o := Output{Name: name}
+		if desc, err := os.ReadFile(filepath.Join(m, "name")); err == nil {
+			o.Description = strings.TrimSpace(string(desc))
+		}
+		if info, err := readFramebufferInfo(name); err == nil {
+			o.Enabled = true
+			o.Width, o.Height = info.width, info.height
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs, nil
+}
+
+// Capture reads the visible part of the framebuffer (or the named one).
+func (b framebufferBackend) Capture(_ context.Context, output string) (Frame, error) {
+	device := b.device
+	if output != "" {
+		if _, err := os.Stat(filepath.Join(framebufferSysfs, output)); err != nil || !strings.HasPrefix(output, "fb") {
+			return Frame{}, fmt.Errorf("%w %q", ErrUnknownOutput, output)
+		}
+		device = output
+	}
+
+	info, err := readFramebufferInfo(device)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	f, err := os.Open(filepath.Join("/dev", device))
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to open framebuffer: %w", err)
+	}
+	defer f.Close()
+
+	raw := make([]byte, info.stride*info.height)
+	// ReadAt may return io.EOF along with the last rows of the framebuffer
+	if n, err := f.ReadAt(raw, int64(info.yoffset*info.stride)); n < len(raw) {
+		return Frame{}, fmt.Errorf("failed to read framebuffer: %w", err)
+	}
+
+	return Frame{Image: decodeFramebuffer(raw, info), Output: device}, nil
+}
+
+// decodeFramebuffer converts the raw little-endian pixels (RGB565, BGR888 or XRGB8888) of the visible
+// rows of a framebuffer to an image, skipping the first xoffset pixels of each row.
+func decodeFramebuffer(raw []byte, info framebufferInfo) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, info.width, info.height))
+	bytesPerPixel := info.bpp / 8
+
+	for y := 0; y < info.height; y++ {
+		row := raw[y*info.stride+info.xoffset*bytesPerPixel:]
+		for x := 0; x < info.width; x++ {
+			p := row[x*bytesPerPixel:]
+			i := img.PixOffset(x, y)
+
+			switch info.bpp {
+			case 16:
+				v := uint16(p[0]) | uint16(p[1])<<8
+				r, g, b := uint8(v>>11&0x1f), uint8(v>>5&0x3f), uint8(v&0x1f)
+				img.Pix[i] = r<<3 | r>>2
+				img.Pix[i+1] = g<<2 | g>>4
+				img.Pix[i+2] = b<<3 | b>>2
+			default:
+				img.Pix[i] = p[2]
+				img.Pix[i+1] = p[1]
+				img.Pix[i+2] = p[0]
+			}
+			img.Pix[i+3] = 0xff
+		}
+	}
+	return img
+}
+
+// readFramebufferInfo reads the geometry of a framebuffer from sysfs, and its visible mode from the
+// device. If the device can't be queried, the whole virtual screen is used.
+func readFramebufferInfo(device string) (framebufferInfo, error) {
+	dir := filepath.Join(framebufferSysfs, device)
+	read := func(name string) (string, error) {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", fmt.Errorf("no framebuffer %s: %w", device, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	var info framebufferInfo
+	size, err := read("virtual_size")
+	if err != nil {
+		return info, err
+	}
+	if _, err := fmt.Sscanf(size, "%d,%d", &info.virtualWidth, &info.virtualHeight); err != nil {
+		return info, fmt.Errorf("invalid framebuffer size %q", size)
+	}
+
+	for name, v := range map[string]*int{"bits_per_pixel": &info.bpp, "stride": &info.stride} {
+		s, err := read(name)
+		if err != nil {
+			return info, err
+		}
+		if *v, err = strconv.Atoi(s); err != nil {
+			return info, fmt.Errorf("invalid framebuffer %s %q", name, s)
+		}
+	}
+
+	info.width, info.height = info.virtualWidth, info.virtualHeight
+	if vs, err := screenInfo(device); err == nil {
+		info.width, info.height = int(vs.xres), int(vs.yres)
+		info.xoffset, info.yoffset = int(vs.xoffset), int(vs.yoffset)
+	}
+
+	return info, info.validate()
+}
+
+// validate makes sure the visible part of the framebuffer is inside the virtual screen.
+func (info framebufferInfo) validate() error {
+	switch {
+	case info.width <= 0 || info.height <= 0:
+		return fmt.Errorf("invalid framebuffer size %dx%d", info.width, info.height)
+	case info.xoffset+info.width > info.virtualWidth || info.yoffset+info.height > info.virtualHeight:
+		return fmt.Errorf("visible framebuffer %dx%d+%d+%d is outside of %dx%d", info.width, info.height,
+			info.xoffset, info.yoffset, info.virtualWidth, info.virtualHeight)
+	case info.stride < info.virtualWidth*info.bpp/8:
+		return fmt.Errorf("invalid framebuffer stride %d for width %d", info.stride, info.virtualWidth)
+	}
+	return nil
+}
+
+// screenInfo returns the current mode of a framebuffer device (FBIOGET_VSCREENINFO).
+func screenInfo(device string) (fbVarScreeninfo, error) {
+	var vs fbVarScreeninfo
+
+	f, err := os.Open(filepath.Join("/dev", device))
+	if err != nil {
+		return vs, err
+	}
+	defer f.Close()
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fbiogetVscreeninfo, uintptr(unsafe.Pointer(&vs))); errno != 0 {
+		return vs, fmt.Errorf("failed to get the screen info of %s: %w", device, errno)
+	}
+	return vs, nil
+}
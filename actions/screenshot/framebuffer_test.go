@@ -0,0 +1,73 @@
+package screenshot
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDecodeFramebuffer(t *testing.T) {
+	tests := []struct {
+		name string
+		bpp  int
+		px   []byte // one pixel, little-endian
+		want color.RGBA
+	}{
+		{name: "XRGB8888", bpp: 32, px: []byte{0x30, 0x20, 0x10, 0x00}, want: color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}},
+		{name: "BGR888", bpp: 24, px: []byte{0xff, 0x80, 0x00}, want: color.RGBA{R: 0x00, G: 0x80, B: 0xff, A: 0xff}},
+		{name: "RGB565 red", bpp: 16, px: []byte{0x00, 0xf8}, want: color.RGBA{R: 0xff, A: 0xff}},
+		{name: "RGB565 green", bpp: 16, px: []byte{0xe0, 0x07}, want: color.RGBA{G: 0xff, A: 0xff}},
+		{name: "RGB565 blue", bpp: 16, px: []byte{0x1f, 0x00}, want: color.RGBA{B: 0xff, A: 0xff}},
+	}
+
+	for _, tt := range tests {
+		// a 2x1 screen with 4 bytes of padding at the end of the row
+		bytesPerPixel := tt.bpp / 8
+		stride := 2*bytesPerPixel + 4
+		raw := make([]byte, stride)
+		copy(raw[bytesPerPixel:], tt.px)
+
+		img := decodeFramebuffer(raw, framebufferInfo{width: 2, height: 1, bpp: tt.bpp, stride: stride})
+		if got := img.RGBAAt(1, 0); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+		if got := img.RGBAAt(0, 0); got != (color.RGBA{A: 0xff}) {
+			t.Errorf("%s: expected black, got %v", tt.name, got)
+		}
+	}
+}
+
+func TestDecodeFramebufferOffset(t *testing.T) {
+	// a 2x2 visible screen at 1,0 of a 3 pixel wide virtual screen, 8 bits per channel
+	info := framebufferInfo{width: 2, height: 2, bpp: 32, stride: 12, xoffset: 1, virtualWidth: 3, virtualHeight: 4}
+	raw := []byte{
+		0, 0, 0, 0, 1, 1, 1, 0, 2, 2, 2, 0,
+		3, 3, 3, 0, 4, 4, 4, 0, 5, 5, 5, 0,
+	}
+
+	img := decodeFramebuffer(raw, info)
+	for _, p := range []struct{ x, y, v int }{{0, 0, 1}, {1, 0, 2}, {0, 1, 4}, {1, 1, 5}} {
+		if got := img.RGBAAt(p.x, p.y).R; int(got) != p.v {
+			t.Errorf("%d,%d: expected %d, got %d", p.x, p.y, p.v, got)
+		}
+	}
+}
+
+func TestFramebufferInfoValidate(t *testing.T) {
+	// double-buffered 1920x1080, showing the second buffer
+	info := framebufferInfo{width: 1920, height: 1080, bpp: 32, stride: 7680, yoffset: 1080, virtualWidth: 1920, virtualHeight: 2160}
+	if err := info.validate(); err != nil {
+		t.Errorf("expected a double-buffered framebuffer to be valid: %s", err)
+	}
+
+	tests := map[string]framebufferInfo{
+		"empty":              {bpp: 32, stride: 7680, virtualWidth: 1920, virtualHeight: 1080},
+		"offset past bottom": {width: 1920, height: 1080, bpp: 32, stride: 7680, yoffset: 1081, virtualWidth: 1920, virtualHeight: 2160},
+		"offset past right":  {width: 1920, height: 1080, bpp: 32, stride: 7680, xoffset: 1, virtualWidth: 1920, virtualHeight: 1080},
+		"short stride":       {width: 1920, height: 1080, bpp: 32, stride: 1920, virtualWidth: 1920, virtualHeight: 1080},
+	}
+	for name, info := range tests {
+		if err := info.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
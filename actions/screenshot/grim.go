@@ -0,0 +1,90 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const grimPath = "/usr/bin/grim"
+
+// grimBackend captures a Wayland (wlroots) session with grim.
+type grimBackend struct{}
+
+func (grimBackend) Name() string {
+	return BackendGrim
+}
+
+func (grimBackend) Available() error {
+	xdg := os.Getenv("XDG_RUNTIME_DIR")
+	wayland := os.Getenv("WAYLAND_DISPLAY")
+	if xdg == "" || wayland == "" {
+		return fmt.Errorf("environment not ready: XDG_RUNTIME_DIR=%q, WAYLAND_DISPLAY=%q", xdg, wayland)
+	}
+	if _, err := os.Stat(filepath.Join(xdg, wayland)); err != nil {
+		return fmt.Errorf("no wayland socket: %w", err)
+	}
+	if _, err := os.Stat(grimPath); err != nil {
+		return fmt.Errorf("grim isn't installed: %w", err)
+	}
+	return nil
+}
+
+func (grimBackend) Outputs(ctx context.Context) ([]Output, error) {
+	return wlrOutputs(ctx)
+}
+
+// Capture captures output, or the first enabled output if it's empty, falling back to the whole
+// session if no output can be found.
+func (grimBackend) Capture(ctx context.Context, output string) (Frame, error) {
+	xdg := os.Getenv("XDG_RUNTIME_DIR")
+	wayland := os.Getenv("WAYLAND_DISPLAY")
+
+	// Detect active output
+	outputs, err := wlrOutputs(ctx)
+	if err != nil {
+		slog.Error("Failed to list outputs", slog.String("error", err.Error()))
+		return Frame{}, fmt.Errorf("failed to detect active output: %w", err)
+	}
+
+	o, err := findOutput(outputs, output)
+	switch {
+	case errors.Is(err, ErrUnknownOutput):
+		return Frame{}, err
+	case err != nil:
+		slog.Warn("No output detected, falling back to full screen capture", slog.String("error", err.Error()))
+	}
+
+	args := []string{"-t", "png", "-l", "1"}
+	if o.Name != "" {
+		slog.Info("Detected active output", slog.String("output", o.Name))
+		args = append(args, "-o", o.Name)
+	}
+	args = append(args, "-")
+	cmd := exec.CommandContext(ctx, grimPath, args...)
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	// Pass necessary Wayland env variables explicitly
+	cmd.Env = append(os.Environ(),
+		"XDG_RUNTIME_DIR="+xdg,
+		"WAYLAND_DISPLAY="+wayland,
+	)
+
+	slog.Debug("Running grim screenshot command", slog.Any("args", cmd.Args))
+
+	if err := cmd.Run(); err != nil {
+		slog.Error("Failed to take screenshot", slog.String("error", err.Error()), slog.String("stderr", stderr.String()))
+		return Frame{}, fmt.Errorf("failed to take screenshot: %w, stderr: %s", err, stderr.String())
+	}
+
+	return Frame{PNG: out.Bytes(), Output: o.Name}, nil
+}
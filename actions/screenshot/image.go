@@ -0,0 +1,164 @@
+package screenshot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+)
+
+// Image formats.
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+
+	defaultQuality = 85
+)
+
+// Options select what's captured and how it's encoded. A zero MaxWidth or MaxHeight doesn't
+// limit that dimension; the image is scaled down (never up) to fit, keeping its aspect ratio.
+type Options struct {
+	Output    string
+	Format    string
+	Quality   int
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Image is an encoded screenshot.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Output      string
+	Backend     string
+	Width       int
+	Height      int
+}
+
+// Validate normalizes the format and checks the options.
+func (o *Options) Validate() error {
+	switch strings.ToLower(o.Format) {
+	case "", FormatPNG:
+		o.Format = FormatPNG
+	case FormatJPEG, "jpg":
+		o.Format = FormatJPEG
+	default:
+		return fmt.Errorf("invalid format %q, must be png or jpeg", o.Format)
+	}
+
+	switch {
+	case o.Quality == 0:
+		o.Quality = defaultQuality
+	case o.Quality < 1 || o.Quality > 100:
+		return fmt.Errorf("invalid quality %d, must be 1-100", o.Quality)
+	}
+
+	if o.MaxWidth < 0 || o.MaxHeight < 0 {
+		return fmt.Errorf("max width and height must be positive")
+	}
+	return nil
+}
+
+// encode scales a frame to fit the options and encodes it in the requested format.
+// A PNG that doesn't need scaling is returned as is.
+func encode(frame Frame, opts Options) (Image, error) {
+	img := frame.Image
+	var sw, sh int
+	if img != nil {
+		sw, sh = img.Bounds().Dx(), img.Bounds().Dy()
+	} else {
+		cfg, err := png.DecodeConfig(bytes.NewReader(frame.PNG))
+		if err != nil {
+			return Image{}, fmt.Errorf("failed to read screenshot: %w", err)
+		}
+		sw, sh = cfg.Width, cfg.Height
+	}
+
+	w, h := fit(sw, sh, opts.MaxWidth, opts.MaxHeight)
+	if img == nil && opts.Format == FormatPNG && w == sw && h == sh {
+		return Image{Data: frame.PNG, ContentType: "image/png", Width: w, Height: h}, nil
+	}
+
+	if img == nil {
+		var err error
+		if img, err = png.Decode(bytes.NewReader(frame.PNG)); err != nil {
+			return Image{}, fmt.Errorf("failed to decode screenshot: %w", err)
+		}
+	}
+	if w != sw || h != sh {
+		img = scale(img, w, h)
+	}
+
+	var err error
+	var buf bytes.Buffer
+	ret := Image{Width: w, Height: h}
+	switch opts.Format {
+	case FormatJPEG:
+		ret.ContentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.Quality})
+	default:
+		ret.ContentType = "image/png"
+		err = (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&buf, img)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+
+	ret.Data = buf.Bytes()
+	return ret, nil
+}
+
+// fit returns the largest size no bigger than w x h that fits in maxW x maxH with the same aspect ratio.
+func fit(w, h, maxW, maxH int) (int, int) {
+	ratio := 1.0
+	if maxW > 0 && w > maxW {
+		ratio = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		ratio = min(ratio, float64(maxH)/float64(h))
+	}
+	if ratio == 1.0 {
+		return w, h
+	}
+	return max(1, int(float64(w)*ratio)), max(1, int(float64(h)*ratio))
+}
+
+// scale resizes img to w x h by averaging the source pixels that fall in each destination pixel,
+// which is cheap and looks right when shrinking.
+func scale(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for dy := 0; dy < h; dy++ {
+		y0, y1 := dy*sh/h, max((dy+1)*sh/h, dy*sh/h+1)
+		for dx := 0; dx < w; dx++ {
+			x0, x1 := dx*sw/w, max((dx+1)*sw/w, dx*sw/w+1)
+
+			var r, g, bl, a, n uint32
+			for y := y0; y < y1; y++ {
+				i := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					bl += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(dx, dy)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,71 @@
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		wantW, wantH     int
+	}{
+		{w: 1920, h: 1080, wantW: 1920, wantH: 1080},
+		{w: 1920, h: 1080, maxW: 3840, maxH: 2160, wantW: 1920, wantH: 1080},
+		{w: 1920, h: 1080, maxW: 960, wantW: 960, wantH: 540},
+		{w: 1920, h: 1080, maxH: 360, wantW: 640, wantH: 360},
+		{w: 1920, h: 1080, maxW: 640, maxH: 640, wantW: 640, wantH: 360},
+		{w: 1080, h: 1920, maxW: 640, maxH: 640, wantW: 360, wantH: 640},
+		{w: 4000, h: 1, maxW: 100, wantW: 100, wantH: 1},
+	}
+
+	for _, tt := range tests {
+		w, h := fit(tt.w, tt.h, tt.maxW, tt.maxH)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fit(%d, %d, %d, %d): expected %dx%d, got %dx%d", tt.w, tt.h, tt.maxW, tt.maxH, tt.wantW, tt.wantH, w, h)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	// 4x2: the left half is black and white pixels, the right half is red
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	src.Set(0, 0, color.White)
+	src.Set(1, 0, color.Black)
+	src.Set(0, 1, color.Black)
+	src.Set(1, 1, color.White)
+	for y := 0; y < 2; y++ {
+		for x := 2; x < 4; x++ {
+			src.Set(x, y, color.RGBA{R: 200, A: 0xff})
+		}
+	}
+
+	dst := scale(src, 2, 1)
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("expected a 2x1 image, got %v", b)
+	}
+
+	r, g, b, a := dst.At(0, 0).RGBA()
+	if r>>8 != 127 || g>>8 != 127 || b>>8 != 127 || a>>8 != 255 {
+		t.Errorf("expected the left pixel to be averaged to grey, got %d,%d,%d,%d", r>>8, g>>8, b>>8, a>>8)
+	}
+	if got := color.RGBAModel.Convert(dst.At(1, 0)); got != (color.RGBA{R: 200, A: 0xff}) {
+		t.Errorf("expected the right pixel to stay red, got %v", got)
+	}
+}
+
+func TestScaleUp(t *testing.T) {
+	src := image.NewRGBA(image.Rect(10, 10, 12, 11)) // bounds that don't start at 0,0
+	src.Set(10, 10, color.RGBA{R: 10, A: 0xff})
+	src.Set(11, 10, color.RGBA{R: 20, A: 0xff})
+
+	dst := scale(src, 4, 2)
+	for y := 0; y < 2; y++ {
+		for x, want := range []uint8{10, 10, 20, 20} {
+			if got := color.RGBAModel.Convert(dst.At(x, y)).(color.RGBA).R; got != want {
+				t.Errorf("%d,%d: expected %d, got %d", x, y, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Output is a display output as reported by wlr-randr.
+type Output struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Make        string  `json:"make,omitempty"`
+	Model       string  `json:"model,omitempty"`
+	Enabled     bool    `json:"enabled"`
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	Refresh     float64 `json:"refresh,omitempty"`
+	Position    string  `json:"position,omitempty"`
+	Transform   string  `json:"transform,omitempty"`
+	Scale       float64 `json:"scale,omitempty"`
+}
+
+// Outputs lists the outputs that can be captured by the current backend.
+func Outputs(ctx context.Context) ([]Output, error) {
+	b, err := CurrentBackend()
+	if err != nil {
+		return nil, err
+	}
+	return b.Outputs(ctx)
+}
+
+// wlrOutputs lists the outputs of the Wayland session.
+func wlrOutputs(ctx context.Context) ([]Output, error) {
+	cmd := exec.CommandContext(ctx, "wlr-randr")
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run wlr-randr: %w, stderr: %s", err, stderr.String())
+	}
+
+	return parseWLROutputs(out.String()), nil
+}
+
+// parseWLROutputs parses the output of wlr-randr, which looks like:
+//
+//	HDMI-A-1 "Samsung Electric Company SAMSUNG 0x01000E00 (HDMI-A-1)"
+//	  Make: Samsung Electric Company
+//	  Enabled: yes
+//	  Modes:
+//	    1920x1080 px, 60.000000 Hz (preferred, current)
+//	  Position: 0,0
+//	  Transform: normal
+//	  Scale: 1.000000
+func parseWLROutputs(s string) []Output {
+	var outputs []Output
+	var cur *Output
+	inModes := false
+
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// the header of an output isn't indented
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			name, desc, _ := strings.Cut(line, " ")
+			outputs = append(outputs, Output{Name: name, Description: strings.Trim(strings.TrimSpace(desc), `"`)})
+			cur = &outputs[len(outputs)-1]
+			inModes = false
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		key, value, ok := strings.Cut(trimmed, ":")
+		if inModes && (!ok || strings.Contains(key, " px")) {
+			if strings.Contains(trimmed, "current") {
+				cur.Width, cur.Height, cur.Refresh = parseMode(trimmed)
+			}
+			continue
+		}
+		inModes = false
+
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Make":
+			cur.Make = value
+		case "Model":
+			cur.Model = value
+		case "Enabled":
+			cur.Enabled = value == "yes"
+		case "Modes":
+			inModes = true
+		case "Position":
+			cur.Position = value
+		case "Transform":
+			cur.Transform = value
+		case "Scale":
+			cur.Scale, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+
+	return outputs
+}
+
+// parseMode parses a mode like "1920x1080 px, 60.000000 Hz (preferred, current)".
+func parseMode(mode string) (int, int, float64) {
+	var w, h int
+	var refresh float64
+	size, rest, _ := strings.Cut(mode, " px")
+	fmt.Sscanf(size, "%dx%d", &w, &h)
+
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	if hz, _, ok := strings.Cut(strings.TrimSpace(rest), " Hz"); ok {
+		refresh, _ = strconv.ParseFloat(hz, 64)
+	}
+	return w, h, refresh
+}
+
+// findOutput returns the named output, or the first enabled one if name is empty.
+func findOutput(outputs []Output, name string) (Output, error) {
+	for _, o := range outputs {
+		if name == "" && o.Enabled {
+			return o, nil
+		}
+		if name != "" && o.Name == name {
+			if !o.Enabled {
+				return o, fmt.Errorf("%w: %s is disabled", ErrUnknownOutput, name)
+			}
+			return o, nil
+		}
+	}
+
+	if name == "" {
+		return Output{}, fmt.Errorf("no enabled output found")
+	}
+	return Output{}, fmt.Errorf("%w %q", ErrUnknownOutput, name)
+}
@@ -0,0 +1,108 @@
+package screenshot
+
+import (
+	"errors"
+	"testing"
+)
+
+const wlrRandr = `HDMI-A-1 "Samsung Electric Company SAMSUNG 0x01000E00 (HDMI-A-1)"
+  Make: Samsung Electric Company
+  Model: SAMSUNG
+  Serial: 0x01000E00
+  Physical size: 1600x900 mm
+  Enabled: yes
+  Modes:
+    720x400 px, 70.082001 Hz
+    1920x1080 px, 60.000000 Hz (preferred, current)
+    1920x1080 px, 50.000000 Hz
+  Position: 0,0
+  Transform: normal
+  Scale: 1.000000
+  Adaptive Sync: disabled
+HDMI-A-2 "Dell Inc. DELL P2419H (HDMI-A-2)"
+  Make: Dell Inc.
+  Model: DELL P2419H
+  Enabled: no
+  Modes:
+    1920x1080 px, 60.000000 Hz (preferred)
+  Position: 1920,0
+  Transform: 90
+  Scale: 1.500000
+`
+
+func TestParseWLROutputs(t *testing.T) {
+	outputs := parseWLROutputs(wlrRandr)
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs, got %d", len(outputs))
+	}
+
+	want := Output{
+		Name:        "HDMI-A-1",
+		Description: "Samsung Electric Company SAMSUNG 0x01000E00 (HDMI-A-1)",
+		Make:        "Samsung Electric Company",
+		Model:       "SAMSUNG",
+		Enabled:     true,
+		Width:       1920,
+		Height:      1080,
+		Refresh:     60,
+		Position:    "0,0",
+		Transform:   "normal",
+		Scale:       1,
+	}
+	if outputs[0] != want {
+		t.Errorf("expected %+v, got %+v", want, outputs[0])
+	}
+
+	want = Output{
+		Name:        "HDMI-A-2",
+		Description: "Dell Inc. DELL P2419H (HDMI-A-2)",
+		Make:        "Dell Inc.",
+		Model:       "DELL P2419H",
+		Position:    "1920,0",
+		Transform:   "90",
+		Scale:       1.5,
+	}
+	if outputs[1] != want {
+		t.Errorf("expected %+v, got %+v", want, outputs[1])
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		w, h    int
+		refresh float64
+	}{
+		{mode: "1920x1080 px, 60.000000 Hz (preferred, current)", w: 1920, h: 1080, refresh: 60},
+		{mode: "3840x2160 px, 29.981001 Hz (current)", w: 3840, h: 2160, refresh: 29.981001},
+		{mode: "1280x720 px", w: 1280, h: 720},
+		{mode: "garbage"},
+	}
+
+	for _, tt := range tests {
+		w, h, refresh := parseMode(tt.mode)
+		if w != tt.w || h != tt.h || refresh != tt.refresh {
+			t.Errorf("%q: expected %dx%d@%v, got %dx%d@%v", tt.mode, tt.w, tt.h, tt.refresh, w, h, refresh)
+		}
+	}
+}
+
+func TestFindOutput(t *testing.T) {
+	outputs := parseWLROutputs(wlrRandr)
+
+	if o, err := findOutput(outputs, ""); err != nil || o.Name != "HDMI-A-1" {
+		t.Errorf("expected the first enabled output, got %q, %v", o.Name, err)
+	}
+	if o, err := findOutput(outputs, "HDMI-A-1"); err != nil || o.Name != "HDMI-A-1" {
+		t.Errorf("expected HDMI-A-1, got %q, %v", o.Name, err)
+	}
+	if _, err := findOutput(outputs, "HDMI-A-2"); !errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("expected a disabled output to be unknown, got %v", err)
+	}
+	if _, err := findOutput(outputs, "DP-1"); !errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("expected DP-1 to be unknown, got %v", err)
+	}
+	if _, err := findOutput(nil, ""); err == nil || errors.Is(err, ErrUnknownOutput) {
+		t.Errorf("expected an error without enabled outputs, got %v", err)
+	}
+}
@@ -1,97 +1,60 @@
 package screenshot
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/exec"
-	"strings"
 )
 
-// Take -> moving this to wayland since x11 is deprecated
-func Take(ctx context.Context) ([]byte, error) {
-	slog.Info("Taking screenshot of the Pi using grim")
+// ErrUnknownOutput is returned when a screenshot is requested of an output that doesn't exist or is disabled.
+var ErrUnknownOutput = errors.New("unknown output")
 
-	xdg := os.Getenv("XDG_RUNTIME_DIR")
-	wayland := os.Getenv("WAYLAND_DISPLAY")
-
-	if xdg == "" || wayland == "" {
-		slog.Warn("Environment not ready", slog.String("XDG_RUNTIME_DIR", xdg), slog.String("WAYLAND_DISPLAY", wayland))
-		return nil, fmt.Errorf("environment not ready: XDG_RUNTIME_DIR=%q, WAYLAND_DISPLAY=%q", xdg, wayland)
+// Take captures opts.Output (or the default output) with the current backend, then scales and
+// encodes it as opts asks.
+func Take(ctx context.Context, opts Options) (Image, error) {
+	if err := opts.Validate(); err != nil {
+		return Image{}, err
 	}
 
-	// Detect active output
-	output, err := detectActiveOutput(ctx)
+	img, err := capture(ctx, opts)
 	if err != nil {
-		slog.Error("Failed to detect active output", slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to detect active output: %w", err)
-	}
-
-	var cmd *exec.Cmd
-	if output != "" {
-		slog.Info("Detected active output", slog.String("output", output))
-		cmd = exec.CommandContext(ctx, "/usr/bin/grim", "-o", output, "-")
-	} else {
-		slog.Warn("No output detected, falling back to full screen capture")
-		cmd = exec.CommandContext(ctx, "/usr/bin/grim", "-")
+		return Image{}, err
 	}
 
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-
-	// Pass necessary Wayland env variables explicitly
-	cmd.Env = append(os.Environ(),
-		"XDG_RUNTIME_DIR="+xdg,
-		"WAYLAND_DISPLAY="+wayland,
-	)
-
-	slog.Debug("Running grim screenshot command", slog.Any("args", cmd.Args))
+	slog.Info("Screenshot taken successfully", slog.String("backend", img.Backend), slog.Int("size_bytes", len(img.Data)), slog.String("format", opts.Format), slog.Int("width", img.Width), slog.Int("height", img.Height))
+	return img, nil
+}
 
-	err = cmd.Run()
+// capture takes a screenshot with validated options.
+func capture(ctx context.Context, opts Options) (Image, error) {
+	frame, backend, err := captureFrame(ctx, opts.Output)
 	if err != nil {
-		slog.Error("Failed to take screenshot", slog.String("error", err.Error()), slog.String("stderr", stderr.String()))
-		return nil, fmt.Errorf("failed to take screenshot: %w, stderr: %s", err, stderr.String())
+		return Image{}, err
 	}
 
-	slog.Info("Screenshot taken successfully", slog.Int("size_bytes", out.Len()))
-	return out.Bytes(), nil
+	img, err := encode(frame, opts)
+	if err != nil {
+		return Image{}, err
+	}
+	img.Output = frame.Output
+	img.Backend = backend
+	return img, nil
 }
 
-// detectActiveOutput detects the active output in a Wayland session.
-func detectActiveOutput(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "wlr-randr")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to run wlr-randr: %w", err)
+// captureFrame captures output with the current backend, returning the frame and the backend's name.
+func captureFrame(ctx context.Context, output string) (Frame, string, error) {
+	b, err := CurrentBackend()
+	if err != nil {
+		return Frame{}, "", err
 	}
-
-	lines := strings.Split(out.String(), "\n")
-	for i := range lines {
-		line := lines[i]
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, " ") {
-			continue
-		}
-
-		// Line is the output header: e.g., "DSI-1 \"(null) (null) (DSI-1)\""
-		outputName := strings.Fields(line)[0]
-
-		// Look ahead for "Enabled: yes"
-		for j := i + 1; j < len(lines); j++ {
-			subLine := lines[j]
-			if !strings.HasPrefix(subLine, " ") {
-				break // next block
-			}
-			if strings.Contains(subLine, "Enabled: yes") {
-				return outputName, nil
-			}
-		}
+	if err := b.Available(); err != nil {
+		return Frame{}, "", fmt.Errorf("%w: %s: %s", ErrNoBackend, b.Name(), err)
 	}
 
-	return "", fmt.Errorf("no enabled output found")
+	frame, err := b.Capture(ctx, output)
+	if err != nil {
+		return Frame{}, "", err
+	}
+	return frame, b.Name(), nil
 }
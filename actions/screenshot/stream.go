@@ -0,0 +1,293 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/localsystem"
+	"github.com/byuoitav/device-monitoring/messenger"
+	"github.com/byuoitav/device-monitoring/model"
+)
+
+const (
+	defaultStreamFPS        = 2
+	maxStreamFPS            = 15
+	defaultStreamWidth      = 1280
+	defaultStreamHeight     = 720
+	defaultStreamQuality    = 70
+	defaultStreamMaxViewers = 3
+)
+
+// ErrTooManyViewers is returned when the screen stream already has as many viewers as it allows.
+var ErrTooManyViewers = errors.New("too many viewers")
+
+// StreamConfig sets how the live screen stream is captured. Frames are JPEGs scaled to fit
+// MaxWidth x MaxHeight, captured FPS times a second while anyone is watching.
+type StreamConfig struct {
+	FPS        float64 `json:"fps,omitempty"`
+	MaxWidth   int     `json:"max-width,omitempty"`
+	MaxHeight  int     `json:"max-height,omitempty"`
+	Quality    int     `json:"quality,omitempty"`
+	MaxViewers int     `json:"max-viewers,omitempty"`
+	Output     string  `json:"output,omitempty"`
+}
+
+// Viewer is someone watching the screen stream. FPS, MaxWidth and MaxHeight are what the viewer
+// asked for, limited to the stream config; zero means the configured value.
+type Viewer struct {
+	Name      string    `json:"name,omitempty"`
+	Address   string    `json:"address"`
+	UserAgent string    `json:"user-agent,omitempty"`
+	Since     time.Time `json:"since"`
+	FPS       float64   `json:"fps"`
+	MaxWidth  int       `json:"max-width,omitempty"`
+	MaxHeight int       `json:"max-height,omitempty"`
+}
+
+type viewer struct {
+	Viewer
+	frames   chan []byte
+	lastSent time.Time
+}
+
+var (
+	streamMu    sync.Mutex
+	streamCfg   = StreamConfig{}.withDefaults()
+	viewers     = make(map[*viewer]struct{})
+	stopCapture context.CancelFunc
+	// captures tracks the capture goroutines, which finish their frame after being stopped
+	captures sync.WaitGroup
+)
+
+// ConfigureStream sets how the screen stream is captured. Viewers that are already watching pick
+// it up the next time the stream starts.
+func ConfigureStream(cfg StreamConfig) error {
+	cfg = cfg.withDefaults()
+	switch {
+	case cfg.FPS < 0 || cfg.FPS > maxStreamFPS:
+		return fmt.Errorf("invalid stream fps %v, must be up to %d", cfg.FPS, maxStreamFPS)
+	case cfg.MaxWidth < 0 || cfg.MaxHeight < 0:
+		return fmt.Errorf("stream max width and height must be positive")
+	case cfg.Quality < 1 || cfg.Quality > 100:
+		return fmt.Errorf("invalid stream quality %d, must be 1-100", cfg.Quality)
+	case cfg.MaxViewers < 0:
+		return fmt.Errorf("invalid stream max viewers %d", cfg.MaxViewers)
+	}
+
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	streamCfg = cfg
+	return nil
+}
+
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.FPS == 0 {
+		c.FPS = defaultStreamFPS
+	}
+	if c.MaxWidth == 0 && c.MaxHeight == 0 {
+		c.MaxWidth, c.MaxHeight = defaultStreamWidth, defaultStreamHeight
+	}
+	if c.Quality == 0 {
+		c.Quality = defaultStreamQuality
+	}
+	if c.MaxViewers == 0 {
+		c.MaxViewers = defaultStreamMaxViewers
+	}
+	return c
+}
+
+// Viewers returns who is watching the screen stream.
+func Viewers() []Viewer {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	return listViewers()
+}
+
+// Watch adds v as a viewer of the screen stream, starting the capture if nobody else was watching.
+// JPEG frames are sent on the returned channel; a viewer that falls behind only gets the newest
+// one. stop must be called once v stops watching, and the capture stops with the last viewer.
+func Watch(v Viewer) (<-chan []byte, func(), error) {
+	if v.FPS < 0 || v.MaxWidth < 0 || v.MaxHeight < 0 {
+		return nil, nil, fmt.Errorf("fps, max width and max height must be positive")
+	}
+	if _, err := CurrentBackend(); err != nil {
+		return nil, nil, err
+	}
+
+	streamMu.Lock()
+	if len(viewers) >= streamCfg.MaxViewers {
+		streamMu.Unlock()
+		return nil, nil, fmt.Errorf("%w: the screen stream allows %d", ErrTooManyViewers, streamCfg.MaxViewers)
+	}
+
+	v = streamCfg.limit(v)
+	v.Since = time.Now()
+	w := &viewer{Viewer: v, frames: make(chan []byte, 1)}
+	viewers[w] = struct{}{}
+	if stopCapture == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopCapture = cancel
+		captures.Add(1)
+		go func(cfg StreamConfig) {
+			defer captures.Done()
+			captureStream(ctx, cfg)
+		}(streamCfg)
+	}
+	current := listViewers()
+	streamMu.Unlock()
+
+	slog.Info("Screen stream viewer joined", slog.String("address", v.Address), slog.String("name", v.Name), slog.Int("viewers", len(current)))
+	sendViewersEvent(current)
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			streamMu.Lock()
+			delete(viewers, w)
+			if len(viewers) == 0 && stopCapture != nil {
+				stopCapture()
+				stopCapture = nil
+			}
+			current := listViewers()
+			streamMu.Unlock()
+
+			slog.Info("Screen stream viewer left", slog.String("address", v.Address), slog.String("name", v.Name), slog.Int("viewers", len(current)))
+			sendViewersEvent(current)
+		})
+	}
+	return w.frames, stop, nil
+}
+
+// limit fills in a viewer's fps and size from the config, and keeps them within it.
+func (c StreamConfig) limit(v Viewer) Viewer {
+	if v.FPS == 0 || v.FPS > c.FPS {
+		v.FPS = c.FPS
+	}
+	if v.MaxWidth == 0 || c.MaxWidth > 0 && v.MaxWidth > c.MaxWidth {
+		v.MaxWidth = c.MaxWidth
+	}
+	if v.MaxHeight == 0 || c.MaxHeight > 0 && v.MaxHeight > c.MaxHeight {
+		v.MaxHeight = c.MaxHeight
+	}
+	return v
+}
+
+// captureStream captures frames at the configured rate until ctx is cancelled, and hands them to
+// every viewer that is due one, at the size it asked for.
+func captureStream(ctx context.Context, cfg StreamConfig) {
+	interval := time.Duration(float64(time.Second) / cfg.FPS)
+	slog.Info("Starting screen stream", slog.Float64("fps", cfg.FPS), slog.Int("max_width", cfg.MaxWidth), slog.Int("max_height", cfg.MaxHeight))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+
+	for {
+		frame, _, err := captureFrame(ctx, cfg.Output)
+		switch {
+		case ctx.Err() != nil:
+			slog.Info("Stopped screen stream, nobody is watching")
+			return
+		case err != nil:
+			// only log when the error changes, so a broken backend doesn't flood the log
+			if lastErr == nil || lastErr.Error() != err.Error() {
+				slog.Warn("Failed to capture screen stream frame", slog.Any("error", err))
+			}
+			lastErr = err
+		default:
+			lastErr = nil
+			if err := deliver(frame, cfg, interval, time.Now()); err != nil {
+				slog.Warn("Failed to encode screen stream frame", slog.Any("error", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped screen stream, nobody is watching")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver encodes frame once for each size that's asked for, and sends it to every viewer that's
+// due a frame. A viewer is due one when its last one was at least 1/FPS ago, give or take half of
+// the capture interval.
+func deliver(frame Frame, cfg StreamConfig, interval time.Duration, now time.Time) error {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	encoded := make(map[[2]int][]byte)
+	for v := range viewers {
+		if now.Sub(v.lastSent) < time.Duration(float64(time.Second)/v.FPS)-interval/2 {
+			continue
+		}
+
+		size := [2]int{v.MaxWidth, v.MaxHeight}
+		data, ok := encoded[size]
+		if !ok {
+			img, err := encode(frame, Options{Format: FormatJPEG, Quality: cfg.Quality, MaxWidth: v.MaxWidth, MaxHeight: v.MaxHeight})
+			if err != nil {
+				return err
+			}
+			data = img.Data
+			encoded[size] = data
+		}
+
+		v.send(data)
+		v.lastSent = now
+	}
+	return nil
+}
+
+// send hands a frame to the viewer, replacing the one it hasn't read yet if it's fallen behind.
+func (v *viewer) send(frame []byte) {
+	select {
+	case <-v.frames:
+	default:
+	}
+	v.frames <- frame
+}
+
+// listViewers returns the viewers, oldest first. streamMu must be held.
+func listViewers() []Viewer {
+	ret := make([]Viewer, 0, len(viewers))
+	for v := range viewers {
+		ret = append(ret, v.Viewer)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Since.Before(ret[j].Since)
+	})
+	return ret
+}
+
+// sendViewersEvent records who is watching the screen, so support can see when it's being viewed remotely.
+func sendViewersEvent(current []Viewer) {
+	systemID, err := localsystem.SystemID()
+	if err != nil {
+		slog.Warn("unable to send screen stream event", slog.Any("error", err))
+		return
+	}
+
+	deviceInfo := model.GenerateBasicDeviceInfo(systemID)
+	messenger.Get().SendEvent(model.ToCommonEvent(model.Event{
+		GeneratingSystem: systemID,
+		Timestamp:        time.Now(),
+		EventTags: []string{
+			model.Support,
+			model.AutoGenerated,
+		},
+		TargetDevice: deviceInfo,
+		AffectedRoom: deviceInfo.BasicRoomInfo,
+		Key:          "screen-stream-viewers",
+		Value:        strconv.Itoa(len(current)),
+		Data:         current,
+	}))
+}
@@ -0,0 +1,216 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// useFakeStream sets up an available backend and the stream config for a test.
+func useFakeStream(t *testing.T, cfg StreamConfig) {
+	t.Helper()
+
+	available := true
+	saved := backends
+	backends = []Backend{fakeBackend{name: BackendFramebuffer, available: &available}}
+	t.Cleanup(func() {
+		// the capture can still be finishing a frame after the last viewer stopped it
+		captures.Wait()
+		backends = saved
+		configured, backend = "", nil
+
+		streamMu.Lock()
+		streamCfg = StreamConfig{}.withDefaults()
+		streamMu.Unlock()
+	})
+
+	if err := Configure(Config{Stream: cfg}); err != nil {
+		t.Fatalf("failed to configure: %s", err)
+	}
+}
+
+func TestConfigureKeepsBackendWithInvalidStream(t *testing.T) {
+	useFakeStream(t, StreamConfig{})
+
+	err := Configure(Config{Backend: "framebuffer", Stream: StreamConfig{FPS: maxStreamFPS + 1}})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid stream fps")
+	}
+	if b, err := CurrentBackend(); err != nil || b.Name() != BackendFramebuffer {
+		t.Errorf("expected the configured framebuffer backend, got %v, %v", b, err)
+	}
+}
+
+func TestWatchViewerCap(t *testing.T) {
+	useFakeStream(t, StreamConfig{MaxViewers: 2})
+
+	var stops []func()
+	t.Cleanup(func() {
+		for _, stop := range stops {
+			stop()
+		}
+	})
+	for i := 0; i < 2; i++ {
+		_, stop, err := Watch(Viewer{Address: "10.0.0.1"})
+		if err != nil {
+			t.Fatalf("viewer %d: unexpected error: %s", i, err)
+		}
+		stops = append(stops, stop)
+	}
+
+	if _, _, err := Watch(Viewer{Address: "10.0.0.2"}); !errors.Is(err, ErrTooManyViewers) {
+		t.Fatalf("expected ErrTooManyViewers, got %v", err)
+	}
+
+	// a viewer leaving makes room for another
+	stops[0]()
+	_, stop, err := Watch(Viewer{Address: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("expected room for another viewer, got %s", err)
+	}
+	stops = append(stops, stop)
+
+	if got := len(Viewers()); got != 2 {
+		t.Errorf("expected 2 viewers, got %d", got)
+	}
+}
+
+func TestWatchStopsCaptureWithLastViewer(t *testing.T) {
+	useFakeStream(t, StreamConfig{})
+
+	frames, stop1, err := Watch(Viewer{Address: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, stop2, err := Watch(Viewer{Address: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case frame := <-frames:
+		if _, err := jpeg.Decode(bytes.NewReader(frame)); err != nil {
+			t.Errorf("expected a jpeg frame: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a frame")
+	}
+
+	stop1()
+	stop1() // stopping twice only removes the viewer once
+	streamMu.Lock()
+	running := stopCapture != nil
+	streamMu.Unlock()
+	if !running {
+		t.Fatalf("expected the capture to keep running for the other viewer")
+	}
+
+	stop2()
+	streamMu.Lock()
+	running = stopCapture != nil
+	streamMu.Unlock()
+	if running {
+		t.Errorf("expected the capture to stop with the last viewer")
+	}
+	if got := len(Viewers()); got != 0 {
+		t.Errorf("expected no viewers, got %d", got)
+	}
+}
+
+func TestViewerSendDropsOldFrame(t *testing.T) {
+	v := &viewer{frames: make(chan []byte, 1)}
+	v.send([]byte("old"))
+	v.send([]byte("new"))
+
+	if got := string(<-v.frames); got != "new" {
+		t.Errorf("expected the newest frame, got %q", got)
+	}
+	select {
+	case frame := <-v.frames:
+		t.Errorf("expected only one frame, got another: %q", frame)
+	default:
+	}
+}
+
+func TestStreamConfigLimit(t *testing.T) {
+	cfg := StreamConfig{FPS: 4, MaxWidth: 1280, MaxHeight: 720}
+
+	tests := []struct {
+		name string
+		v    Viewer
+		want Viewer
+	}{
+		{name: "defaults", v: Viewer{}, want: Viewer{FPS: 4, MaxWidth: 1280, MaxHeight: 720}},
+		{name: "lower", v: Viewer{FPS: 1, MaxWidth: 640, MaxHeight: 360}, want: Viewer{FPS: 1, MaxWidth: 640, MaxHeight: 360}},
+		{name: "capped", v: Viewer{FPS: 30, MaxWidth: 3840, MaxHeight: 2160}, want: Viewer{FPS: 4, MaxWidth: 1280, MaxHeight: 720}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.limit(tt.v); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// a dimension the config doesn't limit is only limited by the viewer
+	got := StreamConfig{FPS: 4, MaxWidth: 1280}.limit(Viewer{MaxHeight: 2160})
+	if got.MaxWidth != 1280 || got.MaxHeight != 2160 {
+		t.Errorf("expected 1280x2160, got %dx%d", got.MaxWidth, got.MaxHeight)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	cfg := StreamConfig{FPS: 4, MaxWidth: 64, MaxHeight: 36, Quality: 70}
+	interval := time.Second / 4
+
+	fast := &viewer{Viewer: cfg.limit(Viewer{}), frames: make(chan []byte, 1)}
+	slow := &viewer{Viewer: cfg.limit(Viewer{FPS: 1, MaxWidth: 32}), frames: make(chan []byte, 1)}
+
+	streamMu.Lock()
+	saved := viewers
+	viewers = map[*viewer]struct{}{fast: {}, slow: {}}
+	streamMu.Unlock()
+	t.Cleanup(func() {
+		streamMu.Lock()
+		viewers = saved
+		streamMu.Unlock()
+	})
+
+	frame, err := fakeBackend{}.Capture(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := deliver(frame, cfg, interval, now.Add(time.Duration(i)*interval)); err != nil {
+			t.Fatalf("failed to deliver frame %d: %s", i, err)
+		}
+
+		select {
+		case data := <-fast.frames:
+			if c, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil || c.Width != 64 {
+				t.Errorf("frame %d: expected a 64 wide jpeg for the fast viewer, got %+v, %v", i, c, err)
+			}
+		default:
+			t.Errorf("frame %d: expected a frame for the fast viewer", i)
+		}
+
+		select {
+		case data := <-slow.frames:
+			if i != 0 {
+				t.Errorf("frame %d: expected the slow viewer to only get the first frame of the second", i)
+			}
+			if c, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil || c.Width != 32 {
+				t.Errorf("frame %d: expected a 32 wide jpeg for the slow viewer, got %+v, %v", i, c, err)
+			}
+		default:
+			if i == 0 {
+				t.Errorf("expected the slow viewer to get the first frame")
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// x11Backend captures the root window of an X11 session with ImageMagick's import, or scrot if
+// that isn't installed. Outputs come from xrandr and are cropped out of the root window.
+type x11Backend struct{}
+
+func (x11Backend) Name() string {
+	return BackendX11
+}
+
+func (x11Backend) Available() error {
+	if os.Getenv("DISPLAY") == "" {
+		return fmt.Errorf("DISPLAY isn't set")
+	}
+	if _, err := x11Tool(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (x11Backend) Outputs(ctx context.Context) ([]Output, error) {
+	cmd := exec.CommandContext(ctx, "xrandr", "--query")
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run xrandr: %w, stderr: %s", err, stderr.String())
+	}
+	return parseXRandrOutputs(out.String()), nil
+}
+
+// Capture captures the root window, cropped to output if one is given.
+func (b x11Backend) Capture(ctx context.Context, output string) (Frame, error) {
+	tool, err := x11Tool()
+	if err != nil {
+		return Frame{}, err
+	}
+
+	var region image.Rectangle
+	if output != "" {
+		outputs, err := b.Outputs(ctx)
+		if err != nil {
+			return Frame{}, err
+		}
+		o, err := findOutput(outputs, output)
+		if err != nil {
+			return Frame{}, err
+		}
+		var x, y int
+		fmt.Sscanf(o.Position, "%d,%d", &x, &y)
+		region = image.Rect(x, y, x+o.Width, y+o.Height)
+	}
+
+	var data []byte
+	var stderr bytes.Buffer
+	switch filepath.Base(tool) {
+	case "import":
+		cmd := exec.CommandContext(ctx, tool, "-window", "root", "png:-")
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return Frame{}, fmt.Errorf("failed to take screenshot: %w, stderr: %s", err, stderr.String())
+		}
+		data = out.Bytes()
+	default:
+		dir, err := os.MkdirTemp("", "screenshot")
+		if err != nil {
+			return Frame{}, fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "screen.png")
+		cmd := exec.CommandContext(ctx, tool, path)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return Frame{}, fmt.Errorf("failed to take screenshot: %w, stderr: %s", err, stderr.String())
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return Frame{}, fmt.Errorf("failed to read screenshot: %w", err)
+		}
+	}
+
+	if region.Empty() {
+		return Frame{PNG: data}, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return Frame{}, fmt.Errorf("unable to crop screenshot to %s", output)
+	}
+	return Frame{Image: sub.SubImage(region.Intersect(img.Bounds())), Output: output}, nil
+}
+
+// x11Tool returns the path of the tool used to capture the screen.
+func x11Tool() (string, error) {
+	for _, tool := range []string{"import", "scrot"} {
+		if path, err := exec.LookPath(tool); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("neither import (ImageMagick) nor scrot is installed")
+}
+
+// parseXRandrOutputs parses the output of xrandr --query, which looks like:
+//
+//	Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 7680 x 7680
+//	HDMI-1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 509mm x 286mm
+//	   1920x1080     60.00*+  50.00
+//	HDMI-2 disconnected (normal left inverted right x axis y axis)
+func parseXRandrOutputs(s string) []Output {
+	var outputs []Output
+	var cur *Output
+
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "Screen" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") {
+			if len(fields) < 2 {
+				cur = nil
+				continue
+			}
+
+			outputs = append(outputs, Output{Name: fields[0], Description: fields[1]})
+			cur = &outputs[len(outputs)-1]
+			for _, f := range fields[2:] {
+				var w, h, x, y int
+				if n, _ := fmt.Sscanf(f, "%dx%d+%d+%d", &w, &h, &x, &y); n == 4 {
+					cur.Enabled = true
+					cur.Width, cur.Height = w, h
+					cur.Position = fmt.Sprintf("%d,%d", x, y)
+					break
+				}
+			}
+			continue
+		}
+
+		// the current mode's refresh rate is marked with a *
+		if cur == nil {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if strings.Contains(f, "*") {
+				cur.Refresh, _ = strconv.ParseFloat(strings.TrimRight(f, "*+"), 64)
+			}
+		}
+	}
+
+	return outputs
+}
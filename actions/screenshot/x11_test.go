@@ -0,0 +1,29 @@
+package screenshot
+
+import "testing"
+
+func TestParseXRandrOutputs(t *testing.T) {
+	in := `Screen 0: minimum 320 x 200, current 3840 x 1080, maximum 7680 x 7680
+HDMI-1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 509mm x 286mm
+   1920x1080     60.00*+  50.00    59.94
+   1280x720      60.00    50.00
+HDMI-2 connected 1920x1080+1920+0 (normal left inverted right x axis y axis) 527mm x 296mm
+   1920x1080     60.00 +  50.00*
+DP-1 disconnected (normal left inverted right x axis y axis)
+`
+	outputs := parseXRandrOutputs(in)
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 outputs, got %+v", outputs)
+	}
+
+	want := []Output{
+		{Name: "HDMI-1", Description: "connected", Enabled: true, Width: 1920, Height: 1080, Refresh: 60, Position: "0,0"},
+		{Name: "HDMI-2", Description: "connected", Enabled: true, Width: 1920, Height: 1080, Refresh: 50, Position: "1920,0"},
+		{Name: "DP-1", Description: "disconnected"},
+	}
+	for i := range want {
+		if outputs[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], outputs[i])
+		}
+	}
+}
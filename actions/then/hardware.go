@@ -95,6 +95,21 @@ func hardwareInfo(ctx context.Context, with []byte, log *zap.SugaredLogger) erro
 		}
 	}
 
+	// send info about write rates
+	if io, ok := info.Disk["io"].(map[string]localsystem.DiskIOInfo); ok {
+		for disk, stats := range io {
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+			tmp.Key = fmt.Sprintf("write-bytes-per-second-%s", disk)
+			tmp.Value = fmt.Sprintf("%v", stats.WriteBytesPerSec)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+
+			tmp.Key = fmt.Sprintf("writes-per-second-%s", disk)
+			tmp.Value = fmt.Sprintf("%v", stats.WritesPerSecond)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+		}
+	}
+
 	// send info about total disk usage
 	if usage, ok := info.Disk["usage"].(*disk.UsageStat); ok {
 		tmp := event
@@ -104,6 +119,68 @@ func hardwareInfo(ctx context.Context, with []byte, log *zap.SugaredLogger) erro
 		messenger.Get().SendEvent(model.ToCommonEvent(tmp))
 	}
 
+	// send info about each mount
+	if mounts, ok := info.Disk["mounts"].([]localsystem.MountInfo); ok {
+		for _, mount := range mounts {
+			name := localsystem.MountKey(mount.Mountpoint)
+
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+			tmp.Key = fmt.Sprintf("disk-used-percent-%s", name)
+			tmp.Value = fmt.Sprintf("%v", mount.UsedPercent)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+
+			tmp.Key = fmt.Sprintf("inodes-used-percent-%s", name)
+			tmp.Value = fmt.Sprintf("%v", mount.InodesUsedPercent)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+
+			// a filesystem remounted read-only usually means the sd card is dying
+			ro := event
+			ro.AddToTags(model.DetailState)
+			if mount.ReadOnly {
+				ro.AddToTags(model.Alert)
+			}
+			ro.Key = fmt.Sprintf("read-only-%s", name)
+			ro.Value = fmt.Sprintf("%v", mount.ReadOnly)
+			messenger.Get().SendEvent(model.ToCommonEvent(ro))
+		}
+	}
+
+	// send info about each network interface
+	if ifaces, ok := info.Network["stats"].(map[string]localsystem.InterfaceStats); ok {
+		for name, stats := range ifaces {
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+
+			send := func(key string, value any) {
+				tmp.Key = fmt.Sprintf("%s-%s", key, name)
+				tmp.Value = fmt.Sprintf("%v", value)
+				messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+			}
+
+			send("carrier", stats.Carrier)
+			send("rx-bytes-per-second", stats.RxBytesPerSec)
+			send("tx-bytes-per-second", stats.TxBytesPerSec)
+			send("rx-errors", stats.RxErrors)
+			send("tx-errors", stats.TxErrors)
+			send("rx-dropped", stats.RxDropped)
+			send("tx-dropped", stats.TxDropped)
+
+			if stats.SpeedMbps > 0 {
+				send("link-speed-mbps", stats.SpeedMbps)
+			}
+			if len(stats.Duplex) > 0 {
+				send("duplex", stats.Duplex)
+			}
+			if stats.Wireless != nil {
+				send("wifi-signal-dbm", stats.Wireless.SignalDBm)
+				if len(stats.Wireless.SSID) > 0 {
+					send("wifi-ssid", stats.Wireless.SSID)
+				}
+			}
+		}
+	}
+
 	// send info about avg # of processes in uninterruptible sleep
 	if avg, ok := info.Procs["avg-procs-u-sleep"]; ok {
 		tmp := event
@@ -113,6 +190,54 @@ func hardwareInfo(ctx context.Context, with []byte, log *zap.SugaredLogger) erro
 		messenger.Get().SendEvent(model.ToCommonEvent(tmp))
 	}
 
+	// send info about each watched process
+	if watched, ok := info.Procs["watched"].([]localsystem.WatchedProcessStats); ok {
+		for _, w := range watched {
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+
+			send := func(key string, value any) {
+				tmp.Key = fmt.Sprintf("process-%s-%s", w.Name, key)
+				tmp.Value = fmt.Sprintf("%v", value)
+				messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+			}
+
+			send("running", w.Running)
+			send("restarts", w.Restarts)
+			if w.Running {
+				send("rss", w.RSS)
+				send("cpu-percent", w.CPUPercent)
+				send("open-fds", w.OpenFDs)
+				send("threads", w.Threads)
+			}
+		}
+	}
+
+	// send rolling averages/maxima from the sampler
+	for name, summary := range info.Metrics {
+		tmp := event
+		tmp.AddToTags(model.DetailState, model.Metrics)
+
+		windows := map[string]localsystem.Window{
+			"1m":  summary.OneMinute,
+			"5m":  summary.FiveMinutes,
+			"15m": summary.FifteenMinutes,
+		}
+		for window, w := range windows {
+			if w.Samples == 0 {
+				continue
+			}
+
+			tmp.Key = fmt.Sprintf("%s-avg-%s", name, window)
+			tmp.Value = fmt.Sprintf("%v", w.Avg)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+
+			tmp.Key = fmt.Sprintf("%s-max-%s", name, window)
+			tmp.Value = fmt.Sprintf("%v", w.Max)
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+		}
+	}
+
 	return nil
 }
 
@@ -132,6 +257,72 @@ func deviceHardwareInfo(ctx context.Context, with []byte, log *zap.SugaredLogger
 		return fmt.Errorf("failed to get hardware info: %w", err)
 	}
 
+	changes, err := hardwareinfo.RecordHardwareInfo(info)
+	if err != nil {
+		log.Warnf("failed to record hardware info history: %s", err)
+	}
+
+	for _, change := range changes {
+		deviceInfo := model.GenerateBasicDeviceInfo(change.DeviceID)
+		messenger.Get().SendEvent(model.ToCommonEvent(model.Event{
+			GeneratingSystem: systemID,
+			Timestamp:        change.Timestamp,
+			EventTags:        []string{model.Hardware_Info, model.AutoGenerated},
+			TargetDevice:     deviceInfo,
+			AffectedRoom:     deviceInfo.BasicRoomInfo,
+			Key:              change.Kind,
+			Value:            fmt.Sprintf("%s: %s -> %s", change.Field, change.Old, change.New),
+			Data:             change,
+		}))
+	}
+
+	forecasts, dueChanges, err := hardwareinfo.RecordTimers(info)
+	if err != nil {
+		log.Warnf("failed to record lamp/filter timers: %s", err)
+	}
+
+	for _, f := range forecasts {
+		deviceInfo := model.GenerateBasicDeviceInfo(f.DeviceID)
+		event := model.Event{
+			GeneratingSystem: systemID,
+			Timestamp:        time.Now(),
+			EventTags:        []string{model.Hardware_Info, model.DetailState},
+			TargetDevice:     deviceInfo,
+			AffectedRoom:     deviceInfo.BasicRoomInfo,
+		}
+		key := hardwareinfo.Timer{Name: f.Timer}.EventKey()
+
+		event.Key = key
+		event.Value = fmt.Sprintf("%v", f.Hours)
+		messenger.Get().SendEvent(model.ToCommonEvent(event))
+
+		if f.ReplaceBy != nil {
+			event.Key = fmt.Sprintf("%s-replace-by", strings.TrimSuffix(key, "-hours"))
+			event.Value = f.ReplaceBy.Format("2006-01-02")
+			event.Data = f
+			messenger.Get().SendEvent(model.ToCommonEvent(event))
+		}
+	}
+
+	for _, f := range dueChanges {
+		deviceInfo := model.GenerateBasicDeviceInfo(f.DeviceID)
+		event := model.Event{
+			GeneratingSystem: systemID,
+			Timestamp:        time.Now(),
+			EventTags:        []string{model.Hardware_Info, model.AutoGenerated},
+			TargetDevice:     deviceInfo,
+			AffectedRoom:     deviceInfo.BasicRoomInfo,
+			Key:              fmt.Sprintf("%s-%s", strings.TrimSuffix(hardwareinfo.Timer{Name: f.Timer}.EventKey(), "-hours"), hardwareinfo.MaintenanceDue),
+			Value:            fmt.Sprintf("%v", f.Due),
+			Data:             f,
+		}
+		if f.Due {
+			event.AddToTags(model.Alert)
+		}
+
+		messenger.Get().SendEvent(model.ToCommonEvent(event))
+	}
+
 	// key: deviceID, value: structs.Hardware_Info
 	for k, v := range info {
 		// build base event
@@ -184,6 +375,22 @@ func deviceHardwareInfo(ctx context.Context, with []byte, log *zap.SugaredLogger
 			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
 		}
 
+		if len(v.Uptime) > 0 {
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+			tmp.Key = "uptime"
+			tmp.Value = v.Uptime
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+		}
+
+		for _, iface := range v.Interfaces {
+			tmp := event
+			tmp.AddToTags(model.DetailState)
+			tmp.Key = fmt.Sprintf("interface-status-%s", iface.Name)
+			tmp.Value = iface.Status
+			messenger.Get().SendEvent(model.ToCommonEvent(tmp))
+		}
+
 		if len(v.FilterStatus) > 0 {
 			tmp := event
 			tmp.AddToTags(model.DetailState)
@@ -13,6 +13,9 @@ import (
 	"github.com/byuoitav/device-monitoring/actions/health"
 	"github.com/byuoitav/device-monitoring/actions/ping"
 	"github.com/byuoitav/device-monitoring/actions/roomstate"
+	"github.com/byuoitav/device-monitoring/actions/screencheck"
+	"github.com/byuoitav/device-monitoring/couchdb"
+	"github.com/byuoitav/device-monitoring/handlers"
 	"github.com/byuoitav/device-monitoring/localsystem"
 	"github.com/byuoitav/device-monitoring/messenger"
 	"github.com/byuoitav/shipwright/actions/then"
@@ -29,6 +32,7 @@ func init() {
 	then.Add("hardware-info", toThenFunc(hardwareInfo))
 	then.Add("device-hardware-info", toThenFunc(deviceHardwareInfo))
 	then.Add("monitor-dividers", toThenFunc(monitorDividerSensors))
+	then.Add("screen-check", toThenFunc(screenCheck))
 }
 
 // slog to zap logger
@@ -324,12 +328,36 @@ func monitorDividerSensors(ctx context.Context, with []byte, log *zap.SugaredLog
 			return fmt.Errorf("no pins to monitor")
 		}
 
-		gpio.SetPins(pins)
+		// the topology comes from the same couch config as the pins
+		cfg, err := couchdb.GetMonitoringConfig(ctx, "")
+		if err != nil {
+			return fmt.Errorf("failed to get couch config: %w", err)
+		}
+
+		// monitors that are already running for the same config are left alone
+		if err := handlers.ReloadDividers(cfg, pins); err != nil {
+			return fmt.Errorf("failed to start divider sensor monitors: %w", err)
+		}
+	}
+
+	return nil
+}
 
-		for i := range pins {
-			go pins[i].Monitor()
+func screenCheck(ctx context.Context, with []byte, log *zap.SugaredLogger) error {
+	var cfg screencheck.Config
+	if len(with) > 0 {
+		if err := json.Unmarshal(with, &cfg); err != nil {
+			return fmt.Errorf("failed to unmarshal screen check config: %w", err)
 		}
 	}
 
+	// timeout if this takes longer than 30 seconds
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if _, err := screencheck.Check(ctx, cfg); err != nil {
+		return fmt.Errorf("unable to check screen: %w", err)
+	}
+
 	return nil
 }
@@ -2,6 +2,7 @@ package couchdb
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/url"
@@ -201,3 +202,23 @@ func GetMonitoringConfig(ctx context.Context, customSystemID string) (map[string
 
 	return cfg, nil
 }
+
+// DecodeMonitoringSection decodes the top-level key of a monitoring config document into v.
+// It returns false if the document does not have that key.
+func DecodeMonitoringSection(cfg map[string]any, key string, v any) (bool, error) {
+	section, ok := cfg[key]
+	if !ok || section == nil {
+		return false, nil
+	}
+
+	data, err := json.Marshal(section)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal %q monitoring config: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %q monitoring config: %w", key, err)
+	}
+
+	return true, nil
+}
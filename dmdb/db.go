@@ -1,91 +1,85 @@
 package dmdb
 
 import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
 	"github.com/byuoitav/common/nerr"
 )
 
-const (
-	dbLocation = "db"
-)
+// dbLocation is where the database is kept unless DMDB_PATH is set.
+const dbLocation = "/var/lib/device-monitoring"
 
 var (
-//	once sync.Once
-//	db   *badger.DB
+	once    sync.Once
+	initErr error
+	mu      sync.RWMutex
+	dir     string
 )
 
-func initDB() {
-	/*
-		once.Do(func() {
-			opts := badger.DefaultOptions
-			opts.Dir = dbLocation
-			opts.ValueDir = dbLocation
-			opts.ValueLogLoadingMode = options.FileIO //maybe?
-
-			var err error
-			log.L.Infof("Opening local database at: %s", dbLocation)
-
-			db, err = badger.Open(opts)
-			if err != nil {
-				log.L.Fatalf("failed to open badger db: %s", err)
-			}
-
-			// close the database connection if the program is killed
-			c := make(chan os.Signal)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-			go func() {
-				<-c
-				log.L.Infof("Closing local database.")
-				err := db.Close()
-				if err != nil {
-					log.L.Warnf("failed to close local database: %s", err)
-				}
-
-				os.Exit(0)
-			}()
-		})
-	*/
+// initDB makes sure the directory holding the database exists. Each key is stored as its own file.
+func initDB() *nerr.E {
+	once.Do(func() {
+		dir = os.Getenv("DMDB_PATH")
+		if dir == "" {
+			dir = dbLocation
+		}
+
+		initErr = os.MkdirAll(dir, 0o755)
+	})
+	if initErr != nil {
+		return nerr.Translate(initErr).Addf("failed to create local database at %s", dir)
+	}
+
+	return nil
+}
+
+func keyPath(key string) string {
+	return filepath.Join(dir, url.PathEscape(key))
 }
 
 // Put puts a key/value into the database
 func Put(key string, value []byte) *nerr.E {
-	/*
-		initDB()
-
-		err := db.Update(func(txn *badger.Txn) error {
-			return txn.Set([]byte(key), value)
-		})
-		if err != nil {
-			return nerr.Translate(err).Addf("failed to put key %s into the database", key)
-		}
+	if err := initDB(); err != nil {
+		return err.Addf("failed to put key %s into the database", key)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 
-	*/
+	// write to a temp file and rename it so a crash never leaves a half-written value
+	tmp := keyPath(key) + ".tmp"
+	if err := os.WriteFile(tmp, value, 0o644); err != nil {
+		return nerr.Translate(err).Addf("failed to put key %s into the database", key)
+	}
+
+	if err := os.Rename(tmp, keyPath(key)); err != nil {
+		return nerr.Translate(err).Addf("failed to put key %s into the database", key)
+	}
 
 	return nil
 }
 
-// Get returns a value at the given key
+// Get returns a value at the given key. A key that hasn't been set returns an empty value.
 func Get(key string) ([]byte, *nerr.E) {
-	/*
-		initDB()
-		value := []byte{}
-
-			err := db.View(func(txn *badger.Txn) error {
-				item, err := txn.Get([]byte(key))
-				if err != nil {
-					return err
-				}
-
-				value, err = item.ValueCopy(nil)
-				return err
-			})
-			if err != nil {
-				if err == badger.ErrKeyNotFound {
-					return value, nil
-				}
-
-				return value, nerr.Translate(err).Addf("failed to get '%s' from the local database", key)
-			}
-	*/
-
-	return []byte{}, nil
+	if err := initDB(); err != nil {
+		return []byte{}, err.Addf("failed to get '%s' from the local database", key)
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	value, err := os.ReadFile(keyPath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []byte{}, nil
+		}
+
+		return []byte{}, nerr.Translate(err).Addf("failed to get '%s' from the local database", key)
+	}
+
+	return value, nil
 }
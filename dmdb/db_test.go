@@ -0,0 +1,83 @@
+package dmdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "dmdb")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("DMDB_PATH", filepath.Join(dir, "db"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestGetUnsetKey(t *testing.T) {
+	b, err := Get("unset")
+	if err != nil {
+		t.Fatalf("failed to get an unset key: %s", err)
+	}
+	if len(b) != 0 {
+		t.Errorf("expected an empty value, got %q", b)
+	}
+}
+
+func TestPutGet(t *testing.T) {
+	if err := Put("divider-state", []byte("first")); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	if err := Put("divider-state", []byte("second")); err != nil {
+		t.Fatalf("failed to overwrite: %s", err)
+	}
+
+	b, err := Get("divider-state")
+	if err != nil {
+		t.Fatalf("failed to get: %s", err)
+	}
+	if string(b) != "second" {
+		t.Errorf("expected the overwritten value, got %q", b)
+	}
+
+	entries, rerr := os.ReadDir(dir)
+	if rerr != nil {
+		t.Fatalf("failed to list the database: %s", rerr)
+	}
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == ".tmp" {
+			t.Errorf("expected no temp files to be left behind, found %s", e.Name())
+		}
+	}
+}
+
+func TestKeysAreEscaped(t *testing.T) {
+	keys := map[string]string{
+		"hardware/history": "a",
+		"../escape":        "b",
+		"hardware":         "c",
+	}
+	for k, v := range keys {
+		if err := Put(k, []byte(v)); err != nil {
+			t.Fatalf("failed to put %q: %s", k, err)
+		}
+	}
+
+	for k, v := range keys {
+		b, err := Get(k)
+		if err != nil {
+			t.Fatalf("failed to get %q: %s", k, err)
+		}
+		if string(b) != v {
+			t.Errorf("%q: expected %q, got %q", k, v, b)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); err == nil {
+		t.Errorf("expected a key to stay inside the database directory")
+	}
+}
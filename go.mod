@@ -11,6 +11,7 @@ require (
 	github.com/gin-gonic/gin v1.10.0
 	github.com/go-kivik/kivik/v4 v4.4.0
 	github.com/gorilla/websocket v1.4.2
+	github.com/gosnmp/gosnmp v1.38.0
 	github.com/rs/zerolog v1.33.0
 	github.com/warthog618/go-gpiocdev v0.9.1
 )
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/byuoitav/device-monitoring/actions/alerts"
+	"github.com/gin-gonic/gin"
+)
+
+// GetActiveAlerts returns the resource alerts that are currently raised on this device
+func GetActiveAlerts(c *gin.Context) {
+	evaluator := alerts.Get()
+	if evaluator == nil {
+		c.JSON(http.StatusOK, []alerts.Alert{})
+		return
+	}
+	c.JSON(http.StatusOK, evaluator.Active())
+}
+
+// GetAlertThresholds returns the thresholds alerts are evaluated against
+func GetAlertThresholds(c *gin.Context) {
+	evaluator := alerts.Get()
+	if evaluator == nil {
+		c.JSON(http.StatusOK, []alerts.Threshold{})
+		return
+	}
+	c.JSON(http.StatusOK, evaluator.Thresholds())
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"log/slog"
 
+	"github.com/byuoitav/device-monitoring/actions/ping"
+	"github.com/byuoitav/device-monitoring/couchdb"
 	"github.com/byuoitav/device-monitoring/localsystem"
 	"github.com/gin-gonic/gin"
 )
@@ -24,11 +30,86 @@ func RebootPi(c *gin.Context) {
 	c.Data(http.StatusOK, "text/plain", []byte("Rebooting in 5 seconds..."))
 }
 
-// SetDHCPState toggles dhcp to be on/off
+// SetDHCPState turns dhcp on or off. If the device can't reach its gateway and couch afterwards
+// (within the timeout query parameter, 45s by default and at most 5m), the previous configuration
+// is restored.
 func SetDHCPState(c *gin.Context) {
 	state := c.Param("state")
 	slog.Info("Received request to set DHCP state", slog.String("state", state))
 
-	// TODO: implement actual DHCP-toggle logic here
-	c.String(http.StatusNotImplemented, "SetDHCPState is not yet implemented")
+	var enabled bool
+	switch strings.ToLower(state) {
+	case "on", "true", "enabled":
+		enabled = true
+	case "off", "false", "disabled":
+	default:
+		c.String(http.StatusBadRequest, fmt.Sprintf("invalid DHCP state %q, must be on or off", state))
+		return
+	}
+
+	timeout := localsystem.DefaultConnectivityTimeout
+	if s := c.Query("timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > localsystem.MaxConnectivityTimeout {
+			c.String(http.StatusBadRequest, fmt.Sprintf("invalid timeout %q, must be up to %s", s, localsystem.MaxConnectivityTimeout))
+			return
+		}
+		timeout = d
+	}
+
+	if err := localsystem.CanToggleDHCP(); err != nil {
+		c.String(http.StatusConflict, err.Error())
+		return
+	}
+
+	// the change can drop this connection, which mustn't stop the rollback
+	ctx := context.WithoutCancel(c.Request.Context())
+	err := localsystem.SetDHCP(ctx, enabled, timeout, checkConnectivity)
+	switch {
+	case errors.Is(err, localsystem.ErrInvalidStaticConfig):
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, localsystem.ErrDHCPChangeInProgress):
+		c.String(http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, localsystem.ErrRolledBack):
+		slog.Error("DHCP change rolled back", slog.Any("error", err))
+		c.String(http.StatusGatewayTimeout, err.Error())
+		return
+	case err != nil:
+		slog.Error("failed to set DHCP state", slog.Any("error", err))
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled})
+}
+
+// checkConnectivity confirms the device can ping its default gateway and reach couch.
+func checkConnectivity(ctx context.Context) error {
+	gw, err := localsystem.DefaultGateway()
+	if err != nil {
+		return err
+	}
+
+	pinger, err := ping.NewPinger()
+	if err != nil {
+		return err
+	}
+	defer pinger.Close()
+
+	result := pinger.Ping(ctx, ping.Config{Count: 1, Delay: time.Second}, ping.Host{ID: "gateway", Addr: gw.String()})["gateway"]
+	switch {
+	case result == nil:
+		return fmt.Errorf("no ping result for gateway %s", gw)
+	case result.Error != "":
+		return fmt.Errorf("unable to ping gateway %s: %s", gw, result.Error)
+	case result.PacketsReceived == 0:
+		return fmt.Errorf("gateway %s didn't respond", gw)
+	}
+
+	if err := couchdb.ValidateConnection(ctx); err != nil {
+		return fmt.Errorf("unable to reach couch: %w", err)
+	}
+	return nil
 }
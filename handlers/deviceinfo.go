@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"log/slog"
 
 	"github.com/byuoitav/device-monitoring/actions/hardwareinfo"
 	"github.com/byuoitav/device-monitoring/actions/health"
+	"github.com/byuoitav/device-monitoring/actions/screencheck"
 	"github.com/byuoitav/device-monitoring/actions/screenshot"
 	"github.com/byuoitav/device-monitoring/localsystem"
 	"github.com/gin-gonic/gin"
@@ -20,8 +23,11 @@ type DeviceInfo struct {
 	Hostname             string `json:"hostname,omitempty"`
 	ID                   string `json:"id,omitempty"`
 	IP                   string `json:"ip,omitempty"`
+	Interface            string `json:"interface,omitempty"`
 	InternetConnectivity bool   `json:"internet-connectivity"`
 
+	Interfaces []localsystem.Interface `json:"interfaces"`
+
 	DHCPInfo struct {
 		Enabled    bool `json:"enabled"`
 		Toggleable bool `json:"toggleable"`
@@ -53,6 +59,16 @@ func GetDeviceInfo(c *gin.Context) {
 	}
 
 	info.IP = ip.String()
+	if info.Interface, err = localsystem.PrimaryInterface(); err != nil {
+		slog.Warn("primary interface lookup failed", slog.Any("error", err))
+	}
+
+	// the rest of the device info is still useful without the interfaces
+	if info.Interfaces, err = localsystem.Interfaces(c.Request.Context()); err != nil {
+		slog.Warn("network interface lookup failed", slog.Any("error", err))
+		info.Interfaces = []localsystem.Interface{}
+	}
+
 	info.InternetConnectivity = localsystem.IsConnectedToInternet()
 
 	if info.DHCPInfo.Enabled, err = localsystem.UsingDHCP(); err != nil {
@@ -131,15 +147,154 @@ func GetDHCPState(c *gin.Context) {
 	c.JSON(http.StatusOK, ret)
 }
 
-// GetScreenshot a screenshot of the device's screen
+// GetScreenshot a screenshot of the device's screen.
+// The format (png or jpeg), quality, max-width, max-height and output query parameters pick the output
+// and how the image is encoded.
 func GetScreenshot(c *gin.Context) {
-	imgBytes, err := screenshot.Take(c.Request.Context())
-	if err != nil {
+	opts := screenshot.Options{
+		Output: c.Query("output"),
+		Format: c.Query("format"),
+	}
+	for param, v := range map[string]*int{"quality": &opts.Quality, "max-width": &opts.MaxWidth, "max-height": &opts.MaxHeight} {
+		if s := c.Query(param); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				c.String(http.StatusBadRequest, fmt.Sprintf("invalid %s %q", param, s))
+				return
+			}
+			*v = n
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	img, err := screenshot.Take(c.Request.Context(), opts)
+	switch {
+	case errors.Is(err, screenshot.ErrUnknownOutput):
+		c.String(http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, screenshot.ErrNoBackend):
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
 		slog.Error("screenshot failed", slog.Any("error", err))
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
-	c.Data(http.StatusOK, "image/jpeg", imgBytes)
+
+	c.Header("X-Screenshot-Backend", img.Backend)
+	if img.Output != "" {
+		c.Header("X-Screenshot-Output", img.Output)
+	}
+	c.Data(http.StatusOK, img.ContentType, img.Data)
+}
+
+// GetScreenshotOutputs lists the display outputs that can be captured.
+func GetScreenshotOutputs(c *gin.Context) {
+	outputs, err := screenshot.Outputs(c.Request.Context())
+	switch {
+	case errors.Is(err, screenshot.ErrNoBackend):
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, outputs)
+}
+
+const streamBoundary = "frame"
+
+// GetScreenStream serves a live view of the screen as a multipart MJPEG stream. The optional viewer
+// query parameter names who is watching, and fps, max-width and max-height lower the frame rate and
+// size below the configured ones.
+func GetScreenStream(c *gin.Context) {
+	v := screenshot.Viewer{
+		Name:      c.Query("viewer"),
+		Address:   c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if s := c.Query("fps"); s != "" {
+		fps, err := strconv.ParseFloat(s, 64)
+		if err != nil || fps <= 0 {
+			c.String(http.StatusBadRequest, fmt.Sprintf("invalid fps %q", s))
+			return
+		}
+		v.FPS = fps
+	}
+	for param, n := range map[string]*int{"max-width": &v.MaxWidth, "max-height": &v.MaxHeight} {
+		if s := c.Query(param); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil || i <= 0 {
+				c.String(http.StatusBadRequest, fmt.Sprintf("invalid %s %q", param, s))
+				return
+			}
+			*n = i
+		}
+	}
+
+	frames, stop, err := screenshot.Watch(v)
+	switch {
+	case errors.Is(err, screenshot.ErrTooManyViewers):
+		c.String(http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, screenshot.ErrNoBackend):
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer stop()
+
+	c.Header("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+	c.Header("Cache-Control", "no-cache, no-store")
+	c.Header("Connection", "close")
+	c.Status(http.StatusOK)
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case frame := <-frames:
+			_, err := fmt.Fprintf(c.Writer, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", streamBoundary, len(frame))
+			if err == nil {
+				_, err = c.Writer.Write(frame)
+			}
+			if err == nil {
+				_, err = c.Writer.WriteString("\r\n")
+			}
+			if err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// GetScreenStreamViewers lists who is watching the screen stream.
+func GetScreenStreamViewers(c *gin.Context) {
+	c.JSON(http.StatusOK, screenshot.Viewers())
+}
+
+// GetScreenStatus returns the result of the last screen check.
+func GetScreenStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, screencheck.GetStatus())
+}
+
+// GetSuspiciousScreenshot returns the most recent screenshot that was found to be blank or frozen.
+func GetSuspiciousScreenshot(c *gin.Context) {
+	frame, ok := screencheck.SuspiciousFrame()
+	if !ok {
+		c.String(http.StatusNotFound, "no suspicious screenshot has been captured")
+		return
+	}
+
+	c.Header("X-Screen-Problem", frame.Problem)
+	c.Header("X-Screen-Checked-At", frame.CheckedAt.Format(time.RFC3339))
+	c.Data(http.StatusOK, frame.ContentType, frame.Data)
 }
 
 // HardwareInfo returns hardware info about this device
@@ -153,6 +308,27 @@ func HardwareInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, info)
 }
 
+// Metrics returns the rolling 1m/5m/15m averages and maxima from the metrics sampler
+func Metrics(c *gin.Context) {
+	sampler := localsystem.GetSampler()
+	if sampler == nil {
+		c.String(http.StatusServiceUnavailable, "metrics sampler is not running")
+		return
+	}
+	c.JSON(http.StatusOK, sampler.Summaries())
+}
+
+// Processes returns usage of the watched processes and the top processes by memory and CPU
+func Processes(c *gin.Context) {
+	snapshot, err := localsystem.ProcessesInfo()
+	if err != nil {
+		slog.Error("process info retrieval failed", slog.Any("error", err))
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
+
 // GetServiceHealth returns the health of services on this device
 func GetServiceHealth(c *gin.Context) {
 	var configs []health.ServiceCheckConfig
@@ -3,7 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -12,32 +14,67 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// GetDividerState returns the state of the dividers
+// ErrNoPins is returned by LoadPinsFromJSON when the config doesn't have any divider pins.
+var ErrNoPins = errors.New("no pin configuration found in couch config")
+
+// GetDividerState returns the state of the dividers, along with the room configuration they match
+// if a divider topology is configured.
 func GetDividerState(c *gin.Context) {
 	pins := gpio.GetPins()
-	resp := map[string][]string{"connected": {}, "disconnected": {}}
+	connected, disconnected := []string{}, []string{}
 	for _, p := range pins {
 		if p.Connected {
-			resp["connected"] = append(resp["connected"], p.BlueberryPresets)
+			connected = append(connected, p.BlueberryPresets)
 		} else {
-			resp["disconnected"] = append(resp["disconnected"], p.BlueberryPresets)
+			disconnected = append(disconnected, p.BlueberryPresets)
 		}
 	}
+
+	resp := gin.H{"connected": connected, "disconnected": disconnected}
+	if len(gpio.GetTopology().Configurations) > 0 {
+		status := gpio.CurrentStatus()
+		resp["configuration"] = status.Configuration
+		resp["sensors"] = status.Sensors
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
 // PresetForHostname returns the preset that a specific hostname should be on
 func PresetForHostname(c *gin.Context) {
-	hostname := c.Param("hostname")
+	preset, err := gpio.PresetFor(c.Param("hostname"))
+	switch {
+	case errors.Is(err, gpio.ErrNotSupported):
+		c.String(http.StatusBadRequest, err.Error())
+	case errors.Is(err, gpio.ErrUnknownHostname):
+		c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, gpio.ErrNoConfiguration):
+		c.String(http.StatusConflict, err.Error())
+	case err != nil:
+		c.String(http.StatusInternalServerError, err.Error())
+	default:
+		c.String(http.StatusOK, preset)
+	}
+}
 
-	pins := gpio.GetPins()
+// GetDividerTopology returns the divider topology and the current combined state.
+func GetDividerTopology(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"topology": gpio.GetTopology(),
+		"status":   gpio.CurrentStatus(),
+	})
+}
 
-	if len(pins) != 1 {
-		c.String(http.StatusBadRequest, "not supported in this room")
-		return
+// LoadDividerTopology sets the divider topology from the "divider-topology" section of a couch config.
+// A config without one clears the topology.
+func LoadDividerTopology(cfg map[string]any) error {
+	var topology gpio.Topology
+	if _, err := couchdb.DecodeMonitoringSection(cfg, "divider-topology", &topology); err != nil {
+		return err
 	}
-	preset := pins[0].CurrentPreset(hostname)
-	c.String(http.StatusOK, preset)
+	if err := gpio.SetTopology(topology); err != nil {
+		return fmt.Errorf("invalid divider topology: %w", err)
+	}
+	return nil
 }
 
 // GetDividerPins returns the configured GPIO pin definitions for divider sensors.
@@ -99,8 +136,84 @@ func LoadPinsFromJSON(cfg map[string]any, err error) ([]gpio.Pin, error) {
 	}
 
 	if len(pins) == 0 {
-		return nil, fmt.Errorf("no pin configuration found in couch config")
+		return nil, ErrNoPins
 	}
 
 	return pins, nil
 }
+
+// GetDividerMonitors returns the status of each divider sensor monitor.
+func GetDividerMonitors(c *gin.Context) {
+	c.JSON(http.StatusOK, gpio.Statuses())
+}
+
+// GetDividerTemplates returns the change and true-up templates checked by the last reload, with any errors.
+func GetDividerTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gpio.Templates())
+}
+
+// GetDividerHistory returns the most recent divider transitions, newest first.
+func GetDividerHistory(c *gin.Context) {
+	c.JSON(http.StatusOK, gpio.History())
+}
+
+// GetDividerRequests returns the outcome of the most recent divider change and true-up requests.
+func GetDividerRequests(c *gin.Context) {
+	c.JSON(http.StatusOK, gpio.RecentRequests())
+}
+
+// ReloadDividerMonitors reloads the divider pins from couch, restarting any monitors whose config changed.
+func ReloadDividerMonitors(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := reloadDividerPins(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gpio.Statuses())
+}
+
+// WatchDividerConfig reloads the divider pins from couch every interval until ctx is done.
+func WatchDividerConfig(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			if err := reloadDividerPins(reqCtx); err != nil {
+				slog.Warn("Failed to reload divider pins", slog.Any("error", err))
+			}
+			cancel()
+		}
+	}
+}
+
+func reloadDividerPins(ctx context.Context) error {
+	cfg, err := couchdb.GetMonitoringConfig(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get couch config: %w", err)
+	}
+
+	// a config without pins stops any monitors that are running
+	pins, err := LoadPinsFromJSON(cfg, nil)
+	if err != nil && !errors.Is(err, ErrNoPins) {
+		return err
+	}
+
+	return ReloadDividers(cfg, pins)
+}
+
+// ReloadDividers restarts the monitors for pins and sets the divider topology from cfg, the couch
+// config the pins came from. Every reload goes through here so the topology always matches the pins.
+func ReloadDividers(cfg map[string]any, pins []gpio.Pin) error {
+	if err := gpio.Reload(pins); err != nil {
+		return err
+	}
+	return LoadDividerTopology(cfg)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byuoitav/device-monitoring/actions/gpio"
+	"github.com/gin-gonic/gin"
+)
+
+// SetSimulatedLine sets the value of a line on the gpio simulator.
+func SetSimulatedLine(c *gin.Context) {
+	sim, ok := gpio.GetBackend().(*gpio.Simulator)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "the gpio simulator is not enabled"})
+		return
+	}
+
+	pin, err := strconv.Atoi(c.Param("pin"))
+	if err != nil || pin < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pin " + c.Param("pin")})
+		return
+	}
+
+	var value int
+	switch strings.ToLower(c.Param("value")) {
+	case "1", "high", "on", "true":
+		value = gpio.HIGH
+	case "0", "low", "off", "false":
+		value = gpio.LOW
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value " + c.Param("value") + ", must be 1/0, high/low, on/off or true/false"})
+		return
+	}
+
+	if err := sim.Set(pin, value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pin": pin, "value": sim.Get(pin)})
+}
+
+// GetChips lists the gpio chips and lines available on the current backend.
+func GetChips(c *gin.Context) {
+	chips, err := gpio.Chips()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"backend":      gpio.GetBackend().Name(),
+		"default-chip": gpio.DefaultChip(),
+		"chips":        chips,
+	})
+}
+
+// GetOutputs returns the state of every gpio output.
+func GetOutputs(c *gin.Context) {
+	c.JSON(http.StatusOK, gpio.Outputs())
+}
+
+// SetOutput turns a gpio output on or off, or pulses it.
+// The body is {"command": "on" | "off" | "pulse", "duration": "2s"}; duration only applies to pulses.
+func SetOutput(c *gin.Context) {
+	var body struct {
+		Command  string `json:"command"`
+		Duration string `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+		return
+	}
+
+	var duration time.Duration
+	if body.Duration != "" {
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duration " + body.Duration})
+			return
+		}
+		duration = d
+	}
+
+	state, err := gpio.SetOutput(c.Param("name"), strings.ToLower(body.Command), duration)
+	switch {
+	case errors.Is(err, gpio.ErrUnknownOutput):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, gpio.ErrInvalidCommand):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "state": state})
+	default:
+		c.JSON(http.StatusOK, state)
+	}
+}
@@ -98,3 +98,39 @@ func DeviceHardwareInfo(c *gin.Context) {
 
 	c.JSON(http.StatusOK, info)
 }
+
+// DeviceHardwareInfoHistory returns the last known hardware info and change log of each device in the room.
+// Pass ?device=<id> to get a single device.
+func DeviceHardwareInfoHistory(c *gin.Context) {
+	history, err := hardwareinfo.History()
+	if err != nil {
+		slog.Error("failed to get device hardware info history", slog.Any("error", err))
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if id := c.Query("device"); id != "" {
+		h, ok := history[id]
+		if !ok {
+			c.String(http.StatusNotFound, fmt.Sprintf("no hardware info history for %s", id))
+			return
+		}
+
+		c.JSON(http.StatusOK, h)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// MaintenanceForecasts returns lamp/filter hours, usage rates and forecasted replacement dates for devices in the room.
+func MaintenanceForecasts(c *gin.Context) {
+	forecasts, err := hardwareinfo.Forecasts()
+	if err != nil {
+		slog.Error("failed to get maintenance forecasts", slog.Any("error", err))
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, forecasts)
+}
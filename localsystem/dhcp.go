@@ -0,0 +1,163 @@
+package localsystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConnectivityTimeout is how long SetDHCP waits for the network to come back after a change.
+	DefaultConnectivityTimeout = 45 * time.Second
+	// MaxConnectivityTimeout is the longest SetDHCP waits, so a bad change can't leave the device
+	// unreachable for long.
+	MaxConnectivityTimeout = 5 * time.Minute
+
+	connectivityRetryDelay = 2 * time.Second
+)
+
+var (
+	// ErrInvalidStaticConfig is returned when the saved static config can't be applied.
+	ErrInvalidStaticConfig = errors.New("invalid static config")
+	// ErrRolledBack is returned when the connectivity check failed after a DHCP change, and the
+	// previous configuration was restored.
+	ErrRolledBack = errors.New("connectivity check failed, rolled back to the previous configuration")
+	// ErrDHCPChangeInProgress is returned when another DHCP change hasn't finished yet.
+	ErrDHCPChangeInProgress = errors.New("a DHCP change is already in progress")
+
+	dhcpMu sync.Mutex
+)
+
+// Validate checks that the config has at least one address, a gateway on the subnet of one of
+// them, and that the DNS servers are IP addresses.
+func (c StaticConfig) Validate() error {
+	addrs := splitList(c.Addresses)
+	if len(addrs) == 0 {
+		return fmt.Errorf("%w: no addresses", ErrInvalidStaticConfig)
+	}
+
+	var nets []*net.IPNet
+	for _, a := range addrs {
+		_, ipnet, err := net.ParseCIDR(a)
+		if err != nil {
+			return fmt.Errorf("%w: address %q must be in CIDR notation", ErrInvalidStaticConfig, a)
+		}
+		nets = append(nets, ipnet)
+	}
+
+	gw := net.ParseIP(strings.TrimSpace(c.Gateway))
+	if gw == nil {
+		return fmt.Errorf("%w: invalid gateway %q", ErrInvalidStaticConfig, c.Gateway)
+	}
+	onSubnet := false
+	for _, n := range nets {
+		onSubnet = onSubnet || n.Contains(gw)
+	}
+	if !onSubnet {
+		return fmt.Errorf("%w: gateway %s isn't on the subnet of %s", ErrInvalidStaticConfig, gw, c.Addresses)
+	}
+
+	for _, d := range splitList(c.DNS) {
+		if net.ParseIP(d) == nil {
+			return fmt.Errorf("%w: invalid DNS server %q", ErrInvalidStaticConfig, d)
+		}
+	}
+	return nil
+}
+
+// SetDHCP turns DHCP on or off for the active connection. Turning it on saves the current static
+// config, and turning it off restores the saved one. After the change, verify is retried until it
+// succeeds or timeout (at most MaxConnectivityTimeout) passes; if it never does, the previous configuration is put back and
+// ErrRolledBack is returned. A nil verify skips the check.
+func SetDHCP(ctx context.Context, enabled bool, timeout time.Duration, verify func(context.Context) error) error {
+	if !dhcpMu.TryLock() {
+		return ErrDHCPChangeInProgress
+	}
+	defer dhcpMu.Unlock()
+
+	m, connName, err := activeConnection(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.Modifiable(ctx, connName); err != nil {
+		return err
+	}
+
+	prev, err := m.IPv4(ctx, connName)
+	if err != nil {
+		return err
+	}
+	if prev.DHCP == enabled {
+		return nil
+	}
+
+	next := IPv4Settings{DHCP: true}
+	if enabled {
+		if err := prev.Validate(); err != nil {
+			// keep whatever was saved before rather than replacing it with something that can't be restored
+			slog.Warn("Not saving the current static config", slog.Any("error", err))
+		} else if err := saveStaticConfig(prev.StaticConfig); err != nil {
+			return err
+		}
+	} else {
+		cfg, err := loadStaticConfig()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidStaticConfig, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		next = IPv4Settings{StaticConfig: cfg}
+	}
+
+	slog.Info("Changing DHCP state", slog.String("manager", m.Name()), slog.String("connection", connName), slog.Bool("enabled", enabled))
+	err = m.SetIPv4(ctx, connName, next)
+	if err == nil && verify != nil {
+		err = waitForConnectivity(ctx, timeout, verify)
+	}
+	if err == nil {
+		return nil
+	}
+
+	slog.Warn("DHCP change failed, rolling back", slog.String("connection", connName), slog.Any("error", err))
+	if rerr := m.SetIPv4(ctx, connName, prev); rerr != nil {
+		return fmt.Errorf("%w: %s, and the rollback failed: %s", ErrRolledBack, err, rerr)
+	}
+	return fmt.Errorf("%w: %s", ErrRolledBack, err)
+}
+
+// waitForConnectivity retries verify until it succeeds or timeout passes.
+func waitForConnectivity(ctx context.Context, timeout time.Duration, verify func(context.Context) error) error {
+	if timeout <= 0 {
+		timeout = DefaultConnectivityTimeout
+	}
+	timeout = min(timeout, MaxConnectivityTimeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := verify(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Debug("Connectivity check failed", slog.Any("error", err))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no connectivity after %s: %w", timeout, err)
+		case <-time.After(connectivityRetryDelay):
+		}
+	}
+}
+
+// splitList splits a comma or space separated list.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
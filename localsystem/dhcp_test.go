@@ -0,0 +1,143 @@
+package localsystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeNetworkManager keeps the ipv4 settings of one connection in memory. setErrs are returned by
+// the calls to SetIPv4, in order.
+type fakeNetworkManager struct {
+	settings IPv4Settings
+	setErrs  []error
+	set      []IPv4Settings
+	dns      map[string][]string
+}
+
+func (*fakeNetworkManager) Name() string                               { return "fake" }
+func (*fakeNetworkManager) Available(context.Context) error            { return nil }
+func (*fakeNetworkManager) Connection(context.Context) (string, error) { return "eth0", nil }
+func (*fakeNetworkManager) Modifiable(context.Context, string) error   { return nil }
+
+func (m *fakeNetworkManager) DNS(_ context.Context, iface string) ([]string, error) {
+	dns, ok := m.dns[iface]
+	if !ok {
+		return nil, fmt.Errorf("unknown interface %s", iface)
+	}
+	return dns, nil
+}
+
+func (m *fakeNetworkManager) IPv4(context.Context, string) (IPv4Settings, error) {
+	return m.settings, nil
+}
+
+func (m *fakeNetworkManager) SetIPv4(_ context.Context, _ string, s IPv4Settings) error {
+	m.set = append(m.set, s)
+
+	var err error
+	if len(m.setErrs) > 0 {
+		err, m.setErrs = m.setErrs[0], m.setErrs[1:]
+	}
+	if err == nil {
+		m.settings = s
+	}
+	return err
+}
+
+// useNetworkManager makes m the network manager for a test.
+func useNetworkManager(t *testing.T, m NetworkManager) {
+	t.Helper()
+
+	networkMu.Lock()
+	savedConfigured, savedManager := networkConfigured, networkManager
+	networkConfigured, networkManager = "fake", m
+	networkMu.Unlock()
+
+	t.Cleanup(func() {
+		networkMu.Lock()
+		networkConfigured, networkManager = savedConfigured, savedManager
+		networkMu.Unlock()
+	})
+}
+
+func TestSetDHCPRollback(t *testing.T) {
+	// the static config is empty, so turning DHCP on doesn't save it over the one on the device
+	prev := IPv4Settings{DHCP: false}
+	failedVerify := func(context.Context) error { return errors.New("gateway unreachable") }
+	goodVerify := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name     string
+		setErrs  []error
+		verify   func(context.Context) error
+		want     IPv4Settings
+		wantErr  error
+		wantSets int
+	}{
+		{
+			name:     "verified",
+			verify:   goodVerify,
+			want:     IPv4Settings{DHCP: true},
+			wantSets: 1,
+		},
+		{
+			name:     "failed verify restores the previous settings",
+			verify:   failedVerify,
+			want:     prev,
+			wantErr:  ErrRolledBack,
+			wantSets: 2,
+		},
+		{
+			name:     "failed change restores the previous settings",
+			setErrs:  []error{errors.New("nmcli failed")},
+			verify:   goodVerify,
+			want:     prev,
+			wantErr:  ErrRolledBack,
+			wantSets: 2,
+		},
+		{
+			name:     "failed rollback",
+			setErrs:  []error{nil, errors.New("nmcli failed")},
+			verify:   failedVerify,
+			want:     IPv4Settings{DHCP: true},
+			wantErr:  ErrRolledBack,
+			wantSets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeNetworkManager{settings: prev, setErrs: tt.setErrs}
+			useNetworkManager(t, m)
+
+			err := SetDHCP(context.Background(), true, 10*time.Millisecond, tt.verify)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if len(m.set) != tt.wantSets {
+				t.Errorf("expected %d changes, got %d: %+v", tt.wantSets, len(m.set), m.set)
+			}
+			if len(m.set) > 1 && m.set[1] != prev {
+				t.Errorf("expected the rollback to restore %+v, got %+v", prev, m.set[1])
+			}
+			if m.settings != tt.want {
+				t.Errorf("expected the connection to end up with %+v, got %+v", tt.want, m.settings)
+			}
+		})
+	}
+}
+
+func TestSetDHCPUnchanged(t *testing.T) {
+	m := &fakeNetworkManager{settings: IPv4Settings{DHCP: true}}
+	useNetworkManager(t, m)
+
+	if err := SetDHCP(context.Background(), true, time.Second, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(m.set) != 0 {
+		t.Errorf("expected no changes when DHCP is already on, got %+v", m.set)
+	}
+}
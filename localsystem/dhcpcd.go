@@ -0,0 +1,264 @@
+package localsystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const dhcpcdConfPath = "/etc/dhcpcd.conf"
+
+// dhcpcdManager configures dhcpcd through the static options of an interface in dhcpcd.conf.
+type dhcpcdManager struct {
+	run  Runner
+	path string
+}
+
+func (*dhcpcdManager) Name() string {
+	return NetworkManagerDHCP
+}
+
+func (m *dhcpcdManager) Available(ctx context.Context) error {
+	if _, err := os.Stat(m.path); err != nil {
+		return err
+	}
+	return serviceActive(ctx, m.run, "dhcpcd")
+}
+
+// Connection returns the primary interface.
+func (m *dhcpcdManager) Connection(context.Context) (string, error) {
+	return PrimaryInterface()
+}
+
+func (m *dhcpcdManager) Modifiable(_ context.Context, _ string) error {
+	if err := unix.Access(m.path, unix.W_OK); err != nil {
+		return fmt.Errorf("%s is not writable: %w", m.path, err)
+	}
+	return nil
+}
+
+func (m *dhcpcdManager) IPv4(_ context.Context, iface string) (IPv4Settings, error) {
+	conf, err := os.ReadFile(m.path)
+	if err != nil {
+		return IPv4Settings{}, fmt.Errorf("failed to read dhcpcd config: %w", err)
+	}
+	return parseDhcpcdConf(string(conf), iface), nil
+}
+
+func (m *dhcpcdManager) SetIPv4(ctx context.Context, iface string, s IPv4Settings) error {
+	conf, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read dhcpcd config: %w", err)
+	}
+	if err := writeFileAtomic(m.path, []byte(renderDhcpcdConf(string(conf), iface, s))); err != nil {
+		return fmt.Errorf("failed to write dhcpcd config: %w", err)
+	}
+
+	if _, err := m.run.Run(ctx, "systemctl", "restart", "dhcpcd"); err != nil {
+		return fmt.Errorf("failed to restart dhcpcd: %w", err)
+	}
+	return nil
+}
+
+// DNS returns the DNS servers from iface's lease, or its static config if it doesn't have one.
+func (m *dhcpcdManager) DNS(ctx context.Context, iface string) ([]string, error) {
+	if out, err := m.run.Run(ctx, "dhcpcd", "-U", iface); err == nil {
+		if dns := parseDhcpcdLease(string(out)); len(dns) > 0 {
+			return dns, nil
+		}
+	}
+
+	conf, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dhcpcd config: %w", err)
+	}
+	return splitList(parseDhcpcdConf(string(conf), iface).DNS), nil
+}
+
+// parseDhcpcdLease returns the DNS servers from the lease dumped by dhcpcd -U, which looks like:
+//
+//	ip_address='10.5.0.20'
+//	domain_name_servers='10.8.0.26 10.8.0.19'
+//	dhcp6_name_servers='2001:db8::53'
+func parseDhcpcdLease(s string) []string {
+	var dns []string
+	for _, line := range strings.Split(s, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimPrefix(key, "new_") {
+		case "domain_name_servers", "dhcp6_name_servers":
+			dns = append(dns, strings.Fields(strings.Trim(value, `'"`))...)
+		}
+	}
+	return dns
+}
+
+// dhcpcdBlock returns the range of lines [start, end) of iface's block in conf, where start is
+// its "interface" line. start is -1 if there isn't one.
+func dhcpcdBlock(lines []string, iface string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "interface", "ssid", "profile":
+			if start != -1 {
+				return start, i
+			}
+			if fields[0] == "interface" && len(fields) > 1 && fields[1] == iface {
+				start = i
+			}
+		}
+	}
+	return start, len(lines)
+}
+
+// parseDhcpcdConf returns the ipv4 settings of iface from dhcpcd.conf, which sets a static
+// address like:
+//
+//	interface eth0
+//	static ip_address=10.5.0.20/23
+//	static routers=10.5.1.1
+//	static domain_name_servers=10.8.0.26 10.8.0.19
+//
+// It uses DHCP unless it has a static ip_address.
+func parseDhcpcdConf(conf, iface string) IPv4Settings {
+	lines := strings.Split(conf, "\n")
+	start, end := dhcpcdBlock(lines, iface)
+	if start == -1 {
+		return IPv4Settings{DHCP: true}
+	}
+
+	var addrs, dns []string
+	var gateway string
+	for _, line := range lines[start+1 : end] {
+		key, value, ok := dhcpcdStatic(line)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "ip_address":
+			for _, a := range strings.Fields(value) {
+				if !strings.Contains(a, ":") {
+					addrs = append(addrs, a)
+				}
+			}
+		case "routers":
+			if fields := strings.Fields(value); gateway == "" && len(fields) > 0 {
+				gateway = fields[0]
+			}
+		case "domain_name_servers":
+			dns = append(dns, strings.Fields(value)...)
+		}
+	}
+
+	return IPv4Settings{
+		DHCP: len(addrs) == 0,
+		StaticConfig: StaticConfig{
+			Addresses: strings.Join(addrs, ","),
+			Gateway:   gateway,
+			DNS:       strings.Join(dns, ","),
+		},
+	}
+}
+
+// renderDhcpcdConf replaces the static ipv4 options of iface in conf with s. The rest of the file,
+// including any ipv6 options, is left alone, and an interface block left empty is removed.
+func renderDhcpcdConf(conf, iface string, s IPv4Settings) string {
+	lines := strings.Split(strings.TrimRight(conf, "\n"), "\n")
+	start, end := dhcpcdBlock(lines, iface)
+
+	var static []string
+	if !s.DHCP {
+		static = append(static, "static ip_address="+strings.Join(splitList(s.Addresses), " "))
+		if s.Gateway != "" {
+			static = append(static, "static routers="+s.Gateway)
+		}
+		if dns := splitList(s.DNS); len(dns) > 0 {
+			static = append(static, "static domain_name_servers="+strings.Join(dns, " "))
+		}
+	}
+
+	if start == -1 {
+		if s.DHCP {
+			return conf
+		}
+		lines = append(lines, "", "interface "+iface)
+		return strings.Join(append(lines, static...), "\n") + "\n"
+	}
+
+	var kept []string
+	empty := true
+	for _, line := range lines[start+1 : end] {
+		if key, value, ok := dhcpcdStatic(line); ok && (key == "routers" || key == "domain_name_servers" || key == "ip_address" && !strings.Contains(value, ":")) {
+			continue
+		}
+		kept = append(kept, line)
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			empty = false
+		}
+	}
+
+	var out []string
+	out = append(out, lines[:start]...)
+	if !empty || len(static) > 0 {
+		out = append(out, lines[start])
+		out = append(out, static...)
+		out = append(out, kept...)
+	} else {
+		// drop the blank line that separated the block from the previous one
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+			out = out[:len(out)-1]
+		}
+		out = append(out, kept...)
+	}
+	out = append(out, lines[end:]...)
+	return strings.TrimRight(strings.Join(out, "\n"), "\n") + "\n"
+}
+
+// dhcpcdStatic parses a "static key=value" line.
+func dhcpcdStatic(line string) (string, string, bool) {
+	opt, ok := strings.CutPrefix(strings.TrimSpace(line), "static ")
+	if !ok {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(strings.TrimSpace(opt), "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// writeFileAtomic replaces path with data, keeping its permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
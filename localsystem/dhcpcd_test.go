@@ -0,0 +1,82 @@
+package localsystem
+
+import (
+	"strings"
+	"testing"
+)
+
+const dhcpcdConf = `# A sample configuration for dhcpcd.
+hostname
+clientid
+persistent
+option rapid_commit
+
+interface eth0
+static ip_address=10.5.0.20/23
+static ip6_address=2001:db8::20/64
+static routers=10.5.1.1
+static domain_name_servers=10.8.0.26 10.8.0.19
+
+interface wlan0
+metric 300
+`
+
+func TestParseDhcpcdConf(t *testing.T) {
+	got := parseDhcpcdConf(dhcpcdConf, "eth0")
+	want := IPv4Settings{StaticConfig: StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	for _, iface := range []string{"wlan0", "eth1"} {
+		if s := parseDhcpcdConf(dhcpcdConf, iface); !s.DHCP {
+			t.Errorf("expected %s to use DHCP, got %+v", iface, s)
+		}
+	}
+}
+
+func TestRenderDhcpcdConf(t *testing.T) {
+	dhcp := renderDhcpcdConf(dhcpcdConf, "eth0", IPv4Settings{DHCP: true})
+	want := `# A sample configuration for dhcpcd.
+hostname
+clientid
+persistent
+option rapid_commit
+
+interface eth0
+static ip6_address=2001:db8::20/64
+
+interface wlan0
+metric 300
+`
+	if dhcp != want {
+		t.Errorf("expected the ipv4 options to be removed, got:\n%s", dhcp)
+	}
+
+	// putting the static config back restores the original settings
+	static := IPv4Settings{StaticConfig: StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}}
+	if s := parseDhcpcdConf(renderDhcpcdConf(dhcp, "eth0", static), "eth0"); s != static {
+		t.Errorf("expected %+v, got %+v", static, s)
+	}
+
+	// an interface without a block gets one, and loses it again when it goes back to DHCP
+	wlan := renderDhcpcdConf(dhcpcdConf, "eth1", static)
+	if s := parseDhcpcdConf(wlan, "eth1"); s != static {
+		t.Errorf("expected %+v, got %+v", static, s)
+	}
+	if got := renderDhcpcdConf(wlan, "eth1", IPv4Settings{DHCP: true}); got != dhcpcdConf {
+		t.Errorf("expected the empty block to be removed, got:\n%s", got)
+	}
+}
+
+func TestParseDhcpcdLease(t *testing.T) {
+	dns := parseDhcpcdLease(`ip_address='10.5.0.20'
+subnet_cidr='23'
+routers='10.5.1.1'
+domain_name_servers='10.8.0.26 10.8.0.19'
+dhcp6_name_servers='2001:db8::53'
+`)
+	if strings.Join(dns, ",") != "10.8.0.26,10.8.0.19,2001:db8::53" {
+		t.Errorf("unexpected DNS servers %q", dns)
+	}
+}
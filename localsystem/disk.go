@@ -0,0 +1,238 @@
+package localsystem
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+const blockDeviceRootPath = "/sys/block"
+
+// DiskConfig controls which mounts and block devices DiskInfo reports on.
+// An empty list means the mounts/devices are discovered automatically.
+type DiskConfig struct {
+	Mounts  []string `json:"mounts,omitempty"`
+	Devices []string `json:"devices,omitempty"`
+}
+
+// MountInfo is the usage of a single mounted filesystem. Error is set, and the usage left empty,
+// if it couldn't be read.
+type MountInfo struct {
+	Mountpoint        string  `json:"mountpoint"`
+	Device            string  `json:"device"`
+	Fstype            string  `json:"fstype"`
+	ReadOnly          bool    `json:"read-only"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	UsedPercent       float64 `json:"used-percent"`
+	InodesTotal       uint64  `json:"inodes-total"`
+	InodesUsed        uint64  `json:"inodes-used"`
+	InodesFree        uint64  `json:"inodes-free"`
+	InodesUsedPercent float64 `json:"inodes-used-percent"`
+	Error             string  `json:"error,omitempty"`
+}
+
+// DiskIOInfo is the IO activity of a single block device. Rates are measured by the metrics
+// sampler over its last interval, and are zero until it has taken two samples.
+type DiskIOInfo struct {
+	Device           string  `json:"device"`
+	ReadCount        uint64  `json:"read-count"`
+	WriteCount       uint64  `json:"write-count"`
+	ReadBytes        uint64  `json:"read-bytes"`
+	WriteBytes       uint64  `json:"write-bytes"`
+	ReadsPerSecond   float64 `json:"reads-per-second"`
+	WritesPerSecond  float64 `json:"writes-per-second"`
+	ReadBytesPerSec  float64 `json:"read-bytes-per-second"`
+	WriteBytesPerSec float64 `json:"write-bytes-per-second"`
+	IOsInProgress    uint64  `json:"ios-in-progress"`
+	MillisecondsBusy uint64  `json:"ms-busy"`
+}
+
+var (
+	diskConfigMu sync.RWMutex
+	diskConfig   DiskConfig
+)
+
+// SetDiskConfig sets which mounts and block devices DiskInfo reports on.
+func SetDiskConfig(cfg DiskConfig) {
+	diskConfigMu.Lock()
+	defer diskConfigMu.Unlock()
+	diskConfig = cfg
+}
+
+// GetDiskConfig returns the current disk config.
+func GetDiskConfig() DiskConfig {
+	diskConfigMu.RLock()
+	defer diskConfigMu.RUnlock()
+	return diskConfig
+}
+
+// DiskInfo returns per-mount usage and per-device IO rates.
+// "usage" and "io-counters" are kept for the root filesystem and raw counters; "usage" is left out
+// if the root filesystem's usage can't be read, which is reported on its mount instead.
+func DiskInfo() (map[string]interface{}, error) {
+	info := make(map[string]interface{})
+	cfg := GetDiskConfig()
+
+	mounts, err := Mounts(cfg)
+	if err != nil {
+		slog.Error("failed to get disk mounts", slog.Any("error", err))
+		return info, fmt.Errorf("failed to get disk mounts: %w", err)
+	}
+	info["mounts"] = mounts
+
+	if usage, err := disk.Usage("/"); err != nil {
+		slog.Warn("failed to get disk usage", slog.Any("error", err))
+	} else {
+		usage.UsedPercent = round(usage.UsedPercent, .01)
+		info["usage"] = usage
+	}
+
+	devices := cfg.Devices
+	if len(devices) == 0 {
+		devices = blockDevices()
+	}
+
+	ioCounters, err := disk.IOCounters(devices...)
+	if err != nil {
+		slog.Error("failed to get disk IO counters", slog.Any("error", err))
+		return info, fmt.Errorf("failed to get disk IO counters: %w", err)
+	}
+	info["io-counters"] = ioCounters
+	info["io"] = diskIORates(ioCounters)
+
+	return info, nil
+}
+
+// Mounts returns usage for the configured mounts, or every mounted block device if none are configured.
+func Mounts(cfg DiskConfig) ([]MountInfo, error) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	return mounts(partitions, cfg, disk.Usage), nil
+}
+
+// mounts picks the partitions to report on and reads their usage. Without configured mounts,
+// every mounted block device except loop devices is reported, once per mountpoint.
+func mounts(partitions []disk.PartitionStat, cfg DiskConfig, usage func(string) (*disk.UsageStat, error)) []MountInfo {
+	seen := make(map[string]bool)
+	var ret []MountInfo
+	for _, p := range partitions {
+		if seen[p.Mountpoint] {
+			continue
+		}
+
+		if len(cfg.Mounts) > 0 {
+			if !slices.Contains(cfg.Mounts, p.Mountpoint) {
+				continue
+			}
+		} else if !strings.HasPrefix(p.Device, "/dev/") || strings.HasPrefix(p.Device, "/dev/loop") {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		m := MountInfo{
+			Mountpoint: p.Mountpoint,
+			Device:     p.Device,
+			Fstype:     p.Fstype,
+			ReadOnly:   slices.Contains(strings.Split(p.Opts, ","), "ro"),
+		}
+
+		u, err := usage(p.Mountpoint)
+		if err != nil {
+			slog.Warn("failed to get mount usage", slog.String("mountpoint", p.Mountpoint), slog.Any("error", err))
+			m.Error = err.Error()
+			ret = append(ret, m)
+			continue
+		}
+
+		m.Total = u.Total
+		m.Used = u.Used
+		m.Free = u.Free
+		m.UsedPercent = round(u.UsedPercent, .01)
+		m.InodesTotal = u.InodesTotal
+		m.InodesUsed = u.InodesUsed
+		m.InodesFree = u.InodesFree
+		m.InodesUsedPercent = round(u.InodesUsedPercent, .01)
+		ret = append(ret, m)
+	}
+
+	for _, m := range cfg.Mounts {
+		if !seen[m] {
+			slog.Warn("configured mount not found", slog.String("mountpoint", m))
+		}
+	}
+
+	return ret
+}
+
+// MountKey turns a mountpoint into something usable in an event key, e.g. "/boot/firmware" -> "boot-firmware".
+func MountKey(mountpoint string) string {
+	key := strings.Trim(mountpoint, "/")
+	if key == "" {
+		return "root"
+	}
+	return strings.ReplaceAll(key, "/", "-")
+}
+
+// blockDevices lists the whole-disk block devices on the system (sda, mmcblk0, nvme0n1, ...).
+func blockDevices() []string {
+	return listBlockDevices(blockDeviceRootPath)
+}
+
+// listBlockDevices lists the block devices in root, skipping loop and ram disks.
+func listBlockDevices(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		slog.Warn("failed to list block devices", slog.Any("error", err))
+		return nil
+	}
+
+	var devices []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		devices = append(devices, name)
+	}
+	return devices
+}
+
+// diskIORates adds the rates measured by the sampler to the cumulative counters of each device.
+func diskIORates(counters map[string]disk.IOCountersStat) map[string]DiskIOInfo {
+	rates := make(map[string]DiskIOInfo, len(counters))
+	for name, cur := range counters {
+		rates[name] = DiskIOInfo{
+			Device:           name,
+			ReadCount:        cur.ReadCount,
+			WriteCount:       cur.WriteCount,
+			ReadBytes:        cur.ReadBytes,
+			WriteBytes:       cur.WriteBytes,
+			ReadsPerSecond:   sampledRate("disk-reads-per-second-" + name),
+			WritesPerSecond:  sampledRate("disk-writes-per-second-" + name),
+			ReadBytesPerSec:  sampledRate("disk-read-bytes-per-second-" + name),
+			WriteBytesPerSec: sampledRate("disk-write-bytes-per-second-" + name),
+			IOsInProgress:    cur.IopsInProgress,
+			MillisecondsBusy: cur.IoTime,
+		}
+	}
+	return rates
+}
+
+// counterRate returns the per-second rate between two readings of a counter,
+// treating a counter that went backwards (reset/wrap) as zero.
+func counterRate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
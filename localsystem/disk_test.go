@@ -0,0 +1,151 @@
+package localsystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+var partitions = []disk.PartitionStat{
+	{Device: "/dev/mmcblk0p2", Mountpoint: "/", Fstype: "ext4", Opts: "rw,noatime"},
+	{Device: "/dev/mmcblk0p1", Mountpoint: "/boot/firmware", Fstype: "vfat", Opts: "ro,relatime"},
+	{Device: "/dev/mmcblk0p2", Mountpoint: "/", Fstype: "ext4", Opts: "rw,noatime"},
+	{Device: "/dev/loop0", Mountpoint: "/snap/core/1", Fstype: "squashfs", Opts: "ro"},
+	{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs", Opts: "rw"},
+	{Device: "/dev/sda1", Mountpoint: "/mnt/usb", Fstype: "exfat", Opts: "rw"},
+}
+
+func fakeUsage(path string) (*disk.UsageStat, error) {
+	if path == "/mnt/usb" {
+		return nil, errors.New("input/output error")
+	}
+	return &disk.UsageStat{Path: path, Total: 1000, Used: 250, Free: 750, UsedPercent: 25.004, InodesTotal: 100, InodesUsed: 10, InodesFree: 90, InodesUsedPercent: 10}, nil
+}
+
+func TestMounts(t *testing.T) {
+	got := mounts(partitions, DiskConfig{}, fakeUsage)
+
+	var names []string
+	for _, m := range got {
+		names = append(names, m.Mountpoint)
+	}
+	if want := []string{"/", "/boot/firmware", "/mnt/usb"}; !slices.Equal(names, want) {
+		t.Fatalf("expected mounts %v, got %v", want, names)
+	}
+
+	root := got[0]
+	if root.ReadOnly || root.Device != "/dev/mmcblk0p2" || root.UsedPercent != 25 || root.InodesFree != 90 || root.Error != "" {
+		t.Errorf("unexpected root mount: %+v", root)
+	}
+	if !got[1].ReadOnly {
+		t.Errorf("expected /boot/firmware to be read-only")
+	}
+
+	// a mount whose usage can't be read is still reported
+	if usb := got[2]; usb.Error != "input/output error" || usb.Total != 0 || usb.Fstype != "exfat" {
+		t.Errorf("unexpected usb mount: %+v", usb)
+	}
+}
+
+func TestMountsConfigured(t *testing.T) {
+	got := mounts(partitions, DiskConfig{Mounts: []string{"/run", "/boot/firmware", "/data"}}, fakeUsage)
+
+	var names []string
+	for _, m := range got {
+		names = append(names, m.Mountpoint)
+	}
+	// a configured mount is reported even if it isn't a block device, and a missing one is skipped
+	if want := []string{"/boot/firmware", "/run"}; !slices.Equal(names, want) {
+		t.Errorf("expected mounts %v, got %v", want, names)
+	}
+}
+
+func TestMountKey(t *testing.T) {
+	tests := map[string]string{
+		"/":               "root",
+		"/boot/firmware":  "boot-firmware",
+		"/mnt/usb/":       "mnt-usb",
+		"/var/lib/docker": "var-lib-docker",
+	}
+	for mountpoint, want := range tests {
+		if got := MountKey(mountpoint); got != want {
+			t.Errorf("%q: got %q, want %q", mountpoint, got, want)
+		}
+	}
+}
+
+func TestListBlockDevices(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"mmcblk0", "sda", "nvme0n1", "loop0", "loop7", "ram0", "zram0"} {
+		if err := os.Mkdir(filepath.Join(root, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := listBlockDevices(root), []string{"mmcblk0", "nvme0n1", "sda"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := listBlockDevices(filepath.Join(root, "missing")); got != nil {
+		t.Errorf("expected no devices, got %v", got)
+	}
+}
+
+func TestDiskIORates(t *testing.T) {
+	s := &Sampler{interval: 10 * time.Second, size: 8, series: make(map[string]*series)}
+	now := time.Now()
+	for name, v := range map[string]float64{
+		"disk-reads-per-second-mmcblk0":       12.5,
+		"disk-writes-per-second-mmcblk0":      3,
+		"disk-read-bytes-per-second-mmcblk0":  4096,
+		"disk-write-bytes-per-second-mmcblk0": 1024,
+		// sampled too long ago to still be the current rate
+		"disk-reads-per-second-sda": 99,
+	} {
+		at := now
+		if name == "disk-reads-per-second-sda" {
+			at = now.Add(-time.Minute)
+		}
+		s.series[name] = newSeries(s.size)
+		s.series[name].add(point{at: at, value: v})
+	}
+
+	saved := sampler
+	sampler = s
+	t.Cleanup(func() { sampler = saved })
+
+	rates := diskIORates(map[string]disk.IOCountersStat{
+		"mmcblk0": {ReadCount: 100, WriteCount: 50, ReadBytes: 1 << 20, WriteBytes: 1 << 19, IopsInProgress: 1, IoTime: 300},
+		"sda":     {ReadCount: 7},
+	})
+
+	want := DiskIOInfo{
+		Device:           "mmcblk0",
+		ReadCount:        100,
+		WriteCount:       50,
+		ReadBytes:        1 << 20,
+		WriteBytes:       1 << 19,
+		ReadsPerSecond:   12.5,
+		WritesPerSecond:  3,
+		ReadBytesPerSec:  4096,
+		WriteBytesPerSec: 1024,
+		IOsInProgress:    1,
+		MillisecondsBusy: 300,
+	}
+	if got := rates["mmcblk0"]; got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := rates["sda"]; got.ReadCount != 7 || got.ReadsPerSecond != 0 {
+		t.Errorf("expected no rate for a stale sample, got %+v", got)
+	}
+
+	// without the sampler there are counters, but no rates
+	sampler = nil
+	if got := diskIORates(map[string]disk.IOCountersStat{"mmcblk0": {ReadCount: 100}})["mmcblk0"]; got.ReadCount != 100 || got.ReadsPerSecond != 0 {
+		t.Errorf("expected only counters without the sampler, got %+v", got)
+	}
+}
@@ -9,31 +9,20 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"log/slog"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/disk"
 	dockerstat "github.com/shirou/gopsutil/docker"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
-	"github.com/shirou/gopsutil/process"
 )
 
 const (
 	temperatureRootPath = "/sys/class/thermal"
-	uSleepCheckInterval = 3 * time.Second
-	uSleepResetInterval = 5 * time.Minute
-)
-
-var (
-	avgProcsInit     sync.Once
-	avgProcsInUSleep float64
 )
 
 // CPUInfo returns per-CPU and load-average stats.
@@ -47,24 +36,37 @@ func CPUInfo() (map[string]any, error) {
 	}
 	info["hardware"] = cpuState
 
-	percentages, err := cpu.Percent(0, true)
-	if err != nil {
-		slog.Error("failed to get per-CPU usage", slog.Any("error", err))
-		return info, fmt.Errorf("failed to get CPU usage: %w", err)
-	}
-	usage := make(map[string]float64, len(percentages))
-	for i, p := range percentages {
-		usage[fmt.Sprintf("cpu%d", i)] = round(p, .01)
-	}
-	info["usage"] = usage
+	// prefer the sampler so usage covers a fixed interval instead of "since the last request"
+	if sampler := GetSampler(); sampler != nil {
+		perCPU := sampler.PerCPU()
+		usage := make(map[string]float64, len(perCPU)+1)
+		for i, p := range perCPU {
+			usage[fmt.Sprintf("cpu%d", i)] = round(p, .01)
+		}
+		if avg, ok := sampler.Summary("cpu-usage-percent"); ok {
+			usage["avg"] = avg.Current
+		}
+		info["usage"] = usage
+	} else {
+		percentages, err := cpu.Percent(0, true)
+		if err != nil {
+			slog.Error("failed to get per-CPU usage", slog.Any("error", err))
+			return info, fmt.Errorf("failed to get CPU usage: %w", err)
+		}
+		usage := make(map[string]float64, len(percentages))
+		for i, p := range percentages {
+			usage[fmt.Sprintf("cpu%d", i)] = round(p, .01)
+		}
+		info["usage"] = usage
 
-	avgPercent, err := cpu.Percent(0, false)
-	if err != nil {
-		slog.Error("failed to get average CPU usage", slog.Any("error", err))
-		return info, fmt.Errorf("failed to get average CPU usage: %w", err)
-	}
-	if len(avgPercent) > 0 {
-		usage["avg"] = round(avgPercent[0], .01)
+		avgPercent, err := cpu.Percent(0, false)
+		if err != nil {
+			slog.Error("failed to get average CPU usage", slog.Any("error", err))
+			return info, fmt.Errorf("failed to get average CPU usage: %w", err)
+		}
+		if len(avgPercent) > 0 {
+			usage["avg"] = round(avgPercent[0], .01)
+		}
 	}
 
 	loadAvg, err := load.Avg()
@@ -74,6 +76,7 @@ func CPUInfo() (map[string]any, error) {
 	}
 	info["avg1min"] = loadAvg.Load1
 	info["avg5min"] = loadAvg.Load5
+	info["avg15min"] = loadAvg.Load15
 
 	return info, nil
 }
@@ -119,9 +122,15 @@ func HostInfo() (map[string]interface{}, error) {
 	}
 	info["users"] = users
 
+	info["temperature"] = Temperatures()
+
+	return info, nil
+}
+
+// Temperatures returns the current reading of each thermal sensor in degrees C, keyed by sensor type.
+func Temperatures() map[string]float64 {
 	temps := make(map[string]float64)
 	count := make(map[string]int)
-	info["temperature"] = temps
 
 	if err := filepath.Walk(temperatureRootPath, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
@@ -153,32 +162,10 @@ func HostInfo() (map[string]interface{}, error) {
 		slog.Warn("error walking temperature sensors", slog.Any("error", err))
 	}
 
-	return info, nil
-}
-
-// DiskInfo returns usage and IO counters for key devices.
-func DiskInfo() (map[string]interface{}, error) {
-	info := make(map[string]interface{})
-
-	usage, err := disk.Usage("/")
-	if err != nil {
-		slog.Error("failed to get disk usage", slog.Any("error", err))
-		return info, fmt.Errorf("failed to get disk usage: %w", err)
-	}
-	usage.UsedPercent = round(usage.UsedPercent, .01)
-	info["usage"] = usage
-
-	ioCounters, err := disk.IOCounters("sda", "mmcblk0")
-	if err != nil {
-		slog.Error("failed to get disk IO counters", slog.Any("error", err))
-		return info, fmt.Errorf("failed to get disk IO counters: %w", err)
-	}
-	info["io-counters"] = ioCounters
-
-	return info, nil
+	return temps
 }
 
-// NetworkInfo returns the list of network interfaces.
+// NetworkInfo returns the list of network interfaces and per-interface traffic stats.
 func NetworkInfo() (map[string]interface{}, error) {
 	info := make(map[string]interface{})
 
@@ -189,6 +176,13 @@ func NetworkInfo() (map[string]interface{}, error) {
 	}
 	info["interfaces"] = ifaces
 
+	stats, err := InterfaceStatistics()
+	if err != nil {
+		slog.Error("failed to get network interface stats", slog.Any("error", err))
+		return info, fmt.Errorf("failed to get network interface stats: %w", err)
+	}
+	info["stats"] = stats
+
 	return info, nil
 }
 
@@ -220,71 +214,30 @@ func DockerInfo() (map[string]interface{}, error) {
 	return info, nil
 }
 
-// ProcsInfo returns the names of processes in uninterruptible sleep,
-// plus a running average of how many are in that state.
+// ProcsInfo returns the names of processes in uninterruptible sleep, the 5 minute
+// average of how many are in that state, and usage of the watched and top processes,
+// all from the same process snapshot.
 func ProcsInfo() (map[string]interface{}, error) {
-	avgProcsInit.Do(startWatchingUSleep)
 	info := make(map[string]interface{})
 
-	procs, err := process.Processes()
+	snapshot, err := ProcessesInfo()
 	if err != nil {
-		slog.Error("failed to list processes", slog.Any("error", err))
-		return info, fmt.Errorf("failed to list processes: %w", err)
+		slog.Error("failed to get process usage", slog.Any("error", err))
+		return info, fmt.Errorf("failed to get process usage: %w", err)
 	}
+	info["cur-procs-u-sleep"] = snapshot.uSleep
+	info["watched"] = snapshot.Watched
+	info["top-memory"] = snapshot.TopMemory
+	info["top-cpu"] = snapshot.TopCPU
 
-	var bad []string
-	for _, p := range procs {
-		status, serr := p.Status()
-		if serr != nil {
-			continue
-		}
-		if status == "D" {
-			if name, nerr := p.Name(); nerr == nil {
-				bad = append(bad, name)
-			} else {
-				bad = append(bad, fmt.Sprintf("unknown(%v)", p.Pid))
-			}
+	if sampler := GetSampler(); sampler != nil {
+		if summary, ok := sampler.Summary("procs-u-sleep"); ok {
+			info["avg-procs-u-sleep"] = summary.FiveMinutes.Avg
 		}
 	}
-
-	info["cur-procs-u-sleep"] = bad
-	info["avg-procs-u-sleep"] = avgProcsInUSleep
 	return info, nil
 }
 
-// startWatchingUSleep continuously measures processes in uninterruptible sleep.
-func startWatchingUSleep() {
-	avgProcsInUSleep = 0
-	checkTicker := time.NewTicker(uSleepCheckInterval)
-	resetTicker := time.NewTicker(uSleepResetInterval)
-
-	go func() {
-		defer checkTicker.Stop()
-		defer resetTicker.Stop()
-
-		for {
-			select {
-			case <-checkTicker.C:
-				procs, err := process.Processes()
-				if err != nil {
-					slog.Warn("failed to list processes for u-sleep monitoring", slog.Any("error", err))
-					continue
-				}
-				count := 0
-				for _, p := range procs {
-					if status, serr := p.Status(); serr == nil && status == "D" {
-						count++
-					}
-				}
-				avgProcsInUSleep = round((avgProcsInUSleep+float64(count))/2, .05)
-
-			case <-resetTicker.C:
-				avgProcsInUSleep = 0
-			}
-		}
-	}()
-}
-
 // round to the nearest multiple of unit.
 func round(x, unit float64) float64 {
 	return math.Round(x/unit) * unit
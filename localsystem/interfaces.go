@@ -0,0 +1,206 @@
+package localsystem
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+)
+
+const resolvConfPath = "/etc/resolv.conf"
+
+// virtualInterfaces are prefixes of interface names that are never picked as the primary
+// interface when there's no default route to go by.
+var virtualInterfaces = []string{"docker", "br-", "veth", "virbr", "tun", "tap", "wg"}
+
+// Address is an address assigned to an interface.
+type Address struct {
+	Address string `json:"address"`
+	Prefix  int    `json:"prefix"`
+	Family  string `json:"family"`
+}
+
+// Interface describes a network interface and how it's configured.
+type Interface struct {
+	Name        string    `json:"name"`
+	MAC         string    `json:"mac,omitempty"`
+	Up          bool      `json:"up"`
+	Primary     bool      `json:"primary"`
+	MTU         int       `json:"mtu"`
+	Addresses   []Address `json:"addresses"`
+	IPv4Gateway string    `json:"ipv4-gateway,omitempty"`
+	IPv6Gateway string    `json:"ipv6-gateway,omitempty"`
+	DNS         []string  `json:"dns,omitempty"`
+}
+
+// PrimaryInterface returns the name of the interface the device is reached on: the configured
+// interface if there is one, otherwise the interface of the default route. Without a default
+// route, it's the first interface that's up and has an IPv4 address, skipping virtual ones.
+func PrimaryInterface() (string, error) {
+	networkMu.Lock()
+	configured := networkInterface
+	networkMu.Unlock()
+
+	if configured != "" {
+		if _, err := net.InterfaceByName(configured); err != nil {
+			return "", fmt.Errorf("configured interface %q: %w", configured, err)
+		}
+		return configured, nil
+	}
+
+	if r, err := defaultRoute(); err == nil {
+		return r.Interface, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || isVirtualInterface(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no default route or interface with an IPv4 address found")
+}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfaces {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interfaces returns every interface except the loopback, with its addresses, default gateways
+// and DNS servers.
+func Interfaces(ctx context.Context) ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	routes, err := readRoutes(procNetRoute, parseRoutes)
+	if err != nil {
+		return nil, err
+	}
+	routes6, err := readRoutes(procNetIPv6Route, parseIPv6Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := PrimaryInterface()
+	if err != nil {
+		slog.Warn("Unable to find the primary interface", slog.Any("error", err))
+	}
+
+	m, err := CurrentNetworkManager(ctx)
+	if err != nil {
+		m = nil
+	}
+
+	ret := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		info := Interface{
+			Name:      iface.Name,
+			MAC:       iface.HardwareAddr.String(),
+			Up:        iface.Flags&net.FlagUp != 0,
+			Primary:   iface.Name == primary,
+			MTU:       iface.MTU,
+			Addresses: []Address{},
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses of %s: %w", iface.Name, err)
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ones, _ := ipnet.Mask.Size()
+			family := "ipv6"
+			if ipnet.IP.To4() != nil {
+				family = "ipv4"
+			}
+			info.Addresses = append(info.Addresses, Address{Address: ipnet.IP.String(), Prefix: ones, Family: family})
+		}
+
+		if r, ok := defaultRouteOf(routes, iface.Name); ok {
+			info.IPv4Gateway = r.Gateway.String()
+		}
+		if r, ok := defaultRouteOf(routes6, iface.Name); ok {
+			info.IPv6Gateway = r.Gateway.String()
+		}
+
+		info.DNS = interfaceDNS(ctx, m, info, resolvConfNameservers)
+
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
+// interfaceDNS returns the DNS servers of iface. They're per interface when the network manager
+// can tell us; otherwise the ones in resolv.conf are put on the primary interface.
+func interfaceDNS(ctx context.Context, m NetworkManager, iface Interface, resolvConf func() ([]string, error)) []string {
+	if m != nil && iface.Up {
+		dns, err := m.DNS(ctx, iface.Name)
+		if err != nil {
+			slog.Debug("Unable to get DNS servers", slog.String("interface", iface.Name), slog.Any("error", err))
+		}
+		if len(dns) > 0 {
+			return dns
+		}
+	}
+	if !iface.Primary {
+		return nil
+	}
+
+	dns, err := resolvConf()
+	if err != nil {
+		slog.Warn("Unable to read DNS servers", slog.Any("error", err))
+	}
+	return dns
+}
+
+func resolvConfNameservers() ([]string, error) {
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseResolvConf(f)
+}
+
+// parseResolvConf returns the nameservers in resolv.conf.
+func parseResolvConf(r io.Reader) ([]string, error) {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
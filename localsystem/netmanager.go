@@ -0,0 +1,167 @@
+package localsystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Network managers that can be set in the network config.
+const (
+	NetworkManagerAuto = "auto"
+	NetworkManagerNM   = "networkmanager"
+	NetworkManagerDHCP = "dhcpcd"
+	NetworkManagerSD   = "networkd"
+)
+
+// ErrNoNetworkManager is returned when none of the supported network managers is running.
+var ErrNoNetworkManager = errors.New("no supported network manager is running")
+
+// Runner runs a command and returns what it wrote to stdout.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return out.Bytes(), fmt.Errorf("failed to run %s: %w — %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// IPv4Settings are the configured (rather than current) ipv4 settings of a connection.
+// The static config is only used when DHCP is off.
+type IPv4Settings struct {
+	DHCP bool `json:"dhcp"`
+	StaticConfig
+}
+
+// NetworkManager configures the network of the device.
+type NetworkManager interface {
+	// Name returns the name of the network manager, as used in the network config.
+	Name() string
+	// Available returns why the network manager can't be used here, or nil if it can.
+	Available(ctx context.Context) error
+	// Connection returns the connection (or interface) that DHCP is set on.
+	Connection(ctx context.Context) (string, error)
+	// Modifiable returns why conn can't be changed, or nil if it can.
+	Modifiable(ctx context.Context, conn string) error
+	// IPv4 returns the ipv4 settings of conn.
+	IPv4(ctx context.Context, conn string) (IPv4Settings, error)
+	// SetIPv4 changes the ipv4 settings of conn and applies them.
+	SetIPv4(ctx context.Context, conn string, s IPv4Settings) error
+	// DNS returns the DNS servers in use on the network interface iface.
+	DNS(ctx context.Context, iface string) ([]string, error)
+}
+
+// NetworkConfig selects the network manager. By default ("auto") the first one that's running is
+// used, trying NetworkManager, then dhcpcd, then systemd-networkd. Interface overrides the primary
+// interface, which is otherwise the one with the default route.
+type NetworkConfig struct {
+	Manager   string `json:"manager,omitempty"`
+	Interface string `json:"interface,omitempty"`
+}
+
+var (
+	networkManagers = []NetworkManager{
+		&nmcliManager{run: execRunner{}},
+		&dhcpcdManager{run: execRunner{}, path: dhcpcdConfPath},
+		&networkdManager{run: execRunner{}},
+	}
+
+	networkMu         sync.Mutex
+	networkConfigured string
+	networkManager    NetworkManager
+	networkInterface  string
+)
+
+// ConfigureNetwork sets the network manager, detecting it now if it's "auto".
+func ConfigureNetwork(ctx context.Context, cfg NetworkConfig) error {
+	name := strings.ToLower(cfg.Manager)
+	switch name {
+	case "":
+		name = NetworkManagerAuto
+	case "nmcli", "network-manager":
+		name = NetworkManagerNM
+	case "systemd-networkd":
+		name = NetworkManagerSD
+	}
+
+	networkMu.Lock()
+	defer networkMu.Unlock()
+
+	networkInterface = cfg.Interface
+	if name == NetworkManagerAuto {
+		networkConfigured, networkManager = name, detectNetworkManager(ctx)
+		return nil
+	}
+
+	for _, m := range networkManagers {
+		if m.Name() == name {
+			if err := m.Available(ctx); err != nil {
+				slog.Warn("Configured network manager isn't available", slog.String("manager", name), slog.Any("error", err))
+			}
+			networkConfigured, networkManager = name, m
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown network manager %q", cfg.Manager)
+}
+
+// CurrentNetworkManager returns the network manager used to configure the network. When
+// auto-detecting, it's detected again if none was found yet.
+func CurrentNetworkManager(ctx context.Context) (NetworkManager, error) {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+
+	if networkConfigured == "" {
+		networkConfigured = NetworkManagerAuto
+	}
+	if networkConfigured == NetworkManagerAuto && networkManager == nil {
+		networkManager = detectNetworkManager(ctx)
+	}
+
+	if networkManager == nil {
+		return nil, ErrNoNetworkManager
+	}
+	return networkManager, nil
+}
+
+// detectNetworkManager returns the first available network manager, or nil.
+func detectNetworkManager(ctx context.Context) NetworkManager {
+	for _, m := range networkManagers {
+		err := m.Available(ctx)
+		if err == nil {
+			slog.Info("Detected network manager", slog.String("manager", m.Name()))
+			return m
+		}
+		slog.Debug("Network manager unavailable", slog.String("manager", m.Name()), slog.Any("error", err))
+	}
+
+	slog.Warn("No supported network manager is running")
+	return nil
+}
+
+// serviceActive returns nil if the systemd unit is active.
+func serviceActive(ctx context.Context, run Runner, unit string) error {
+	out, err := run.Run(ctx, "systemctl", "is-active", unit)
+	if state := strings.TrimSpace(string(out)); state != "active" {
+		if state == "" && err != nil {
+			return err
+		}
+		return fmt.Errorf("%s is %s", unit, state)
+	}
+	return nil
+}
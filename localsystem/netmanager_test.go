@@ -0,0 +1,62 @@
+package localsystem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeRunner returns recorded output for each command line, and records the commands it ran.
+type fakeRunner struct {
+	outputs map[string]string
+	ran     []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	r.ran = append(r.ran, cmd)
+
+	out, ok := r.outputs[cmd]
+	if !ok {
+		return nil, fmt.Errorf("failed to run %s: exit status 1", name)
+	}
+	return []byte(out), nil
+}
+
+func TestServiceActive(t *testing.T) {
+	run := &fakeRunner{outputs: map[string]string{
+		"systemctl is-active dhcpcd":           "active\n",
+		"systemctl is-active systemd-networkd": "inactive\n",
+	}}
+
+	if err := serviceActive(context.Background(), run, "dhcpcd"); err != nil {
+		t.Errorf("expected dhcpcd to be active: %s", err)
+	}
+	if err := serviceActive(context.Background(), run, "systemd-networkd"); err == nil {
+		t.Errorf("expected systemd-networkd to be inactive")
+	}
+	if err := serviceActive(context.Background(), run, "NetworkManager"); err == nil {
+		t.Errorf("expected an error for a unit that couldn't be checked")
+	}
+}
+
+func TestStaticConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg   StaticConfig
+		valid bool
+	}{
+		{StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}, true},
+		{StaticConfig{Addresses: "10.5.0.20/24, 192.168.1.5/24", Gateway: "192.168.1.1"}, true},
+		{StaticConfig{Addresses: "10.5.0.20", Gateway: "10.5.0.1"}, false},
+		{StaticConfig{Addresses: "10.5.0.20/24", Gateway: "10.5.1.1"}, false},
+		{StaticConfig{Addresses: "10.5.0.20/24", Gateway: "10.5.0.1", DNS: "dns.byu.edu"}, false},
+		{StaticConfig{Gateway: "10.5.0.1"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expected valid to be %v, got %v", tt.cfg, tt.valid, err)
+		}
+	}
+}
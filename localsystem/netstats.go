@@ -0,0 +1,224 @@
+package localsystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	netClassRootPath = "/sys/class/net"
+	procWirelessPath = "/proc/net/wireless"
+)
+
+// InterfaceStats is the traffic and link state of a single network interface.
+// Rates are measured by the metrics sampler over its last interval, and are zero until it has taken two samples.
+type InterfaceStats struct {
+	Name           string `json:"name"`
+	MACAddress     string `json:"mac-address,omitempty"`
+	OperState      string `json:"oper-state"`
+	Carrier        bool   `json:"carrier"`
+	SpeedMbps      int    `json:"speed-mbps,omitempty"`
+	Duplex         string `json:"duplex,omitempty"`
+	MTU            int    `json:"mtu,omitempty"`
+	RxBytes        uint64 `json:"rx-bytes"`
+	TxBytes        uint64 `json:"tx-bytes"`
+	RxPackets      uint64 `json:"rx-packets"`
+	TxPackets      uint64 `json:"tx-packets"`
+	RxErrors       uint64 `json:"rx-errors"`
+	TxErrors       uint64 `json:"tx-errors"`
+	RxDropped      uint64 `json:"rx-dropped"`
+	TxDropped      uint64 `json:"tx-dropped"`
+	Collisions     uint64 `json:"collisions"`
+	CarrierChanges uint64 `json:"carrier-changes"`
+
+	RxBytesPerSec float64 `json:"rx-bytes-per-second"`
+	TxBytesPerSec float64 `json:"tx-bytes-per-second"`
+
+	Wireless *WirelessStats `json:"wireless,omitempty"`
+}
+
+// WirelessStats is the signal information for a wireless interface.
+type WirelessStats struct {
+	SSID        string  `json:"ssid,omitempty"`
+	LinkQuality float64 `json:"link-quality"`
+	SignalDBm   float64 `json:"signal-dbm"`
+	NoiseDBm    float64 `json:"noise-dbm"`
+}
+
+// ssidCacheTTL is how long the SSID of a wireless interface is cached, so iwgetid isn't run on
+// every call.
+const ssidCacheTTL = 30 * time.Second
+
+type cachedSSID struct {
+	ssid string
+	at   time.Time
+}
+
+var (
+	ssidMu    sync.Mutex
+	ssidCache = make(map[string]cachedSSID)
+)
+
+// InterfaceStatistics returns stats for every physical network interface on the device.
+func InterfaceStatistics() (map[string]InterfaceStats, error) {
+	stats, err := readInterfaceStats()
+	if err != nil {
+		return nil, err
+	}
+
+	wireless := readProcWireless()
+	for name, s := range stats {
+		s.RxBytesPerSec = sampledRate("net-rx-bytes-per-second-" + name)
+		s.TxBytesPerSec = sampledRate("net-tx-bytes-per-second-" + name)
+		if w, ok := wireless[name]; ok {
+			w.SSID = wirelessSSID(name)
+			s.Wireless = &w
+		}
+		stats[name] = s
+	}
+	return stats, nil
+}
+
+// readInterfaceStats reads the counters and link state of every physical network interface.
+func readInterfaceStats() (map[string]InterfaceStats, error) {
+	entries, err := os.ReadDir(netClassRootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	stats := make(map[string]InterfaceStats)
+	for _, e := range entries {
+		name := e.Name()
+		dir := filepath.Join(netClassRootPath, name)
+
+		// only report interfaces backed by real hardware (skips lo, docker0, veth*, ...)
+		if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+			continue
+		}
+
+		s := InterfaceStats{
+			Name:           name,
+			MACAddress:     readSysString(dir, "address"),
+			OperState:      readSysString(dir, "operstate"),
+			Carrier:        readSysString(dir, "carrier") == "1",
+			Duplex:         readSysString(dir, "duplex"),
+			MTU:            int(readSysUint(dir, "mtu")),
+			RxBytes:        readSysUint(dir, "statistics/rx_bytes"),
+			TxBytes:        readSysUint(dir, "statistics/tx_bytes"),
+			RxPackets:      readSysUint(dir, "statistics/rx_packets"),
+			TxPackets:      readSysUint(dir, "statistics/tx_packets"),
+			RxErrors:       readSysUint(dir, "statistics/rx_errors"),
+			TxErrors:       readSysUint(dir, "statistics/tx_errors"),
+			RxDropped:      readSysUint(dir, "statistics/rx_dropped"),
+			TxDropped:      readSysUint(dir, "statistics/tx_dropped"),
+			Collisions:     readSysUint(dir, "statistics/collisions"),
+			CarrierChanges: readSysUint(dir, "carrier_changes"),
+		}
+
+		// speed is -1 (or unreadable) when the link is down
+		if speed, err := strconv.Atoi(readSysString(dir, "speed")); err == nil && speed > 0 {
+			s.SpeedMbps = speed
+		}
+
+		stats[name] = s
+	}
+
+	return stats, nil
+}
+
+// readProcWireless reads the link quality and signal levels per interface from /proc/net/wireless.
+func readProcWireless() map[string]WirelessStats {
+	f, err := os.Open(procWirelessPath)
+	if err != nil {
+		return make(map[string]WirelessStats)
+	}
+	defer f.Close()
+
+	return parseProcWireless(f)
+}
+
+// parseProcWireless parses the contents of /proc/net/wireless.
+func parseProcWireless(r io.Reader) map[string]WirelessStats {
+	ret := make(map[string]WirelessStats)
+
+	// the first two lines are headers, e.g.
+	// wlan0: 0000   54.  -56.  -256        0      0      0      0     27        0
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) < 4 {
+			continue
+		}
+
+		var w WirelessStats
+		w.LinkQuality, _ = strconv.ParseFloat(strings.TrimSuffix(fields[1], "."), 64)
+		w.SignalDBm, _ = strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		w.NoiseDBm, _ = strconv.ParseFloat(strings.TrimSuffix(fields[3], "."), 64)
+		ret[strings.TrimSpace(name)] = w
+	}
+
+	return ret
+}
+
+// wirelessSSID returns the SSID the interface is associated with, or "" if it can't be determined.
+func wirelessSSID(iface string) string {
+	ssidMu.Lock()
+	defer ssidMu.Unlock()
+
+	if c, ok := ssidCache[iface]; ok && time.Since(c.at) < ssidCacheTTL {
+		return c.ssid
+	}
+
+	ssid := runIwgetid(iface)
+	ssidCache[iface] = cachedSSID{ssid: ssid, at: time.Now()}
+	return ssid
+}
+
+func runIwgetid(iface string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "iwgetid", "-r", iface).Output()
+	if err != nil {
+		slog.Debug("failed to get ssid", slog.String("interface", iface), slog.Any("error", err))
+		return ""
+	}
+
+	return string(bytes.TrimSpace(out))
+}
+
+func readSysString(dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func readSysUint(dir, name string) uint64 {
+	v, err := strconv.ParseUint(readSysString(dir, name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
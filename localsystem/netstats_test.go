@@ -0,0 +1,37 @@
+package localsystem
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcWireless(t *testing.T) {
+	in := `Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+ wlan0: 0000   54.  -56.  -256        0      0      0      0     27        0
+  wlp2s0: 0000   70  -40  -95        0      0      0      0      0        0
+ bad0: 0000
+`
+	got := parseProcWireless(strings.NewReader(in))
+	if len(got) != 2 {
+		t.Fatalf("expected 2 interfaces, got %v", got)
+	}
+
+	w := got["wlan0"]
+	if w.LinkQuality != 54 || w.SignalDBm != -56 || w.NoiseDBm != -256 {
+		t.Errorf("unexpected wlan0 stats: %+v", w)
+	}
+	w = got["wlp2s0"]
+	if w.LinkQuality != 70 || w.SignalDBm != -40 || w.NoiseDBm != -95 {
+		t.Errorf("unexpected wlp2s0 stats: %+v", w)
+	}
+}
+
+func TestParseProcWirelessEmpty(t *testing.T) {
+	in := `Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+`
+	if got := parseProcWireless(strings.NewReader(in)); len(got) != 0 {
+		t.Errorf("expected no interfaces, got %v", got)
+	}
+}
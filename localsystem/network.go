@@ -1,15 +1,13 @@
 package localsystem
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
 	"net"
 	"os"
-	"os/exec"
-	"strings"
 )
 
 const staticConfigPath = "/etc/network/static_config.json"
@@ -38,36 +36,54 @@ func MustHostname() string {
 	return h
 }
 
-// IPAddress gets the public ip address of the device
+// IPAddress returns the address of the primary interface, preferring IPv4 to a global IPv6 address.
 func IPAddress() (net.IP, error) {
-	addrs, err := net.InterfaceAddrs()
+	name, err := PrimaryInterface()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+		return nil, err
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of %s: %w", name, err)
 	}
 
 	var ip net.IP
 	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok &&
-			!ipnet.IP.IsLoopback() &&
-			strings.Contains(addr.String(), "/24") {
-
-			parsed, _, perr := net.ParseCIDR(addr.String())
-			if perr != nil {
-				return nil, fmt.Errorf("failed to parse IP %q: %w", addr.String(), perr)
-			}
-			ip = parsed
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			ip = ipnet.IP
 			break
 		}
+		if ip == nil && ipnet.IP.IsGlobalUnicast() {
+			ip = ipnet.IP
+		}
 	}
 
 	if ip == nil {
-		return nil, fmt.Errorf("no non‑loopback /24 IP address found")
+		return nil, fmt.Errorf("no IP address found on %s", name)
 	}
 
-	slog.Info("My IP address", slog.String("ip", ip.String()))
+	slog.Info("My IP address", slog.String("ip", ip.String()), slog.String("interface", name))
 	return ip, nil
 }
 
+// DefaultGateway returns the gateway of the default IPv4 route, picking the lowest metric if there's more than one.
+func DefaultGateway() (net.IP, error) {
+	r, err := defaultRoute()
+	if err != nil {
+		return nil, err
+	}
+	return r.Gateway, nil
+}
+
 // IsConnectedToInternet returns true if the device can reach google's servers.
 func IsConnectedToInternet() bool {
 	conn, err := net.Dial("tcp", "google.com:80")
@@ -80,127 +96,50 @@ func IsConnectedToInternet() bool {
 
 // UsingDHCP returns true if the device is using DHCP, and false if it has a static ip set.
 func UsingDHCP() (bool, error) {
-
-	// Get the active connection name
-	connName, err := getActiveConnectionName()
+	ctx := context.Background()
+	m, conn, err := activeConnection(ctx)
 	if err != nil {
-		return false, fmt.Errorf("failed to get active connection name: %w", err)
+		return false, err
 	}
 
-	// use nmcli to check the ipv4 method of the connection
-	cmd := exec.Command("nmcli", "-t", "-f", "ipv4.method", "connection", "show", connName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return false, fmt.Errorf("failed to run nmcli command: %w", err)
-	}
-
-	// Parse the output ipv4.method:string method is only after the colon
-	method := strings.TrimSpace(out.String())
-	method = strings.TrimPrefix(method, "ipv4.method:")
-	switch method {
-	case "auto":
-		return true, nil
-	case "manual", "disabled": // manual means static IP, disabled means no IP
-		return false, nil
-	default:
-		return false, fmt.Errorf("unknown ipv4.method value: %s", method)
+	s, err := m.IPv4(ctx, conn)
+	if err != nil {
+		return false, err
 	}
+	return s.DHCP, nil
 }
 
-// ToggleDHCP switches between DHCP and static mode. (using nmcli)
+// ToggleDHCP switches between DHCP and static mode.
 func ToggleDHCP() error {
-	connName, err := getActiveConnectionName()
-	if err != nil {
-		return err
-	}
-
 	enabled, err := UsingDHCP()
 	if err != nil {
 		return fmt.Errorf("failed to determine current DHCP state: %w", err)
 	}
-
-	if enabled {
-		// DHCP → Static (restore from saved config)
-		cfg, err := loadStaticConfig()
-		if err != nil {
-			return fmt.Errorf("cannot restore static config: %w", err)
-		}
-
-		cmd := exec.Command("nmcli", "connection", "modify", connName,
-			"ipv4.method", "manual",
-			"ipv4.addresses", cfg.Addresses,
-			"ipv4.gateway", cfg.Gateway,
-			"ipv4.dns", cfg.DNS)
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to apply static config: %w — %s", err, strings.TrimSpace(string(out)))
-		}
-	} else {
-		// Static → DHCP (save current static config)
-		cfg, err := readCurrentStaticConfig(connName)
-		if err != nil {
-			return fmt.Errorf("cannot save static config: %w", err)
-		}
-		if err := saveStaticConfig(cfg); err != nil {
-			return err
-		}
-
-		cmd := exec.Command("nmcli", "connection", "modify", connName,
-			"ipv4.method", "auto",
-			"ipv4.addresses", "",
-			"ipv4.gateway", "",
-			"ipv4.dns", "")
-		if out, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to switch to DHCP: %w — %s", err, strings.TrimSpace(string(out)))
-		}
-	}
-
-	// Apply changes
-	if out, err := exec.Command("nmcli", "connection", "up", connName).CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to activate connection: %w — %s", err, strings.TrimSpace(string(out)))
-	}
-	return nil
+	return SetDHCP(context.Background(), !enabled, 0, nil)
 }
 
 // CanToggleDHCP returns nil if you can toggle DHCP, or an error if you can't
 func CanToggleDHCP() error {
-	connName, err := getActiveConnectionName()
+	ctx := context.Background()
+	m, conn, err := activeConnection(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get active connection name: %w", err)
-	}
-
-	// Check if the connection is modifiable (not locked)
-	cmd := exec.Command("nmcli", "-t", "-f", "connection.permissions", "connection", "show", connName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run nmcli command: %w", err)
-	}
-
-	permissions := strings.TrimSpace(out.String())
-	if permissions != "" && permissions != "--" {
-		return fmt.Errorf("connection %s is not modifiable, permissions: %s", connName, permissions)
+		return err
 	}
-
-	return nil
+	return m.Modifiable(ctx, conn)
 }
 
-func getActiveConnectionName() (string, error) {
-	cmd := exec.Command("nmcli", "-t", "-f", "NAME", "connection", "show", "--active")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to run nmcli command: %w", err)
+// activeConnection returns the current network manager and the connection DHCP is set on.
+func activeConnection(ctx context.Context) (NetworkManager, string, error) {
+	m, err := CurrentNetworkManager(ctx)
+	if err != nil {
+		return nil, "", err
 	}
 
-	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
-	if len(lines) == 0 || lines[0] == "" {
-		return "", fmt.Errorf("no active network connection found")
+	conn, err := m.Connection(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get active connection: %w", err)
 	}
-
-	return lines[0], nil
+	return m, conn, nil
 }
 
 func saveStaticConfig(cfg StaticConfig) error {
@@ -226,24 +165,3 @@ func loadStaticConfig() (StaticConfig, error) {
 	}
 	return cfg, nil
 }
-
-func readCurrentStaticConfig(connName string) (StaticConfig, error) {
-	cmd := exec.Command("nmcli", "-t", "-f", "ipv4.addresses,ipv4.gateway,ipv4.dns", "connection", "show", connName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return StaticConfig{}, fmt.Errorf("failed to read current static config: %w", err)
-	}
-
-	lines := strings.Split(strings.TrimSpace(out.String()), ":")
-	if len(lines) < 3 {
-		return StaticConfig{}, fmt.Errorf("unexpected nmcli output: %s", out.String())
-	}
-
-	return StaticConfig{
-		Addresses: lines[0],
-		Gateway:   lines[1],
-		DNS:       lines[2],
-	}, nil
-}
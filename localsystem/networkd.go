@@ -0,0 +1,319 @@
+package localsystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const networkdConfigDir = "/etc/systemd/network/"
+
+// networkdManager configures systemd-networkd through the .network file of an interface.
+type networkdManager struct {
+	run Runner
+}
+
+func (*networkdManager) Name() string {
+	return NetworkManagerSD
+}
+
+func (m *networkdManager) Available(ctx context.Context) error {
+	return serviceActive(ctx, m.run, "systemd-networkd")
+}
+
+// Connection returns the primary interface.
+func (m *networkdManager) Connection(context.Context) (string, error) {
+	return PrimaryInterface()
+}
+
+func (m *networkdManager) Modifiable(ctx context.Context, iface string) error {
+	path, err := m.networkFile(ctx, iface)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(path, networkdConfigDir) {
+		return fmt.Errorf("%s is configured by %s, which isn't in %s", iface, path, networkdConfigDir)
+	}
+	if err := unix.Access(path, unix.W_OK); err != nil {
+		return fmt.Errorf("%s is not writable: %w", path, err)
+	}
+	return nil
+}
+
+func (m *networkdManager) IPv4(ctx context.Context, iface string) (IPv4Settings, error) {
+	path, err := m.networkFile(ctx, iface)
+	if err != nil {
+		return IPv4Settings{}, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return IPv4Settings{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return parseNetworkdFile(string(b)), nil
+}
+
+func (m *networkdManager) SetIPv4(ctx context.Context, iface string, s IPv4Settings) error {
+	path, err := m.networkFile(ctx, iface)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := writeFileAtomic(path, []byte(renderNetworkdFile(string(b), s))); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if _, err := m.run.Run(ctx, "networkctl", "reload"); err != nil {
+		return fmt.Errorf("failed to reload networkd: %w", err)
+	}
+	if _, err := m.run.Run(ctx, "networkctl", "reconfigure", iface); err != nil {
+		return fmt.Errorf("failed to reconfigure %s: %w", iface, err)
+	}
+	return nil
+}
+
+func (m *networkdManager) DNS(ctx context.Context, iface string) ([]string, error) {
+	status, err := m.status(ctx, iface)
+	if err != nil {
+		return nil, err
+	}
+	return status["DNS"], nil
+}
+
+// networkFile returns the path of the .network file that configures iface.
+func (m *networkdManager) networkFile(ctx context.Context, iface string) (string, error) {
+	status, err := m.status(ctx, iface)
+	if err != nil {
+		return "", err
+	}
+
+	var path string
+	if v := status["Network File"]; len(v) > 0 {
+		path = v[0]
+	}
+	if path == "" || path == "n/a" {
+		return "", fmt.Errorf("%s isn't configured by a .network file", iface)
+	}
+	return path, nil
+}
+
+func (m *networkdManager) status(ctx context.Context, iface string) (map[string][]string, error) {
+	out, err := m.run.Run(ctx, "networkctl", "status", "--no-pager", iface)
+	if err != nil {
+		return nil, err
+	}
+	return parseNetworkctlStatus(string(out)), nil
+}
+
+// parseNetworkctlStatus parses the "Key: value" lines of networkctl status, which looks like:
+//
+//	● 2: eth0
+//	                     Link File: /usr/lib/systemd/network/99-default.link
+//	                  Network File: /etc/systemd/network/10-eth0.network
+//	                         State: routable (configured)
+//	                       Address: 10.5.0.20
+//	                                fe80::dea6:32ff:fe01:2345
+//
+// Values that continue onto the next lines are returned in order.
+func parseNetworkctlStatus(s string) map[string][]string {
+	status := make(map[string][]string)
+	key := ""
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		k, value, ok := strings.Cut(trimmed, ": ")
+		switch {
+		case ok:
+			key = k
+			status[key] = append(status[key], strings.TrimSpace(value))
+		case key != "" && trimmed != "" && strings.HasPrefix(line, " "):
+			status[key] = append(status[key], trimmed)
+		default:
+			key = ""
+		}
+	}
+	return status
+}
+
+// networkdSection is a section of a .network file, with its header line (if it has one).
+type networkdSection struct {
+	name  string
+	lines []string
+}
+
+func splitNetworkdSections(file string) []networkdSection {
+	sections := []networkdSection{{}}
+	for _, line := range strings.Split(strings.TrimRight(file, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			sections = append(sections, networkdSection{name: strings.Trim(trimmed, "[]")})
+		}
+		sections[len(sections)-1].lines = append(sections[len(sections)-1].lines, line)
+	}
+	return sections
+}
+
+// networkdOption parses a "Key=value" line.
+func networkdOption(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(trimmed, "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// parseNetworkdFile returns the ipv4 settings from a .network file, which looks like:
+//
+//	[Match]
+//	Name=eth0
+//
+//	[Network]
+//	DHCP=no
+//	Address=10.5.0.20/23
+//	Gateway=10.5.1.1
+//	DNS=10.8.0.26 10.8.0.19
+//
+// Addresses and gateways may also be set in [Address] and [Route] sections. IPv6 values are skipped.
+func parseNetworkdFile(file string) IPv4Settings {
+	var s IPv4Settings
+	var addrs, dns []string
+
+	for _, sec := range splitNetworkdSections(file) {
+		for _, line := range sec.lines {
+			key, value, ok := networkdOption(line)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case sec.name == "Network" && key == "DHCP":
+				s.DHCP = value == "yes" || value == "true" || value == "ipv4" || value == "both"
+			case (sec.name == "Network" || sec.name == "Address") && key == "Address":
+				for _, a := range strings.Fields(value) {
+					if !strings.Contains(a, ":") {
+						addrs = append(addrs, a)
+					}
+				}
+			case (sec.name == "Network" || sec.name == "Route") && key == "Gateway":
+				if s.Gateway == "" && !strings.Contains(value, ":") {
+					s.Gateway = value
+				}
+			case sec.name == "Network" && key == "DNS":
+				for _, d := range strings.Fields(value) {
+					if !strings.Contains(d, ":") {
+						dns = append(dns, d)
+					}
+				}
+			}
+		}
+	}
+
+	s.Addresses = strings.Join(addrs, ",")
+	s.DNS = strings.Join(dns, ",")
+	return s
+}
+
+// renderNetworkdFile replaces the ipv4 settings in a .network file with s. IPv6 addresses,
+// gateways and DNS servers are kept, as is DHCPv6 when it's on.
+func renderNetworkdFile(file string, s IPv4Settings) string {
+	sections := splitNetworkdSections(file)
+	dhcp := ""
+
+	var out []string
+	network := -1
+	for _, sec := range sections {
+		if sec.name == "Address" || sec.name == "Route" {
+			if ipv4Only(sec) {
+				continue
+			}
+		}
+
+		var lines []string
+		for _, line := range sec.lines {
+			key, value, ok := networkdOption(line)
+			if ok && sec.name == "Network" {
+				switch key {
+				case "DHCP":
+					dhcp = value
+					continue
+				case "Address", "Gateway", "DNS":
+					if v6 := ipv6Fields(value); len(v6) > 0 {
+						lines = append(lines, key+"="+strings.Join(v6, " "))
+					}
+					continue
+				}
+			}
+			lines = append(lines, line)
+		}
+
+		if sec.name == "Network" && network == -1 {
+			network = len(out) + 1
+		}
+		out = append(out, lines...)
+	}
+
+	v6 := dhcp == "yes" || dhcp == "true" || dhcp == "ipv6" || dhcp == "both"
+	var opts []string
+	switch {
+	case s.DHCP && v6:
+		opts = append(opts, "DHCP=yes")
+	case s.DHCP:
+		opts = append(opts, "DHCP=ipv4")
+	case v6:
+		opts = append(opts, "DHCP=ipv6")
+	default:
+		opts = append(opts, "DHCP=no")
+	}
+	if !s.DHCP {
+		for _, a := range splitList(s.Addresses) {
+			opts = append(opts, "Address="+a)
+		}
+		if s.Gateway != "" {
+			opts = append(opts, "Gateway="+s.Gateway)
+		}
+		if dns := splitList(s.DNS); len(dns) > 0 {
+			opts = append(opts, "DNS="+strings.Join(dns, " "))
+		}
+	}
+
+	if network == -1 {
+		out = append(out, "", "[Network]")
+		out = append(out, opts...)
+	} else {
+		out = append(out[:network], append(opts, out[network:]...)...)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n") + "\n"
+}
+
+// ipv4Only returns true if the Address or Gateway of an [Address] or [Route] section is an ipv4
+// address, and a [Route] is a default route.
+func ipv4Only(sec networkdSection) bool {
+	for _, line := range sec.lines {
+		key, value, ok := networkdOption(line)
+		switch {
+		case !ok:
+		case key == "Destination" && value != "0.0.0.0/0":
+			return false
+		case key == "Address" || key == "Gateway":
+			if strings.Contains(value, ":") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func ipv6Fields(value string) []string {
+	var v6 []string
+	for _, f := range strings.Fields(value) {
+		if strings.Contains(f, ":") {
+			v6 = append(v6, f)
+		}
+	}
+	return v6
+}
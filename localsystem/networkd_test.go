@@ -0,0 +1,109 @@
+package localsystem
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const (
+	networkctlStatus = `● 2: eth0
+                     Link File: /usr/lib/systemd/network/99-default.link
+                  Network File: /etc/systemd/network/10-eth0.network
+                         State: routable (configured)
+                  Online state: online
+                          Type: ether
+                          Path: platform-fd580000.ethernet
+                        Driver: bcmgenet
+                    HW Address: dc:a6:32:01:23:45 (Raspberry Pi Trading Ltd)
+                       Address: 10.5.0.20
+                                fe80::dea6:32ff:fe01:2345
+                       Gateway: 10.5.1.1
+                           DNS: 10.8.0.26
+                                10.8.0.19
+`
+
+	networkdFile = `[Match]
+Name=eth0
+
+[Network]
+DHCP=ipv6
+Address=10.5.0.20/23
+Address=2001:db8::20/64
+Gateway=10.5.1.1
+DNS=10.8.0.26 10.8.0.19 2001:db8::53
+
+[Route]
+Destination=10.9.0.0/16
+Gateway=10.5.0.2
+`
+)
+
+func TestNetworkdFile(t *testing.T) {
+	run := &fakeRunner{outputs: map[string]string{
+		"networkctl status --no-pager eth0": networkctlStatus,
+		"networkctl status --no-pager eth1": "● 3: eth1\n  Network File: n/a\n",
+	}}
+	m := &networkdManager{run: run}
+
+	path, err := m.networkFile(context.Background(), "eth0")
+	if err != nil || path != "/etc/systemd/network/10-eth0.network" {
+		t.Errorf("expected the network file of eth0, got %q (%v)", path, err)
+	}
+	if _, err := m.networkFile(context.Background(), "eth1"); err == nil {
+		t.Errorf("expected an error for an interface without a network file")
+	}
+
+	dns, err := m.DNS(context.Background(), "eth0")
+	if err != nil || strings.Join(dns, ",") != "10.8.0.26,10.8.0.19" {
+		t.Errorf("expected the DNS servers of eth0, got %q (%v)", dns, err)
+	}
+}
+
+func TestParseNetworkdFile(t *testing.T) {
+	got := parseNetworkdFile(networkdFile)
+	want := IPv4Settings{StaticConfig: StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	got = parseNetworkdFile("[Match]\nName=eth0\n\n[Network]\nDHCP=yes\n")
+	if !got.DHCP {
+		t.Errorf("expected DHCP, got %+v", got)
+	}
+
+	got = parseNetworkdFile("[Network]\nDHCP=no\n\n[Address]\nAddress=10.5.0.20/23\n\n[Route]\nGateway=10.5.1.1\n")
+	if got.DHCP || got.Addresses != "10.5.0.20/23" || got.Gateway != "10.5.1.1" {
+		t.Errorf("expected the address and gateway from their sections, got %+v", got)
+	}
+}
+
+func TestRenderNetworkdFile(t *testing.T) {
+	dhcp := renderNetworkdFile(networkdFile, IPv4Settings{DHCP: true})
+	want := `[Match]
+Name=eth0
+
+[Network]
+DHCP=yes
+Address=2001:db8::20/64
+DNS=2001:db8::53
+
+[Route]
+Destination=10.9.0.0/16
+Gateway=10.5.0.2
+`
+	if dhcp != want {
+		t.Errorf("expected the ipv4 settings to be removed, got:\n%s", dhcp)
+	}
+
+	static := IPv4Settings{StaticConfig: StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}}
+	restored := renderNetworkdFile(dhcp, static)
+	if s := parseNetworkdFile(restored); s != static {
+		t.Errorf("expected %+v, got %+v", static, s)
+	}
+
+	got := renderNetworkdFile("[Match]\nName=eth0\n\n[Network]\nDHCP=no\n\n[Address]\nAddress=10.5.0.20/23\n\n[Route]\nGateway=10.5.1.1\n", IPv4Settings{DHCP: true})
+	if want := "[Match]\nName=eth0\n\n[Network]\nDHCP=ipv4\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,199 @@
+package localsystem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// nmcliManager configures NetworkManager connections with nmcli.
+type nmcliManager struct {
+	run Runner
+}
+
+func (*nmcliManager) Name() string {
+	return NetworkManagerNM
+}
+
+func (m *nmcliManager) Available(ctx context.Context) error {
+	out, err := m.run.Run(ctx, "nmcli", "-t", "-f", "RUNNING", "general")
+	if err != nil {
+		return err
+	}
+	if state := strings.TrimSpace(string(out)); state != "running" {
+		return fmt.Errorf("NetworkManager is %s", state)
+	}
+	return nil
+}
+
+// Connection returns the active connection of the primary interface, or the first active one
+// that isn't the loopback if the primary interface can't be found.
+func (m *nmcliManager) Connection(ctx context.Context) (string, error) {
+	out, err := m.run.Run(ctx, "nmcli", "-t", "-f", "NAME,TYPE,DEVICE", "connection", "show", "--active")
+	if err != nil {
+		return "", err
+	}
+
+	primary, _ := PrimaryInterface()
+	return activeNMCLIConnection(string(out), primary)
+}
+
+// activeNMCLIConnection picks the connection on device from the active connections.
+func activeNMCLIConnection(active, device string) (string, error) {
+	first := ""
+	for _, line := range strings.Split(active, "\n") {
+		fields := splitTerse(line)
+		if len(fields) < 3 || fields[0] == "" || fields[1] == "loopback" {
+			continue
+		}
+		if fields[2] == device {
+			return fields[0], nil
+		}
+		if first == "" {
+			first = fields[0]
+		}
+	}
+
+	if first == "" {
+		return "", fmt.Errorf("no active network connection found")
+	}
+	return first, nil
+}
+
+func (m *nmcliManager) Modifiable(ctx context.Context, conn string) error {
+	settings, err := m.settings(ctx, conn, "connection.permissions")
+	if err != nil {
+		return err
+	}
+
+	if permissions := settings["connection.permissions"]; permissions != "" {
+		return fmt.Errorf("connection %s is not modifiable, permissions: %s", conn, permissions)
+	}
+	return nil
+}
+
+func (m *nmcliManager) IPv4(ctx context.Context, conn string) (IPv4Settings, error) {
+	settings, err := m.settings(ctx, conn, "ipv4.method", "ipv4.addresses", "ipv4.gateway", "ipv4.dns")
+	if err != nil {
+		return IPv4Settings{}, err
+	}
+
+	s := IPv4Settings{
+		StaticConfig: StaticConfig{
+			Addresses: settings["ipv4.addresses"],
+			Gateway:   settings["ipv4.gateway"],
+			DNS:       settings["ipv4.dns"],
+		},
+	}
+	switch method := settings["ipv4.method"]; method {
+	case "auto":
+		s.DHCP = true
+	case "manual", "disabled": // manual means static IP, disabled means no IP
+	default:
+		return s, fmt.Errorf("unknown ipv4.method value: %q", method)
+	}
+	return s, nil
+}
+
+func (m *nmcliManager) SetIPv4(ctx context.Context, conn string, s IPv4Settings) error {
+	args := []string{"connection", "modify", conn}
+	if s.DHCP {
+		args = append(args, "ipv4.method", "auto", "ipv4.addresses", "", "ipv4.gateway", "", "ipv4.dns", "")
+	} else {
+		args = append(args, "ipv4.method", "manual", "ipv4.addresses", s.Addresses, "ipv4.gateway", s.Gateway, "ipv4.dns", s.DNS)
+	}
+
+	if _, err := m.run.Run(ctx, "nmcli", args...); err != nil {
+		return fmt.Errorf("failed to modify connection: %w", err)
+	}
+	if _, err := m.run.Run(ctx, "nmcli", "connection", "up", conn); err != nil {
+		return fmt.Errorf("failed to activate connection: %w", err)
+	}
+	return nil
+}
+
+func (m *nmcliManager) DNS(ctx context.Context, iface string) ([]string, error) {
+	out, err := m.run.Run(ctx, "nmcli", "-t", "-f", "IP4.DNS,IP6.DNS", "device", "show", iface)
+	if err != nil {
+		return nil, err
+	}
+	return parseNMCLIDNS(string(out)), nil
+}
+
+// parseNMCLIDNS returns the DNS servers from the terse output of nmcli device show, which lists
+// them like:
+//
+//	IP4.DNS[1]:10.8.0.26
+//	IP4.DNS[2]:10.8.0.19
+//	IP6.DNS[1]:2001\:db8\:\:53
+func parseNMCLIDNS(s string) []string {
+	var dns []string
+	for _, line := range strings.Split(s, "\n") {
+		fields := splitTerse(line)
+		if len(fields) < 2 || !strings.Contains(fields[0], ".DNS[") {
+			continue
+		}
+		if v := strings.Join(fields[1:], ":"); v != "" {
+			dns = append(dns, v)
+		}
+	}
+	return dns
+}
+
+// settings returns the values of fields of conn.
+func (m *nmcliManager) settings(ctx context.Context, conn string, fields ...string) (map[string]string, error) {
+	out, err := m.run.Run(ctx, "nmcli", "-t", "-f", strings.Join(fields, ","), "connection", "show", conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s settings: %w", conn, err)
+	}
+	return parseNMCLISettings(string(out)), nil
+}
+
+// parseNMCLISettings parses the terse output of nmcli connection show, which looks like:
+//
+//	ipv4.method:manual
+//	ipv4.addresses:10.5.0.20/23
+//	ipv6.dns:2001\:db8\:\:53
+//
+// An empty value (or "--") is returned as "".
+func parseNMCLISettings(s string) map[string]string {
+	settings := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		fields := splitTerse(line)
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		value := strings.Join(fields[1:], ":")
+		if value == "--" {
+			value = ""
+		}
+		settings[fields[0]] = value
+	}
+	return settings
+}
+
+// splitTerse splits a line of nmcli's terse output into its fields. Colons and backslashes in
+// the values are escaped with a backslash.
+func splitTerse(line string) []string {
+	line = strings.TrimRight(line, "\r")
+	if line == "" {
+		return nil
+	}
+
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(fields, cur.String())
+}
@@ -0,0 +1,133 @@
+package localsystem
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const (
+	nmcliActive = `lo:loopback:lo
+Wired connection 1:802-3-ethernet:eth0
+docker0:bridge:docker0
+`
+	nmcliStatic = `ipv4.method:manual
+ipv4.addresses:10.5.0.20/23
+ipv4.gateway:10.5.1.1
+ipv4.dns:10.8.0.26,10.8.0.19
+`
+	nmcliDHCP = `ipv4.method:auto
+ipv4.addresses:
+ipv4.gateway:--
+ipv4.dns:
+`
+)
+
+func TestSplitTerse(t *testing.T) {
+	got := splitTerse(`ipv6.addresses:2001\:db8\:\:20/64:c\\d`)
+	want := []string{"ipv6.addresses", "2001:db8::20/64", `c\d`}
+	if len(got) != len(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseNMCLISettings(t *testing.T) {
+	settings := parseNMCLISettings(nmcliStatic + `ipv6.dns:2001\:db8\:\:53
+connection.permissions:--
+`)
+
+	for key, want := range map[string]string{
+		"ipv4.method":            "manual",
+		"ipv4.addresses":         "10.5.0.20/23",
+		"ipv4.gateway":           "10.5.1.1",
+		"ipv4.dns":               "10.8.0.26,10.8.0.19",
+		"ipv6.dns":               "2001:db8::53",
+		"connection.permissions": "",
+	} {
+		if got := settings[key]; got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestNMCLIManager(t *testing.T) {
+	run := &fakeRunner{outputs: map[string]string{
+		"nmcli -t -f RUNNING general":                                                                     "running\n",
+		"nmcli -t -f NAME,TYPE,DEVICE connection show --active":                                           nmcliActive,
+		"nmcli -t -f ipv4.method,ipv4.addresses,ipv4.gateway,ipv4.dns connection show Wired connection 1": nmcliStatic,
+		"nmcli -t -f connection.permissions connection show Wired connection 1":                           "connection.permissions:user:pi\n",
+	}}
+	m := &nmcliManager{run: run}
+	ctx := context.Background()
+
+	if err := m.Available(ctx); err != nil {
+		t.Fatalf("expected NetworkManager to be available: %s", err)
+	}
+
+	conn, err := m.Connection(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %s", err)
+	}
+	if conn != "Wired connection 1" {
+		t.Fatalf("expected the loopback to be skipped, got %q", conn)
+	}
+
+	s, err := m.IPv4(ctx, conn)
+	if err != nil {
+		t.Fatalf("failed to read ipv4 settings: %s", err)
+	}
+	want := IPv4Settings{StaticConfig: StaticConfig{Addresses: "10.5.0.20/23", Gateway: "10.5.1.1", DNS: "10.8.0.26,10.8.0.19"}}
+	if s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	if err := m.Modifiable(ctx, conn); err == nil {
+		t.Errorf("expected a connection with permissions to not be modifiable")
+	}
+
+	run.outputs["nmcli -t -f ipv4.method,ipv4.addresses,ipv4.gateway,ipv4.dns connection show Wired connection 1"] = nmcliDHCP
+	if s, err = m.IPv4(ctx, conn); err != nil || !s.DHCP || s.Gateway != "" {
+		t.Errorf("expected DHCP with no gateway, got %+v (%v)", s, err)
+	}
+
+	run.outputs["nmcli connection modify Wired connection 1 ipv4.method auto ipv4.addresses  ipv4.gateway  ipv4.dns "] = ""
+	run.outputs["nmcli connection up Wired connection 1"] = ""
+	run.ran = nil
+	if err := m.SetIPv4(ctx, conn, IPv4Settings{DHCP: true}); err != nil {
+		t.Fatalf("failed to set ipv4 settings: %s", err)
+	}
+	if len(run.ran) != 2 {
+		t.Errorf("expected the connection to be modified and brought up, ran %q", run.ran)
+	}
+}
+
+func TestActiveNMCLIConnection(t *testing.T) {
+	for device, want := range map[string]string{
+		"eth0":    "Wired connection 1",
+		"docker0": "docker0",
+		"":        "Wired connection 1",
+	} {
+		if got, err := activeNMCLIConnection(nmcliActive, device); err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q (%v)", device, want, got, err)
+		}
+	}
+
+	if _, err := activeNMCLIConnection("lo:loopback:lo\n", "eth0"); err == nil {
+		t.Errorf("expected an error with only the loopback active")
+	}
+}
+
+func TestParseNMCLIDNS(t *testing.T) {
+	dns := parseNMCLIDNS(`IP4.DNS[1]:10.8.0.26
+IP4.DNS[2]:10.8.0.19
+IP6.DNS[1]:2001\:db8\:\:53
+`)
+	if strings.Join(dns, ",") != "10.8.0.26,10.8.0.19,2001:db8::53" {
+		t.Errorf("unexpected DNS servers %q", dns)
+	}
+}
@@ -0,0 +1,293 @@
+package localsystem
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const defaultTopN = 5
+
+// WatchedProcess matches processes by exact name or by a regex on their command line.
+type WatchedProcess struct {
+	Name    string `json:"name"`
+	Process string `json:"process,omitempty"`
+	Cmdline string `json:"cmdline,omitempty"`
+
+	cmdline *regexp.Regexp
+}
+
+// ProcessConfig is the list of processes to watch, and how many to include in the top lists.
+type ProcessConfig struct {
+	Watch []WatchedProcess `json:"watch,omitempty"`
+	TopN  int              `json:"top-n,omitempty"`
+}
+
+// ProcessStats is the resource usage of a single process.
+// CPUPercent is measured since the previous sample.
+type ProcessStats struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	Cmdline    string  `json:"cmdline,omitempty"`
+	RSS        uint64  `json:"rss"`
+	CPUPercent float64 `json:"cpu-percent"`
+	OpenFDs    int32   `json:"open-fds"`
+	Threads    int32   `json:"threads"`
+	CreateTime int64   `json:"create-time"`
+
+	status string
+}
+
+// WatchedProcessStats is the combined usage of every process matching a WatchedProcess.
+// MainPID is the oldest matching process, and stays the main process for as long as it runs.
+// It's counted as a restart when every matching process was started since the main process
+// was last seen, so helpers and children coming and going aren't.
+type WatchedProcessStats struct {
+	Name       string         `json:"name"`
+	Running    bool           `json:"running"`
+	MainPID    int32          `json:"main-pid,omitempty"`
+	Restarts   int            `json:"restarts"`
+	RSS        uint64         `json:"rss"`
+	CPUPercent float64        `json:"cpu-percent"`
+	OpenFDs    int32          `json:"open-fds"`
+	Threads    int32          `json:"threads"`
+	Processes  []ProcessStats `json:"processes"`
+}
+
+// ProcessSnapshot is the result of one pass over every process on the device.
+type ProcessSnapshot struct {
+	SampledAt time.Time             `json:"sampled-at"`
+	Watched   []WatchedProcessStats `json:"watched"`
+	TopMemory []ProcessStats        `json:"top-memory"`
+	TopCPU    []ProcessStats        `json:"top-cpu"`
+	// ProcsUSleep is the number of processes in uninterruptible sleep (state D).
+	ProcsUSleep int `json:"procs-u-sleep"`
+
+	// uSleep is the names of the processes in uninterruptible sleep.
+	uSleep []string
+}
+
+type processTracker struct {
+	mu       sync.Mutex
+	cfg      ProcessConfig
+	cache    map[int32]*process.Process
+	mains    map[string]ProcessStats
+	restarts map[string]int
+	last     ProcessSnapshot
+}
+
+var procs = &processTracker{
+	cache:    make(map[int32]*process.Process),
+	mains:    make(map[string]ProcessStats),
+	restarts: make(map[string]int),
+}
+
+// SetProcessConfig sets the processes to watch.
+func SetProcessConfig(cfg ProcessConfig) error {
+	for i := range cfg.Watch {
+		w := &cfg.Watch[i]
+		if w.Name == "" {
+			return fmt.Errorf("watched process %d is missing a name", i)
+		}
+		if w.Process == "" && w.Cmdline == "" {
+			return fmt.Errorf("watched process %q needs a process name or cmdline pattern", w.Name)
+		}
+		if w.Cmdline != "" {
+			rx, err := regexp.Compile(w.Cmdline)
+			if err != nil {
+				return fmt.Errorf("invalid cmdline pattern for %q: %w", w.Name, err)
+			}
+			w.cmdline = rx
+		}
+	}
+
+	procs.mu.Lock()
+	defer procs.mu.Unlock()
+	procs.cfg = cfg
+	return nil
+}
+
+// ProcessesInfo returns the most recent process snapshot taken by the sampler,
+// or takes one now if the sampler isn't running.
+func ProcessesInfo() (ProcessSnapshot, error) {
+	if GetSampler() != nil {
+		procs.mu.Lock()
+		last := procs.last
+		procs.mu.Unlock()
+
+		if !last.SampledAt.IsZero() {
+			return last, nil
+		}
+	}
+
+	return procs.sample()
+}
+
+func (w WatchedProcess) matches(name, cmdline string) bool {
+	if w.Process != "" && w.Process == name {
+		return true
+	}
+	return w.cmdline != nil && w.cmdline.MatchString(cmdline)
+}
+
+// sample reads usage for every process, updating the cached snapshot.
+func (t *processTracker) sample() (ProcessSnapshot, error) {
+	list, err := process.Processes()
+	if err != nil {
+		return ProcessSnapshot{}, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// reuse Process objects so Percent(0) measures since the last sample
+	alive := make(map[int32]*process.Process, len(list))
+	all := make([]ProcessStats, 0, len(list))
+	for _, p := range list {
+		if cached, ok := t.cache[p.Pid]; ok {
+			p = cached
+		}
+		alive[p.Pid] = p
+
+		stats, err := readProcessStats(p)
+		if err != nil {
+			continue
+		}
+		all = append(all, stats)
+	}
+	t.cache = alive
+
+	// open files and threads are only read for the processes that are reported
+	read := make(map[int32]ProcessStats)
+	details := func(s *ProcessStats) {
+		if r, ok := read[s.PID]; ok {
+			s.OpenFDs, s.Threads = r.OpenFDs, r.Threads
+			return
+		}
+		p, ok := alive[s.PID]
+		if !ok {
+			return
+		}
+		if fds, err := p.NumFDs(); err == nil {
+			s.OpenFDs = fds
+		}
+		if threads, err := p.NumThreads(); err == nil {
+			s.Threads = threads
+		}
+		read[s.PID] = *s
+	}
+
+	t.last = t.update(all, time.Now(), details)
+	return t.last, nil
+}
+
+// update builds a snapshot from the stats of every process, tracking the main process and restarts
+// of each watched process. details fills in the open files and threads of a reported process.
+func (t *processTracker) update(all []ProcessStats, now time.Time, details func(*ProcessStats)) ProcessSnapshot {
+	snapshot := ProcessSnapshot{SampledAt: now}
+	for _, s := range all {
+		if s.status == "D" {
+			snapshot.ProcsUSleep++
+			snapshot.uSleep = append(snapshot.uSleep, s.Name)
+		}
+	}
+
+	for _, w := range t.cfg.Watch {
+		ws := WatchedProcessStats{Name: w.Name, Processes: []ProcessStats{}}
+		prev, seen := t.mains[w.Name]
+
+		var main *ProcessStats
+		for i := range all {
+			s := all[i]
+			if !w.matches(s.Name, s.Cmdline) {
+				continue
+			}
+
+			details(&s)
+			ws.Processes = append(ws.Processes, s)
+			ws.RSS += s.RSS
+			ws.CPUPercent = round(ws.CPUPercent+s.CPUPercent, .01)
+			ws.OpenFDs += s.OpenFDs
+			ws.Threads += s.Threads
+
+			if main == nil || s.CreateTime < main.CreateTime {
+				main = &all[i]
+			}
+		}
+		ws.Running = main != nil
+
+		if ws.Running {
+			stillRunning := false
+			restarted := seen
+			for _, s := range ws.Processes {
+				if s.PID == prev.PID && s.CreateTime == prev.CreateTime {
+					stillRunning = true
+				}
+				// a process that was running at the last sample means this isn't a restart
+				if s.CreateTime <= t.last.SampledAt.UnixMilli() {
+					restarted = false
+				}
+			}
+
+			switch {
+			case stillRunning:
+				main = &prev
+			case restarted:
+				t.restarts[w.Name]++
+				slog.Info("Watched process restarted", slog.String("name", w.Name), slog.Int("old_pid", int(prev.PID)), slog.Int("new_pid", int(main.PID)))
+			}
+			ws.MainPID = main.PID
+			t.mains[w.Name] = *main
+		}
+		ws.Restarts = t.restarts[w.Name]
+
+		snapshot.Watched = append(snapshot.Watched, ws)
+	}
+
+	n := t.cfg.TopN
+	if n <= 0 {
+		n = defaultTopN
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].RSS > all[j].RSS })
+	snapshot.TopMemory = topProcesses(all, n, details)
+
+	sort.Slice(all, func(i, j int) bool { return all[i].CPUPercent > all[j].CPUPercent })
+	snapshot.TopCPU = topProcesses(all, n, details)
+
+	return snapshot
+}
+
+func topProcesses(sorted []ProcessStats, n int, details func(*ProcessStats)) []ProcessStats {
+	top := append([]ProcessStats{}, sorted[:min(n, len(sorted))]...)
+	for i := range top {
+		details(&top[i])
+	}
+	return top
+}
+
+func readProcessStats(p *process.Process) (ProcessStats, error) {
+	name, err := p.Name()
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	stats := ProcessStats{PID: p.Pid, Name: name}
+	stats.Cmdline, _ = p.Cmdline()
+	stats.CreateTime, _ = p.CreateTime()
+	stats.status, _ = p.Status()
+
+	if mem, err := p.MemoryInfo(); err == nil {
+		stats.RSS = mem.RSS
+	}
+	if cpu, err := p.Percent(0); err == nil {
+		stats.CPUPercent = round(cpu, .01)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,119 @@
+package localsystem
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func noDetails(*ProcessStats) {}
+
+func newTestTracker(cfg ProcessConfig) *processTracker {
+	for i := range cfg.Watch {
+		if cfg.Watch[i].Cmdline != "" {
+			cfg.Watch[i].cmdline = regexp.MustCompile(cfg.Watch[i].Cmdline)
+		}
+	}
+	return &processTracker{
+		cfg:      cfg,
+		mains:    make(map[string]ProcessStats),
+		restarts: make(map[string]int),
+	}
+}
+
+func TestProcessRestarts(t *testing.T) {
+	tracker := newTestTracker(ProcessConfig{Watch: []WatchedProcess{{Name: "browser", Process: "chromium"}}})
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 { return start.Add(d).UnixMilli() }
+
+	main := ProcessStats{PID: 100, Name: "chromium", CreateTime: at(-time.Hour)}
+	helper := ProcessStats{PID: 200, Name: "chromium", CreateTime: at(-2 * time.Hour)} // older than main, e.g. a zygote
+	child := ProcessStats{PID: 300, Name: "chromium", CreateTime: at(2 * time.Second)}
+
+	steps := []struct {
+		name         string
+		procs        []ProcessStats
+		wantRunning  bool
+		wantMain     int32
+		wantRestarts int
+	}{
+		{name: "first sample", procs: []ProcessStats{main, helper}, wantRunning: true, wantMain: 200},
+		{name: "child started", procs: []ProcessStats{main, helper, child}, wantRunning: true, wantMain: 200},
+		{name: "oldest helper exited", procs: []ProcessStats{main, child}, wantRunning: true, wantMain: 100},
+		{name: "child exited", procs: []ProcessStats{main}, wantRunning: true, wantMain: 100},
+		{name: "restarted", procs: []ProcessStats{
+			{PID: 400, Name: "chromium", CreateTime: at(18 * time.Second)},
+			{PID: 401, Name: "chromium", CreateTime: at(19 * time.Second)},
+		}, wantRunning: true, wantMain: 400, wantRestarts: 1},
+		{name: "stopped", wantRunning: false, wantRestarts: 1},
+		{name: "started again", procs: []ProcessStats{
+			{PID: 500, Name: "chromium", CreateTime: at(27 * time.Second)},
+		}, wantRunning: true, wantMain: 500, wantRestarts: 2},
+	}
+
+	for i, step := range steps {
+		all := append([]ProcessStats{{PID: 1, Name: "systemd", CreateTime: at(-24 * time.Hour)}}, step.procs...)
+		snapshot := tracker.update(all, start.Add(time.Duration(i)*5*time.Second+time.Second), noDetails)
+		tracker.last = snapshot
+
+		ws := snapshot.Watched[0]
+		if ws.Running != step.wantRunning || ws.MainPID != step.wantMain || ws.Restarts != step.wantRestarts {
+			t.Fatalf("%s: expected running=%v main=%d restarts=%d, got running=%v main=%d restarts=%d",
+				step.name, step.wantRunning, step.wantMain, step.wantRestarts, ws.Running, ws.MainPID, ws.Restarts)
+		}
+		if len(ws.Processes) != len(step.procs) {
+			t.Errorf("%s: expected %d processes, got %d", step.name, len(step.procs), len(ws.Processes))
+		}
+	}
+}
+
+func TestProcessSnapshot(t *testing.T) {
+	tracker := newTestTracker(ProcessConfig{
+		Watch: []WatchedProcess{{Name: "control-api", Cmdline: "av-api"}},
+		TopN:  2,
+	})
+
+	all := []ProcessStats{
+		{PID: 1, Name: "systemd", RSS: 10 << 20, CPUPercent: 0.1},
+		{PID: 10, Name: "av-api", Cmdline: "/usr/bin/av-api --port 8000", RSS: 40 << 20, CPUPercent: 12.5},
+		{PID: 11, Name: "av-api", Cmdline: "/usr/bin/av-api --worker", RSS: 20 << 20, CPUPercent: 2.25},
+		{PID: 20, Name: "chromium", RSS: 300 << 20, CPUPercent: 5, status: "D"},
+		{PID: 30, Name: "kworker", CPUPercent: 30, status: "D"},
+	}
+
+	calls := 0
+	details := func(s *ProcessStats) {
+		calls++
+		s.OpenFDs, s.Threads = 3, 2
+	}
+	snapshot := tracker.update(all, time.Now(), details)
+
+	if snapshot.ProcsUSleep != 2 {
+		t.Errorf("expected 2 processes in uninterruptible sleep, got %d", snapshot.ProcsUSleep)
+	}
+	if want := []string{"chromium", "kworker"}; !slices.Equal(snapshot.uSleep, want) {
+		t.Errorf("expected %v in uninterruptible sleep, got %v", want, snapshot.uSleep)
+	}
+
+	ws := snapshot.Watched[0]
+	if ws.RSS != 60<<20 || ws.CPUPercent != 14.75 || ws.OpenFDs != 6 || ws.Threads != 4 || len(ws.Processes) != 2 {
+		t.Errorf("unexpected totals for the watched process: %+v", ws)
+	}
+
+	if len(snapshot.TopMemory) != 2 || snapshot.TopMemory[0].PID != 20 || snapshot.TopMemory[1].PID != 10 {
+		t.Errorf("unexpected top memory: %+v", snapshot.TopMemory)
+	}
+	if len(snapshot.TopCPU) != 2 || snapshot.TopCPU[0].PID != 30 || snapshot.TopCPU[1].PID != 10 {
+		t.Errorf("unexpected top cpu: %+v", snapshot.TopCPU)
+	}
+	if snapshot.TopCPU[0].OpenFDs != 3 {
+		t.Errorf("expected details for the top processes, got %+v", snapshot.TopCPU[0])
+	}
+
+	// only the 2 watched and 4 top processes are read in detail, not systemd
+	if calls != 6 {
+		t.Errorf("expected details to be read 6 times, got %d", calls)
+	}
+}
@@ -0,0 +1,200 @@
+package localsystem
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	procNetRoute     = "/proc/net/route"
+	procNetIPv6Route = "/proc/net/ipv6_route"
+
+	rtfUp      = 0x1
+	rtfGateway = 0x2
+	rtfReject  = 0x200
+)
+
+// Route is a route from /proc/net/route or /proc/net/ipv6_route.
+type Route struct {
+	Interface   string     `json:"interface"`
+	Destination *net.IPNet `json:"-"`
+	Gateway     net.IP     `json:"gateway,omitempty"`
+	Metric      int        `json:"metric"`
+	Flags       int        `json:"-"`
+}
+
+// Default returns true if r is an active default route.
+func (r Route) Default() bool {
+	ones, _ := r.Destination.Mask.Size()
+	return r.Flags&rtfUp != 0 && r.Flags&rtfReject == 0 && r.Destination.IP.IsUnspecified() && ones == 0
+}
+
+// defaultRoute returns the default IPv4 route with a gateway, picking the lowest metric if there's more than one.
+func defaultRoute() (Route, error) {
+	routes, err := readRoutes(procNetRoute, parseRoutes)
+	if err != nil {
+		return Route{}, err
+	}
+
+	r, ok := defaultRouteOf(routes, "")
+	if !ok {
+		return Route{}, fmt.Errorf("no default route found")
+	}
+	return r, nil
+}
+
+// defaultRouteOf returns the default route with a gateway and the lowest metric, only considering
+// the routes of iface if it's set.
+func defaultRouteOf(routes []Route, iface string) (Route, bool) {
+	var best *Route
+	for i, r := range routes {
+		if iface != "" && r.Interface != iface {
+			continue
+		}
+		if r.Default() && r.Gateway != nil && (best == nil || r.Metric < best.Metric) {
+			best = &routes[i]
+		}
+	}
+	if best == nil {
+		return Route{}, false
+	}
+	return *best, true
+}
+
+// readRoutes parses the routes in path. A missing file (e.g. ipv6_route with IPv6 disabled) has no routes.
+func readRoutes(path string, parse func(io.Reader) ([]Route, error)) ([]Route, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	defer f.Close()
+
+	return parse(f)
+}
+
+// parseRoutes parses /proc/net/route, which looks like:
+//
+//	Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+//	eth0	00000000	0100A8C0	0003	0	0	100	00000000	0	0	0
+//	eth0	0000A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+//
+// Addresses are in host byte order, which is little endian on everything this runs on.
+func parseRoutes(r io.Reader) ([]Route, error) {
+	var routes []Route
+	scanner := bufio.NewScanner(r)
+	for first := true; scanner.Scan(); first = false {
+		fields := strings.Fields(scanner.Text())
+		if first || len(fields) < 8 {
+			continue
+		}
+
+		dst, err := parseRouteAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		gw, err := parseRouteAddr(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		mask, err := parseRouteAddr(fields[7])
+		if err != nil {
+			return nil, err
+		}
+		flags, err := strconv.ParseInt(fields[3], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route flags %q", fields[3])
+		}
+		metric, _ := strconv.Atoi(fields[6])
+
+		route := Route{
+			Interface:   fields[0],
+			Destination: &net.IPNet{IP: dst, Mask: net.IPMask(mask)},
+			Metric:      metric,
+			Flags:       int(flags),
+		}
+		if flags&rtfGateway != 0 {
+			route.Gateway = gw
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	return routes, nil
+}
+
+func parseRouteAddr(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route address %q", s)
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.LittleEndian.PutUint32(ip, uint32(v))
+	return ip, nil
+}
+
+// parseIPv6Routes parses /proc/net/ipv6_route, which has no header and looks like:
+//
+//	00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+//	20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+//
+// The fields are the destination and its prefix length, the source and its prefix length, the
+// next hop, the metric, the reference and use counts, the flags and the interface.
+func parseIPv6Routes(r io.Reader) ([]Route, error) {
+	var routes []Route
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		dst, err := hex.DecodeString(fields[0])
+		if err != nil || len(dst) != net.IPv6len {
+			return nil, fmt.Errorf("invalid route destination %q", fields[0])
+		}
+		prefix, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil || prefix > 128 {
+			return nil, fmt.Errorf("invalid route prefix length %q", fields[1])
+		}
+		next, err := hex.DecodeString(fields[4])
+		if err != nil || len(next) != net.IPv6len {
+			return nil, fmt.Errorf("invalid route next hop %q", fields[4])
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route metric %q", fields[5])
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route flags %q", fields[8])
+		}
+
+		route := Route{
+			Interface:   fields[9],
+			Destination: &net.IPNet{IP: net.IP(dst), Mask: net.CIDRMask(int(prefix), 128)},
+			Metric:      int(metric),
+			Flags:       int(flags),
+		}
+		if flags&rtfGateway != 0 && !net.IP(next).IsUnspecified() {
+			route.Gateway = net.IP(next)
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	return routes, nil
+}
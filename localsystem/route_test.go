@@ -0,0 +1,120 @@
+package localsystem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	procRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+docker0	000011AC	00000000	0001	0	0	0	0000FFFF	0	0	0
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	0100050A	0003	0	0	100	00000000	0	0	0
+eth0	0000050A	00000000	0001	0	0	100	00FEFFFF	0	0	0
+`
+	procIPv6Route = `20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+`
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes(strings.NewReader(procRoute))
+	if err != nil {
+		t.Fatalf("failed to parse routes: %s", err)
+	}
+	if len(routes) != 4 {
+		t.Fatalf("expected 4 routes, got %d", len(routes))
+	}
+
+	if got := routes[3].Destination.String(); got != "10.5.0.0/23" {
+		t.Errorf("expected the eth0 subnet to be 10.5.0.0/23, got %s", got)
+	}
+
+	r, ok := defaultRouteOf(routes, "")
+	if !ok || r.Interface != "eth0" || r.Gateway.String() != "10.5.0.1" {
+		t.Errorf("expected the default route with the lowest metric to be through eth0, got %+v", r)
+	}
+	r, ok = defaultRouteOf(routes, "wlan0")
+	if !ok || r.Gateway.String() != "192.168.1.1" {
+		t.Errorf("expected the wlan0 gateway to be 192.168.1.1, got %+v", r)
+	}
+	if _, ok := defaultRouteOf(routes, "docker0"); ok {
+		t.Errorf("expected docker0 to not have a default route")
+	}
+}
+
+func TestParseIPv6Routes(t *testing.T) {
+	routes, err := parseIPv6Routes(strings.NewReader(procIPv6Route))
+	if err != nil {
+		t.Fatalf("failed to parse routes: %s", err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+
+	if got := routes[0].Destination.String(); got != "2001:db8::/64" {
+		t.Errorf("expected 2001:db8::/64, got %s", got)
+	}
+
+	r, ok := defaultRouteOf(routes, "")
+	if !ok || r.Interface != "eth0" || r.Gateway.String() != "fe80::1" {
+		t.Errorf("expected the default route to be through fe80::1 on eth0, got %+v", r)
+	}
+	if _, ok := defaultRouteOf(routes, "lo"); ok {
+		t.Errorf("expected the unreachable route on lo to not be a default route")
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	servers, err := parseResolvConf(strings.NewReader("# Generated by resolvconf\nsearch byu.edu\nnameserver 10.8.0.26\nnameserver 2001:db8::53\n"))
+	if err != nil {
+		t.Fatalf("failed to parse resolv.conf: %s", err)
+	}
+	if strings.Join(servers, ",") != "10.8.0.26,2001:db8::53" {
+		t.Errorf("unexpected nameservers %q", servers)
+	}
+}
+
+func TestInterfaceDNS(t *testing.T) {
+	resolvConf := func() ([]string, error) { return []string{"10.8.0.26"}, nil }
+	m := &fakeNetworkManager{dns: map[string][]string{
+		"eth0":  {"10.8.0.19"},
+		"wlan0": {},
+	}}
+
+	tests := []struct {
+		name    string
+		m       NetworkManager
+		iface   Interface
+		want    string
+		resolvs func() ([]string, error)
+	}{
+		{name: "from the network manager", m: m, iface: Interface{Name: "eth0", Up: true, Primary: true}, want: "10.8.0.19"},
+		{name: "none from the network manager", m: m, iface: Interface{Name: "wlan0", Up: true, Primary: true}, want: "10.8.0.26"},
+		{name: "network manager error", m: m, iface: Interface{Name: "usb0", Up: true, Primary: true}, want: "10.8.0.26"},
+		{name: "no network manager", iface: Interface{Name: "eth0", Up: true, Primary: true}, want: "10.8.0.26"},
+		{name: "not the primary interface", m: m, iface: Interface{Name: "usb0", Up: true}, want: ""},
+		{name: "down", m: m, iface: Interface{Name: "eth0"}, want: ""},
+		{
+			name:    "no resolv.conf",
+			iface:   Interface{Name: "eth0", Up: true, Primary: true},
+			resolvs: func() ([]string, error) { return nil, errors.New("no such file or directory") },
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := resolvConf
+			if tt.resolvs != nil {
+				read = tt.resolvs
+			}
+			if got := strings.Join(interfaceDNS(context.Background(), tt.m, tt.iface, read), ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package localsystem
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+const (
+	// DefaultSampleInterval is how often the sampler collects metrics if no interval is configured.
+	DefaultSampleInterval = 5 * time.Second
+	// MinSampleInterval is the shortest sample interval that can be configured.
+	MinSampleInterval = time.Second
+
+	longestWindow = 15 * time.Minute
+)
+
+// MetricsConfig configures the background metrics sampler.
+type MetricsConfig struct {
+	Interval string `json:"interval,omitempty"`
+}
+
+// Window is the average and maximum of a metric over a period of time.
+type Window struct {
+	Avg     float64 `json:"avg"`
+	Max     float64 `json:"max"`
+	Samples int     `json:"samples"`
+}
+
+// MetricSummary is the latest value of a metric along with its rolling windows.
+type MetricSummary struct {
+	Current        float64   `json:"current"`
+	SampledAt      time.Time `json:"sampled-at"`
+	OneMinute      Window    `json:"1m"`
+	FiveMinutes    Window    `json:"5m"`
+	FifteenMinutes Window    `json:"15m"`
+}
+
+type point struct {
+	at    time.Time
+	value float64
+}
+
+// series is a fixed-size ring buffer of samples for one metric.
+type series struct {
+	points []point
+	next   int
+	full   bool
+}
+
+func newSeries(size int) *series {
+	return &series{points: make([]point, size)}
+}
+
+func (s *series) add(p point) {
+	s.points[s.next] = p
+	s.next = (s.next + 1) % len(s.points)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+func (s *series) latest() point {
+	i := s.next - 1
+	if i < 0 {
+		i = len(s.points) - 1
+	}
+	return s.points[i]
+}
+
+func (s *series) summary(now time.Time) MetricSummary {
+	latest := s.latest()
+	return MetricSummary{
+		Current:        latest.value,
+		SampledAt:      latest.at,
+		OneMinute:      s.window(now.Add(-1 * time.Minute)),
+		FiveMinutes:    s.window(now.Add(-5 * time.Minute)),
+		FifteenMinutes: s.window(now.Add(-15 * time.Minute)),
+	}
+}
+
+func (s *series) window(since time.Time) Window {
+	n := s.next
+	if s.full {
+		n = len(s.points)
+	}
+
+	var w Window
+	var sum float64
+	for i := 0; i < n; i++ {
+		p := s.points[i]
+		if p.at.Before(since) {
+			continue
+		}
+		if w.Samples == 0 || p.value > w.Max {
+			w.Max = p.value
+		}
+		sum += p.value
+		w.Samples++
+	}
+
+	if w.Samples > 0 {
+		w.Avg = round(sum/float64(w.Samples), .01)
+	}
+	return w
+}
+
+// Sampler collects system metrics at a fixed interval into in-memory ring buffers.
+type Sampler struct {
+	interval time.Duration
+	size     int
+
+	mu     sync.RWMutex
+	series map[string]*series
+	perCPU []float64
+
+	prevCounters   map[string]uint64
+	prevCountersAt time.Time
+}
+
+var (
+	samplerOnce sync.Once
+	sampler     *Sampler
+)
+
+// StartSampler starts the background metrics sampler. Only the first call has any effect.
+func StartSampler(ctx context.Context, interval time.Duration) *Sampler {
+	samplerOnce.Do(func() {
+		if interval < MinSampleInterval {
+			interval = DefaultSampleInterval
+		}
+
+		sampler = &Sampler{
+			interval: interval,
+			size:     int(longestWindow/interval) + 1,
+			series:   make(map[string]*series),
+		}
+
+		slog.Info("Starting metrics sampler", slog.String("interval", interval.String()))
+		go sampler.run(ctx)
+	})
+
+	return sampler
+}
+
+// GetSampler returns the running sampler, or nil if StartSampler hasn't been called.
+func GetSampler() *Sampler {
+	return sampler
+}
+
+// ParseSampleInterval parses the configured sample interval, falling back to DefaultSampleInterval
+// if it isn't set or is invalid. It must be at least MinSampleInterval.
+func (c MetricsConfig) ParseSampleInterval() (time.Duration, error) {
+	if c.Interval == "" {
+		return DefaultSampleInterval, nil
+	}
+
+	d, err := time.ParseDuration(c.Interval)
+	switch {
+	case err != nil:
+		return DefaultSampleInterval, fmt.Errorf("invalid sample interval %q: %w", c.Interval, err)
+	case d < MinSampleInterval:
+		return DefaultSampleInterval, fmt.Errorf("sample interval %q is shorter than %s", c.Interval, MinSampleInterval)
+	}
+	return d, nil
+}
+
+// Interval returns how often the sampler collects metrics.
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *Sampler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *Sampler) sample() {
+	now := time.Now()
+	values := make(map[string]float64)
+
+	perCPU, err := cpu.Percent(0, true)
+	if err != nil {
+		slog.Warn("sampler: failed to get per-CPU usage", slog.Any("error", err))
+	}
+
+	if avg, err := cpu.Percent(0, false); err != nil {
+		slog.Warn("sampler: failed to get CPU usage", slog.Any("error", err))
+	} else if len(avg) > 0 {
+		values["cpu-usage-percent"] = round(avg[0], .01)
+	}
+
+	if vMem, err := mem.VirtualMemory(); err != nil {
+		slog.Warn("sampler: failed to get virtual memory", slog.Any("error", err))
+	} else {
+		values["v-mem-used-percent"] = round(vMem.UsedPercent, .01)
+	}
+
+	if sMem, err := mem.SwapMemory(); err != nil {
+		slog.Warn("sampler: failed to get swap memory", slog.Any("error", err))
+	} else {
+		values["s-mem-used-percent"] = round(sMem.UsedPercent, .01)
+	}
+
+	if avg, err := load.Avg(); err != nil {
+		slog.Warn("sampler: failed to get load average", slog.Any("error", err))
+	} else {
+		values["load"] = avg.Load1
+	}
+
+	for chip, temp := range Temperatures() {
+		values[chip+"-temp"] = temp
+	}
+
+	if snapshot, err := procs.sample(); err != nil {
+		slog.Warn("sampler: failed to sample processes", slog.Any("error", err))
+	} else {
+		values["procs-u-sleep"] = float64(snapshot.ProcsUSleep)
+		for _, w := range snapshot.Watched {
+			values["process-"+w.Name+"-rss-mb"] = round(float64(w.RSS)/(1<<20), .01)
+			values["process-"+w.Name+"-cpu-percent"] = w.CPUPercent
+		}
+	}
+
+	diskCfg := GetDiskConfig()
+	if mounts, err := Mounts(diskCfg); err != nil {
+		slog.Warn("sampler: failed to get disk mounts", slog.Any("error", err))
+	} else {
+		for _, m := range mounts {
+			if m.Error != "" {
+				continue
+			}
+			values["disk-used-percent-"+MountKey(m.Mountpoint)] = m.UsedPercent
+			values["inodes-used-percent-"+MountKey(m.Mountpoint)] = m.InodesUsedPercent
+		}
+	}
+
+	devices := diskCfg.Devices
+	if len(devices) == 0 {
+		devices = blockDevices()
+	}
+
+	// cumulative counters, keyed by the name of the rate metric they're turned into
+	counters := make(map[string]uint64)
+	if io, err := disk.IOCounters(devices...); err != nil {
+		slog.Warn("sampler: failed to get disk IO counters", slog.Any("error", err))
+	} else {
+		for name, c := range io {
+			counters["disk-reads-per-second-"+name] = c.ReadCount
+			counters["disk-writes-per-second-"+name] = c.WriteCount
+			counters["disk-read-bytes-per-second-"+name] = c.ReadBytes
+			counters["disk-write-bytes-per-second-"+name] = c.WriteBytes
+		}
+	}
+
+	if stats, err := readInterfaceStats(); err != nil {
+		slog.Warn("sampler: failed to get network interface stats", slog.Any("error", err))
+	} else {
+		for name, st := range stats {
+			counters["net-rx-bytes-per-second-"+name] = st.RxBytes
+			counters["net-tx-bytes-per-second-"+name] = st.TxBytes
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, rate := range counterRates(s.prevCounters, counters, now.Sub(s.prevCountersAt).Seconds()) {
+		values[name] = rate
+	}
+	s.prevCounters = counters
+	s.prevCountersAt = now
+
+	if perCPU != nil {
+		s.perCPU = perCPU
+	}
+
+	for name, v := range values {
+		ser, ok := s.series[name]
+		if !ok {
+			ser = newSeries(s.size)
+			s.series[name] = ser
+		}
+		ser.add(point{at: now, value: v})
+	}
+}
+
+// counterRates returns the per-second rate of each counter in cur that was also in prev,
+// elapsed seconds before.
+func counterRates(prev, cur map[string]uint64, elapsed float64) map[string]float64 {
+	rates := make(map[string]float64, len(cur))
+	if elapsed <= 0 {
+		return rates
+	}
+	for name, c := range cur {
+		if p, ok := prev[name]; ok {
+			rates[name] = round(counterRate(p, c, elapsed), .01)
+		}
+	}
+	return rates
+}
+
+// sampledRate returns the latest value of a rate metric, or 0 if the sampler hasn't measured it
+// recently.
+func sampledRate(name string) float64 {
+	s := GetSampler()
+	if s == nil {
+		return 0
+	}
+	summary, ok := s.Summary(name)
+	if !ok || time.Since(summary.SampledAt) > 2*s.interval {
+		return 0
+	}
+	return summary.Current
+}
+
+// Summaries returns the current value and 1m/5m/15m windows for every sampled metric.
+func (s *Sampler) Summaries() map[string]MetricSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	ret := make(map[string]MetricSummary, len(s.series))
+	for name, ser := range s.series {
+		ret[name] = ser.summary(now)
+	}
+
+	return ret
+}
+
+// Summary returns the summary for a single metric.
+func (s *Sampler) Summary(name string) (MetricSummary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser, ok := s.series[name]
+	if !ok {
+		return MetricSummary{}, false
+	}
+	return ser.summary(time.Now()), true
+}
+
+// PerCPU returns the per-CPU usage measured over the last sample interval.
+func (s *Sampler) PerCPU() []float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ret := make([]float64, len(s.perCPU))
+	copy(ret, s.perCPU)
+	return ret
+}
@@ -0,0 +1,142 @@
+package localsystem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterRates(t *testing.T) {
+	prev := map[string]uint64{
+		"disk-read-bytes-per-second-sda":  1000,
+		"disk-write-bytes-per-second-sda": 5000,
+		"disk-reads-per-second-sdb":       10,
+	}
+	cur := map[string]uint64{
+		"disk-read-bytes-per-second-sda":  6000,
+		"disk-write-bytes-per-second-sda": 4000, // reset
+		"disk-reads-per-second-mmcblk0":   7,    // new device
+	}
+
+	rates := counterRates(prev, cur, 5)
+	if len(rates) != 2 {
+		t.Fatalf("expected 2 rates, got %v", rates)
+	}
+	if got := rates["disk-read-bytes-per-second-sda"]; got != 1000 {
+		t.Errorf("expected a read rate of 1000, got %v", got)
+	}
+	if got := rates["disk-write-bytes-per-second-sda"]; got != 0 {
+		t.Errorf("expected a counter that went backwards to give 0, got %v", got)
+	}
+	if _, ok := rates["disk-reads-per-second-mmcblk0"]; ok {
+		t.Errorf("expected no rate for a counter without a previous reading")
+	}
+
+	if rates := counterRates(prev, cur, 0); len(rates) != 0 {
+		t.Errorf("expected no rates when no time has passed, got %v", rates)
+	}
+	if rates := counterRates(nil, cur, 5); len(rates) != 0 {
+		t.Errorf("expected no rates on the first sample, got %v", rates)
+	}
+}
+
+func TestParseSampleInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     time.Duration
+		err      bool
+	}{
+		{interval: "", want: DefaultSampleInterval},
+		{interval: "10s", want: 10 * time.Second},
+		{interval: "1s", want: time.Second},
+		{interval: "999ms", want: DefaultSampleInterval, err: true},
+		{interval: "1ms", want: DefaultSampleInterval, err: true},
+		{interval: "0s", want: DefaultSampleInterval, err: true},
+		{interval: "-5s", want: DefaultSampleInterval, err: true},
+		{interval: "often", want: DefaultSampleInterval, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := MetricsConfig{Interval: tt.interval}.ParseSampleInterval()
+		if (err != nil) != tt.err {
+			t.Errorf("%q: expected error %v, got %v", tt.interval, tt.err, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.interval, tt.want, got)
+		}
+	}
+}
+
+func TestSeriesWindows(t *testing.T) {
+	now := time.Now()
+	s := newSeries(4)
+	for _, p := range []point{
+		{at: now.Add(-20 * time.Minute), value: 100}, // overwritten when the ring wraps
+		{at: now.Add(-10 * time.Minute), value: 90},
+		{at: now.Add(-3 * time.Minute), value: 30},
+		{at: now.Add(-30 * time.Second), value: 10},
+		{at: now, value: 20},
+	} {
+		s.add(p)
+	}
+
+	sum := s.summary(now)
+	if sum.Current != 20 || !sum.SampledAt.Equal(now) {
+		t.Errorf("expected the latest sample to be current, got %v at %s", sum.Current, sum.SampledAt)
+	}
+
+	tests := []struct {
+		name string
+		got  Window
+		want Window
+	}{
+		{name: "1m", got: sum.OneMinute, want: Window{Avg: 15, Max: 20, Samples: 2}},
+		{name: "5m", got: sum.FiveMinutes, want: Window{Avg: 20, Max: 30, Samples: 3}},
+		{name: "15m", got: sum.FifteenMinutes, want: Window{Avg: 37.5, Max: 90, Samples: 4}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestSeriesWindowEmpty(t *testing.T) {
+	now := time.Now()
+	s := newSeries(4)
+	s.add(point{at: now.Add(-2 * time.Minute), value: -5})
+
+	if w := s.window(now.Add(-time.Minute)); w != (Window{}) {
+		t.Errorf("expected an empty window, got %+v", w)
+	}
+	if w := s.window(now.Add(-5 * time.Minute)); w != (Window{Avg: -5, Max: -5, Samples: 1}) {
+		t.Errorf("expected a negative value to be the max, got %+v", w)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	s := &Sampler{size: 8, series: make(map[string]*series)}
+	now := time.Now()
+	for name, values := range map[string][]float64{
+		"cpu-usage-percent":  {10, 50},
+		"v-mem-used-percent": {40},
+	} {
+		s.series[name] = newSeries(s.size)
+		for i, v := range values {
+			s.series[name].add(point{at: now.Add(time.Duration(i-len(values)+1) * 10 * time.Second), value: v})
+		}
+	}
+
+	sums := s.Summaries()
+	if len(sums) != 2 {
+		t.Fatalf("expected 2 summaries, got %v", sums)
+	}
+	if got := sums["cpu-usage-percent"]; got.Current != 50 || got.OneMinute != (Window{Avg: 30, Max: 50, Samples: 2}) {
+		t.Errorf("unexpected cpu summary: %+v", got)
+	}
+	if got, ok := s.Summary("v-mem-used-percent"); !ok || got.Current != 40 || got.FifteenMinutes.Samples != 1 {
+		t.Errorf("unexpected memory summary: %+v", got)
+	}
+	if _, ok := s.Summary("load"); ok {
+		t.Errorf("expected no summary for a metric that wasn't sampled")
+	}
+}
@@ -15,6 +15,16 @@ type HardwareInfo struct {
 	PowerSavingModeStatus string           `json:"power_saving_mode_status,omitempty"`
 	TimerInfo             []map[string]int `json:"timer_information,omitempty"`
 	Temperature           string           `json:"temperature,omitempty"`
+	Description           string           `json:"description,omitempty"`
+	Uptime                string           `json:"uptime,omitempty"`
+	Interfaces            []InterfaceState `json:"interfaces,omitempty"`
+}
+
+// InterfaceState is the status of one of a device's network interfaces.
+type InterfaceState struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
 }
 
 type NetworkInfo struct {
@@ -3,15 +3,21 @@ package main
 import (
 	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/byuoitav/auth/wso2"
 	"github.com/byuoitav/device-monitoring/actions"
+	"github.com/byuoitav/device-monitoring/actions/alerts"
+	"github.com/byuoitav/device-monitoring/actions/hardwareinfo"
 	"github.com/byuoitav/device-monitoring/couchdb"
 	"github.com/byuoitav/device-monitoring/handlers"
+	"github.com/byuoitav/device-monitoring/localsystem"
 	"github.com/byuoitav/device-monitoring/messenger"
 	"github.com/byuoitav/device-monitoring/model"
 	"github.com/gin-gonic/gin"
@@ -19,11 +25,15 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/byuoitav/device-monitoring/actions/gpio"
+	"github.com/byuoitav/device-monitoring/actions/screenshot"
 	"github.com/lmittmann/tint"
 
 	_ "github.com/byuoitav/device-monitoring/actions/then"
 )
 
+// dividerReloadInterval is how often the divider pins are re-read from couch.
+const dividerReloadInterval = 5 * time.Minute
+
 func main() {
 	// ===========================
 	// Flags (PRD required; STG optional)
@@ -61,6 +71,10 @@ func main() {
 	slog.SetDefault(slog.New(handler))
 	slog.Info("Starting device-monitoring server")
 
+	// cancelled on SIGINT/SIGTERM, stopping everything started with it
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// ===========================
 	// External deps checks
 	// ===========================
@@ -104,20 +118,97 @@ func main() {
 	// Keep legacy global WSO2 client (PRD) for other handlers that still reference handlers.WSO2Client
 	handlers.WSO2Client = *wso2.New(*prdClientID, *prdClientSecret, *prdGatewayURL, "device-monitoring")
 
-	pins, err := handlers.LoadPinsFromJSON(couchdb.GetMonitoringConfig(ctx, ""))
+	monitoringCfg, cfgErr := couchdb.GetMonitoringConfig(ctx, "")
+
+	var diskCfg localsystem.DiskConfig
+	if ok, err := couchdb.DecodeMonitoringSection(monitoringCfg, "disk", &diskCfg); err != nil {
+		slog.Warn("Failed to load disk config, discovering disks automatically", slog.Any("error", err))
+	} else if ok {
+		localsystem.SetDiskConfig(diskCfg)
+	}
+
+	var maintenanceCfg hardwareinfo.MaintenanceConfig
+	if ok, err := couchdb.DecodeMonitoringSection(monitoringCfg, "maintenance", &maintenanceCfg); err != nil {
+		slog.Warn("Failed to load maintenance config", slog.Any("error", err))
+	} else if ok {
+		hardwareinfo.SetMaintenanceConfig(maintenanceCfg)
+	}
+
+	var snmpCfg hardwareinfo.SNMPConfig
+	if ok, err := couchdb.DecodeMonitoringSection(monitoringCfg, "snmp", &snmpCfg); err != nil {
+		slog.Warn("Failed to load snmp config", slog.Any("error", err))
+	} else if ok {
+		if err := hardwareinfo.SetSNMPConfig(snmpCfg); err != nil {
+			slog.Warn("Invalid snmp config", slog.Any("error", err))
+		}
+	}
+
+	var procCfg localsystem.ProcessConfig
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "processes", &procCfg); err != nil {
+		slog.Warn("Failed to load process watch list", slog.Any("error", err))
+	} else if err := localsystem.SetProcessConfig(procCfg); err != nil {
+		slog.Warn("Invalid process watch list", slog.Any("error", err))
+	}
+
+	var metricsCfg localsystem.MetricsConfig
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "metrics", &metricsCfg); err != nil {
+		slog.Warn("Failed to load metrics config, using defaults", slog.Any("error", err))
+	}
+	interval, err := metricsCfg.ParseSampleInterval()
+	if err != nil {
+		slog.Warn("Invalid metrics sample interval, using default", slog.Any("error", err))
+	}
+	sampler := localsystem.StartSampler(runCtx, interval)
+
+	var thresholds []alerts.Threshold
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "thresholds", &thresholds); err != nil {
+		slog.Warn("Failed to load alert thresholds", slog.Any("error", err))
+	}
+	if _, err := alerts.Start(runCtx, sampler, thresholds); err != nil {
+		slog.Error("Failed to start alert evaluator", slog.Any("error", err))
+	}
+
+	var screenshotCfg screenshot.Config
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "screenshot", &screenshotCfg); err != nil {
+		slog.Warn("Failed to load screenshot config", slog.Any("error", err))
+	}
+	if err := screenshot.Configure(screenshotCfg); err != nil {
+		slog.Warn("Invalid screenshot config", slog.Any("error", err))
+	}
+
+	var networkCfg localsystem.NetworkConfig
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "network", &networkCfg); err != nil {
+		slog.Warn("Failed to load network config", slog.Any("error", err))
+	}
+	if err := localsystem.ConfigureNetwork(runCtx, networkCfg); err != nil {
+		slog.Warn("Invalid network config", slog.Any("error", err))
+	}
+
+	var gpioCfg gpio.Config
+	if _, err := couchdb.DecodeMonitoringSection(monitoringCfg, "gpio", &gpioCfg); err != nil {
+		slog.Warn("Failed to load gpio config", slog.Any("error", err))
+	} else if err := gpio.Configure(gpioCfg); err != nil {
+		slog.Warn("Invalid gpio config", slog.Any("error", err))
+	}
+	slog.Info("Using gpio backend", slog.String("backend", gpio.GetBackend().Name()), slog.String("chip", gpio.DefaultChip()))
+
+	pins, err := handlers.LoadPinsFromJSON(monitoringCfg, cfgErr)
 	if err != nil {
 		slog.Error("Failed to load GPIO pins from JSON", slog.Any("error", err))
 	} else {
-		gpio.SetPins(pins)
-		gpio.StartAllMonitors()
+		if err := handlers.ReloadDividers(monitoringCfg, pins); err != nil {
+			slog.Error("Failed to start GPIO monitors", slog.Any("error", err))
+		}
 		time.Sleep(250 * time.Millisecond) // give monitors a moment to start and read initial states
 		slog.Info("GPIO monitors started", slog.Int("pinCount", len(pins)))
 	}
 
+	go handlers.WatchDividerConfig(runCtx, dividerReloadInterval)
+
 	// ===========================
 	// Start action manager
 	// ===========================
-	go actions.ActionManager().Start(context.TODO())
+	go actions.ActionManager().Start(runCtx)
 	messenger.Get().Register(model.ChanEventConverter(actions.ActionManager().EventStream))
 
 	// ===========================
@@ -167,7 +258,16 @@ func main() {
 	router.GET("/device/network", handlers.IsConnectedToInternet)
 	router.GET("/device/dhcp", handlers.GetDHCPState)
 	router.GET("/device/screenshot", handlers.GetScreenshot)
+	router.GET("/device/screenshot/outputs", handlers.GetScreenshotOutputs)
+	router.GET("/device/screen/stream", handlers.GetScreenStream)
+	router.GET("/device/screen/stream/viewers", handlers.GetScreenStreamViewers)
+	router.GET("/device/screen/status", handlers.GetScreenStatus)
+	router.GET("/device/screen/suspicious", handlers.GetSuspiciousScreenshot)
 	router.GET("/device/hardwareinfo", handlers.HardwareInfo)
+	router.GET("/device/metrics", handlers.Metrics)
+	router.GET("/device/processes", handlers.Processes)
+	router.GET("/device/alerts", handlers.GetActiveAlerts)
+	router.GET("/device/alerts/thresholds", handlers.GetAlertThresholds)
 	router.GET("/device/divider")
 	router.PUT("/device/health", handlers.GetServiceHealth)
 
@@ -176,6 +276,8 @@ func main() {
 	router.GET("/room/state", handlers.RoomState)
 	router.GET("/room/activesignal", handlers.ActiveSignal)
 	router.GET("/room/hardwareinfo", handlers.DeviceHardwareInfo)
+	router.GET("/room/hardwareinfo/history", handlers.DeviceHardwareInfoHistory)
+	router.GET("/room/maintenance", handlers.MaintenanceForecasts)
 	router.GET("/room/health", handlers.RoomHealth)
 
 	// actions
@@ -187,6 +289,18 @@ func main() {
 	router.GET("/divider/state", handlers.GetDividerState)
 	router.GET("/divider/preset/:hostname", handlers.PresetForHostname)
 	router.GET("/divider/pins/:systemID", handlers.GetDividerPins)
+	router.GET("/divider/topology", handlers.GetDividerTopology)
+	router.GET("/divider/monitors", handlers.GetDividerMonitors)
+	router.GET("/divider/requests", handlers.GetDividerRequests)
+	router.GET("/divider/history", handlers.GetDividerHistory)
+	router.GET("/divider/templates", handlers.GetDividerTemplates)
+	router.POST("/divider/monitors/reload", handlers.ReloadDividerMonitors)
+
+	// gpio
+	router.GET("/gpio/chips", handlers.GetChips)
+	router.GET("/gpio/outputs", handlers.GetOutputs)
+	router.PUT("/gpio/outputs/:name", handlers.SetOutput)
+	router.PUT("/gpio/sim/:pin/:value", handlers.SetSimulatedLine)
 
 	// action manager
 	router.GET("/actions", func(c *gin.Context) { c.JSON(http.StatusOK, echoToGin(actions.ActionManager().Info)) })
@@ -203,8 +317,31 @@ func main() {
 	api := router.Group("/api")
 	api.GET("/v1/monitoring", handlers.GetDeviceHealth)
 
-	// run!
-	router.Run(port)
+	// run! until interrupted, then let requests finish before releasing the gpio lines
+	srv := &http.Server{
+		Addr:    port,
+		Handler: router,
+		// cancelled on shutdown, which ends long-lived requests like the screen stream
+		BaseContext: func(net.Listener) context.Context { return runCtx },
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("Server stopped", slog.Any("error", err))
+	case <-runCtx.Done():
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shut down the server cleanly", slog.Any("error", err))
+		}
+		cancel()
+	}
+
+	stop()
+	slog.Info("Stopping GPIO monitors")
+	gpio.Shutdown()
 }
 
 // echoToGin adapts an Echo handler to a Gin handler (this is hacky and needs to be fixed)